@@ -0,0 +1,403 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/ca"
+	"github.com/tuanta7/keys/internal/certificate"
+	"github.com/tuanta7/keys/internal/config"
+)
+
+var (
+	caDir                 string
+	caBits                int
+	caDays                int
+	caIntermediateSubject string
+	caIntermediateDays    int
+	caCRLURLs             []string
+	caOCSPURLs            []string
+
+	caCSRFile        string
+	caProfile        string
+	caKeyOut         string
+	caChain          bool
+	caSerial         string
+	caCertFile       string
+	caReason         string
+	caRevocationTime string
+	crlDays          int
+)
+
+// caCmd represents the ca command
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Run a local certificate authority kept in a directory",
+	Long: `Run a small RSA certificate authority for test and integration environments. Everything lives
+in one directory:
+
+  root.crt, root.key                  the root CA
+  intermediate.crt, intermediate.key  the intermediate CA, when created; it issues the certificates
+  chain.pem                           the issuing CA followed by the root
+  certs/<serial>.crt                  every issued certificate
+  database.json                       serial numbers, issued certificates and revocations
+
+The keys are stored unencrypted, readable only by their owner. Every change to the database is
+made under a lock on database.lock, so parallel CI jobs can share a CA directory.
+
+Example:
+  rsa ca init --dir ca --subject "CN=Test Root CA" --intermediate-subject "CN=Test Issuing CA"
+  rsa ca issue --dir ca --csr api.csr --profile server --out api.crt
+  rsa ca issue --dir ca --profile client --subject "CN=alice" --key-out alice.key --out alice.crt
+  rsa ca revoke --dir ca --cert alice.crt --reason keyCompromise
+  rsa ca crl --dir ca --out ca.crl`,
+}
+
+var caInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a root CA, and optionally an intermediate CA",
+	Long: `Create a root CA in --dir, and with --intermediate-subject an intermediate CA signed by the root
+that issues the certificates. The root CA has no path length limit, the intermediate CA a path
+length of 0. --crl-url and --ocsp-url are written into every certificate the CA issues.
+
+Example:
+  rsa ca init --dir ca --subject "/O=Example/CN=Test Root CA"
+  rsa ca init --dir ca --subject "CN=Test Root CA" --intermediate-subject "CN=Test Issuing CA" \
+    --ocsp-url http://127.0.0.1:8888 --crl-url http://127.0.0.1:8080/ca.crl`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if certSubject == "" {
+			return errors.New("missing --subject")
+		}
+
+		signatureAlgorithm, err := signatureAlgorithm()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Truncate(time.Second)
+		root, err := caOptions(certSubject, now, caDays, -1, signatureAlgorithm)
+		if err != nil {
+			return err
+		}
+
+		opts := ca.InitOptions{Bits: caBits, Root: root, CRLURLs: caCRLURLs, OCSPURLs: caOCSPURLs}
+		if caIntermediateSubject != "" {
+			if caIntermediateDays > caDays {
+				return fmt.Errorf("--intermediate-days (%d) must not exceed --days (%d)", caIntermediateDays, caDays)
+			}
+
+			intermediate, err := caOptions(caIntermediateSubject, now, caIntermediateDays, 0, signatureAlgorithm)
+			if err != nil {
+				return err
+			}
+			opts.Intermediate = &intermediate
+		}
+
+		if err := ca.Init(caDir, opts); err != nil {
+			return err
+		}
+
+		fmt.Printf("Created CA in %s\n", caDir)
+		return nil
+	},
+}
+
+var caIssueCmd = &cobra.Command{
+	Use:   "issue",
+	Short: "Issue a certificate for a CSR, or for a new key",
+	Long: `Issue an end-entity certificate signed by the issuing CA.
+
+With --csr the request's signature is checked and its key certified. The subject and subject
+alternative names come from the request unless --subject, --dns, --ip, --email or --uri are
+given. Without --csr a new RSA key of --bits is generated and written to --key-out.
+
+The profile sets the key usages and extended key usages; those requested in a CSR are ignored:
+\- server: digitalSignature, keyEncipherment; serverAuth
+\- client: digitalSignature; clientAuth
+\- code-signing: digitalSignature; codeSigning
+\- ocsp-signing: digitalSignature; OCSPSigning, for a delegated "ocsp serve" responder
+
+Example:
+  rsa ca issue --dir ca --csr api.csr --out api.crt
+  rsa ca issue --dir ca --profile server --dns localhost --ip 127.0.0.1 --key-out tls.key --chain --out tls.crt
+  rsa ca issue --dir ca --profile code-signing --subject "CN=Release Signing" --key-out sign.key --out sign.crt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile, err := ca.LookupProfile(caProfile)
+		if err != nil {
+			return err
+		}
+
+		authority, err := ca.Open(caDir)
+		if err != nil {
+			return err
+		}
+
+		opts, err := nameOptions()
+		if err != nil {
+			return err
+		}
+
+		if opts.SignatureAlgorithm, err = signatureAlgorithm(); err != nil {
+			return err
+		}
+
+		if certDays <= 0 {
+			return fmt.Errorf("--days must be positive, got %d", certDays)
+		}
+		opts.NotBefore = time.Now().Truncate(time.Second)
+		opts.NotAfter = opts.NotBefore.AddDate(0, 0, certDays)
+
+		var (
+			pub *rsa.PublicKey
+			key *rsa.PrivateKey
+		)
+
+		nameFlagsSet := cmd.Flags().Changed("subject") || cmd.Flags().Changed("dns") || cmd.Flags().Changed("ip") ||
+			cmd.Flags().Changed("email") || cmd.Flags().Changed("uri")
+
+		if caCSRFile != "" {
+			if caKeyOut != "" {
+				return errors.New("--key-out can't be used with --csr, the requester keeps the key")
+			}
+
+			csr, err := readCSR(caCSRFile)
+			if err != nil {
+				return err
+			}
+
+			var ok bool
+			if pub, ok = csr.PublicKey.(*rsa.PublicKey); !ok {
+				return fmt.Errorf("the request holds a %s key, not RSA", csr.PublicKeyAlgorithm)
+			}
+
+			if !nameFlagsSet {
+				opts.Subject = csr.Subject
+				opts.DNSNames = csr.DNSNames
+				opts.IPAddresses = csr.IPAddresses
+				opts.EmailAddresses = csr.EmailAddresses
+				opts.URIs = csr.URIs
+			}
+		} else {
+			if caKeyOut == "" {
+				return errors.New("missing --csr, or --key-out for a new key")
+			}
+
+			if key, err = rsa.GenerateKey(rand.Reader, bits); err != nil {
+				return fmt.Errorf("failed to generate RSA key: %w", err)
+			}
+			pub = &key.PublicKey
+		}
+
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+
+		// The key is written before the certificate is issued and recorded,
+		// so that a path that can't be written doesn't leave a certificate
+		// whose key is lost.
+		if key != nil {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(caKeyOut, pem.EncodeToMemory(&pem.Block{Type: config.KeyTypePrivateKey, Bytes: der}), 0600); err != nil {
+				return err
+			}
+		}
+
+		cert, err := authority.Issue(opts, pub, profile)
+		if err != nil {
+			if key != nil {
+				os.Remove(caKeyOut)
+			}
+			return err
+		}
+
+		out := pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: cert.Raw})
+		if caChain {
+			for _, c := range authority.Chain {
+				out = append(out, pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: c.Raw})...)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Issued certificate %s for %s\n", ca.FormatSerial(cert.SerialNumber), cert.Subject)
+		return writeOutput(outputFile, out)
+	},
+}
+
+var caRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Record the revocation of an issued certificate",
+	Long: `Record the revocation of a certificate issued by the CA, given by --serial (hex, as printed by
+inspect) or --cert. It is listed in the CRLs created afterwards.
+
+Reasons: unspecified, keyCompromise, CACompromise, affiliationChanged, superseded,
+cessationOfOperation, certificateHold, privilegeWithdrawn, AACompromise.
+
+Example:
+  rsa ca revoke --dir ca --serial 5A:0F:3C:1B:22:9E:71:04 --reason superseded
+  rsa ca revoke --dir ca --cert alice.crt --reason keyCompromise --time 2025-01-31T12:00:00Z`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial, err := revocationSerial()
+		if err != nil {
+			return err
+		}
+
+		at := time.Now()
+		if caRevocationTime != "" {
+			if at, err = time.Parse(time.RFC3339, caRevocationTime); err != nil {
+				return fmt.Errorf("invalid --time %q: use RFC 3339", caRevocationTime)
+			}
+		}
+
+		authority, err := ca.Open(caDir)
+		if err != nil {
+			return err
+		}
+
+		if err := authority.Revoke(serial, caReason, at); err != nil {
+			return err
+		}
+
+		fmt.Printf("Revoked certificate %s (%s)\n", ca.FormatSerial(serial), caReason)
+		return nil
+	},
+}
+
+var caCRLCmd = &cobra.Command{
+	Use:   "crl",
+	Short: "Create a CRL of the revoked certificates",
+	Long: `Create a certificate revocation list signed by the issuing CA, listing every revoked certificate
+with its revocation time and reason. Each CRL gets the next CRL number; it is valid from now
+until --days later.
+
+Example:
+  rsa ca crl --dir ca --out ca.crl
+  rsa ca crl --dir ca --days 1 --output-format der --out ca.crl`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if crlDays <= 0 {
+			return fmt.Errorf("--days must be positive, got %d", crlDays)
+		}
+
+		authority, err := ca.Open(caDir)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Truncate(time.Second)
+		der, err := authority.CRL(now, now.AddDate(0, 0, crlDays))
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToUpper(certFormat) {
+		case config.KeyFormatPEM:
+			return writeOutput(outputFile, pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCRL, Bytes: der}))
+		case config.KeyFormatDER:
+			return writeOutput(outputFile, der)
+		default:
+			return fmt.Errorf("unsupported CRL format: %s (use pem or der)", certFormat)
+		}
+	},
+}
+
+// caOptions describes a CA certificate valid for days from notBefore.
+func caOptions(subject string, notBefore time.Time, days, maxPathLen int, signatureAlgorithm x509.SignatureAlgorithm) (certificate.Options, error) {
+	name, err := certificate.ParseName(subject)
+	if err != nil {
+		return certificate.Options{}, fmt.Errorf("invalid subject %q: %w", subject, err)
+	}
+
+	if days <= 0 {
+		return certificate.Options{}, fmt.Errorf("CA validity must be positive, got %d days", days)
+	}
+
+	return certificate.Options{
+		Subject:            name,
+		NotBefore:          notBefore,
+		NotAfter:           notBefore.AddDate(0, 0, days),
+		IsCA:               true,
+		MaxPathLen:         maxPathLen,
+		SignatureAlgorithm: signatureAlgorithm,
+	}, nil
+}
+
+func readCSR(path string) (*x509.CertificateRequest, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := parseCertificateRequest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid request signature: %w", err)
+	}
+
+	return csr, nil
+}
+
+// revocationSerial returns the serial number given with --serial or --cert.
+func revocationSerial() (serial *big.Int, err error) {
+	switch {
+	case caSerial != "" && caCertFile != "":
+		return nil, errors.New("use either --serial or --cert")
+	case caSerial != "":
+		return ca.ParseSerial(caSerial)
+	case caCertFile != "":
+		certs, err := readCertificates(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		return certs[0].SerialNumber, nil
+	default:
+		return nil, errors.New("missing --serial or --cert")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(caCmd)
+	caCmd.AddCommand(caInitCmd, caIssueCmd, caRevokeCmd, caCRLCmd)
+	caCmd.PersistentFlags().StringVarP(&caDir, "dir", "d", ".", "CA directory")
+
+	caInitCmd.Flags().StringVar(&certSubject, "subject", "", `Root CA subject, e.g. "CN=Test Root CA,O=Example"`)
+	caInitCmd.Flags().IntVar(&caBits, "bits", 4096, "RSA key size of the CA keys")
+	caInitCmd.Flags().IntVar(&caDays, "days", 3650, "Root CA validity in days")
+	caInitCmd.Flags().StringVar(&caIntermediateSubject, "intermediate-subject", "", "Create an intermediate CA with this subject")
+	caInitCmd.Flags().IntVar(&caIntermediateDays, "intermediate-days", 1825, "Intermediate CA validity in days")
+	caInitCmd.Flags().StringSliceVar(&caCRLURLs, "crl-url", nil, "CRL distribution point written into issued certificates (repeatable)")
+	caInitCmd.Flags().StringSliceVar(&caOCSPURLs, "ocsp-url", nil, "OCSP responder URL written into issued certificates (repeatable)")
+	addSignatureFlags(caInitCmd)
+
+	caIssueCmd.Flags().StringVar(&caCSRFile, "csr", "", "Certificate signing request to sign")
+	caIssueCmd.Flags().StringVar(&caProfile, "profile", ca.ProfileServer, "Certificate profile: "+strings.Join(ca.ProfileNames(), ", "))
+	caIssueCmd.Flags().StringVar(&caKeyOut, "key-out", "", "Write a newly generated key to this file (without --csr)")
+	caIssueCmd.Flags().IntVarP(&bits, "bits", "b", 2048, "RSA key size of a newly generated key")
+	caIssueCmd.Flags().IntVar(&certDays, "days", 365, "Validity in days")
+	caIssueCmd.Flags().BoolVar(&caChain, "chain", false, "Append the CA chain to the certificate")
+	caIssueCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Certificate file (default: stdout)")
+	addNameFlags(caIssueCmd)
+	addSignatureFlags(caIssueCmd)
+
+	caRevokeCmd.Flags().StringVar(&caSerial, "serial", "", "Serial number of the certificate, in hex")
+	caRevokeCmd.Flags().StringVar(&caCertFile, "cert", "", "Certificate to revoke")
+	caRevokeCmd.Flags().StringVar(&caReason, "reason", "unspecified", "Revocation reason: "+strings.Join(ca.ReasonNames(), ", "))
+	caRevokeCmd.Flags().StringVar(&caRevocationTime, "time", "", "Revocation time, RFC 3339 (default: now)")
+
+	caCRLCmd.Flags().IntVar(&crlDays, "days", 7, "Days until the next CRL update")
+	caCRLCmd.Flags().StringVarP(&outputFile, "out", "o", "", "CRL file (default: stdout)")
+	caCRLCmd.Flags().StringVarP(&certFormat, "output-format", "f", "pem", "CRL format: pem, der")
+}
@@ -0,0 +1,508 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/certificate"
+	"github.com/tuanta7/keys/internal/config"
+)
+
+var (
+	certSubject     string
+	certDNSNames    []string
+	certIPs         []string
+	certEmails      []string
+	certURIs        []string
+	certDays        int
+	certNotBefore   string
+	certKeyUsage    []string
+	certExtKeyUsage []string
+	certIsCA        bool
+	certPathLen     int
+	certFormat      string
+
+	verifyRoots         string
+	verifyIntermediates string
+	verifyPurpose       string
+	verifyHostname      string
+	verifyAt            string
+	verifyMinRSABits    int
+)
+
+// certCmd represents the cert command
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Create and verify X.509 certificates",
+}
+
+var certSelfSignCmd = &cobra.Command{
+	Use:   "selfsign",
+	Short: "Create a self-signed certificate for a key",
+	Long: `Create a self-signed X.509 certificate for any private key inspect reads, e.g. for local TLS
+or a SAML service provider.
+
+The subject is written as in RFC 4514 ("CN=example.com,O=Example,C=US") or as OpenSSL prints it
+("/C=US/O=Example/CN=example.com"). Without --subject, the first --dns name is the common name.
+
+Key usages use the OpenSSL names: digitalSignature, nonRepudiation, keyEncipherment,
+dataEncipherment, keyAgreement, keyCertSign, cRLSign, encipherOnly, decipherOnly. They default to
+digitalSignature and keyEncipherment, or keyCertSign, cRLSign and digitalSignature with --ca.
+Extended key usages are serverAuth, clientAuth, codeSigning, emailProtection, timeStamping,
+OCSPSigning and any.
+
+The certificate is signed with RSASSA-PKCS1-v1_5, or RSASSA-PSS with --padding pss, using --hash.
+
+Example:
+  rsa cert selfsign --key id_rsa --dns localhost --ip 127.0.0.1 --ext-key-usage serverAuth --out localhost.crt
+  rsa cert selfsign --key sp.pem --subject "CN=sp.example.com,O=Example" --days 730 --out sp.crt
+  rsa cert selfsign --key ca.pem --subject "CN=Test Root CA" --ca --path-len 1 --days 3650 --out ca.crt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		if parsed.Private == nil {
+			return errors.New("a self-signed certificate requires a private key")
+		}
+
+		opts, err := certificateOptions()
+		if err != nil {
+			return err
+		}
+
+		der, err := certificate.SelfSign(parsed.Private, opts)
+		if err != nil {
+			return err
+		}
+
+		data, err := encodeCertificate(der, certFormat)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(outputFile, data)
+	},
+}
+
+// certificateOptions builds the certificate options from the flags added by addCertificateFlags.
+func certificateOptions() (certificate.Options, error) {
+	opts, err := requestOptions()
+	if err != nil {
+		return opts, err
+	}
+
+	if certDays <= 0 {
+		return opts, fmt.Errorf("--days must be positive, got %d", certDays)
+	}
+
+	opts.NotBefore = time.Now().Truncate(time.Second)
+	if certNotBefore != "" {
+		if opts.NotBefore, err = time.Parse(time.RFC3339, certNotBefore); err != nil {
+			return opts, fmt.Errorf("invalid --not-before %q: use RFC 3339, e.g. 2025-01-01T00:00:00Z", certNotBefore)
+		}
+	}
+	opts.NotAfter = opts.NotBefore.AddDate(0, 0, certDays)
+
+	return opts, opts.Validate()
+}
+
+// requestOptions builds the names, extensions and signature algorithm from the flags
+// added by addRequestFlags, everything but the validity.
+func requestOptions() (certificate.Options, error) {
+	opts, err := nameOptions()
+	if err != nil {
+		return opts, err
+	}
+
+	opts.MaxPathLen = certPathLen
+	opts.IsCA = certIsCA
+
+	if opts.KeyUsage, err = certificate.ParseKeyUsage(certKeyUsage); err != nil {
+		return opts, err
+	}
+
+	if opts.ExtKeyUsage, err = certificate.ParseExtKeyUsage(certExtKeyUsage); err != nil {
+		return opts, err
+	}
+
+	opts.SignatureAlgorithm, err = signatureAlgorithm()
+	return opts, err
+}
+
+// nameOptions builds the subject and subject alternative names from the flags added by addNameFlags.
+func nameOptions() (certificate.Options, error) {
+	opts := certificate.Options{
+		DNSNames:       certDNSNames,
+		EmailAddresses: certEmails,
+		MaxPathLen:     -1,
+	}
+
+	var err error
+	if opts.Subject, err = certificate.ParseName(certSubject); err != nil {
+		return opts, fmt.Errorf("invalid --subject: %w", err)
+	}
+
+	if certSubject == "" && len(certDNSNames) > 0 {
+		opts.Subject.CommonName = certDNSNames[0]
+	}
+
+	for _, s := range certIPs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return opts, fmt.Errorf("invalid --ip: %s", s)
+		}
+		opts.IPAddresses = append(opts.IPAddresses, ip)
+	}
+
+	for _, s := range certURIs {
+		u, err := url.Parse(s)
+		if err != nil || !u.IsAbs() {
+			return opts, fmt.Errorf("invalid --uri: %s (must be an absolute URI)", s)
+		}
+		opts.URIs = append(opts.URIs, u)
+	}
+
+	return opts, nil
+}
+
+// signatureAlgorithm returns the signature algorithm selected with --hash and --padding.
+func signatureAlgorithm() (x509.SignatureAlgorithm, error) {
+	hash, err := parseHash(hashName)
+	if err != nil {
+		return x509.UnknownSignatureAlgorithm, err
+	}
+
+	switch strings.ToLower(signaturePadding) {
+	case config.PaddingPKCS1v15:
+		return certificate.SignatureAlgorithm(hash, false)
+	case config.PaddingPSS:
+		return certificate.SignatureAlgorithm(hash, true)
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported padding: %s", signaturePadding)
+	}
+}
+
+func printCertificate(cert *x509.Certificate) {
+	fmt.Printf("Subject: %s\n", cert.Subject)
+	fmt.Printf("Issuer: %s\n", cert.Issuer)
+	fmt.Printf("Serial Number: %s\n", colonHex(cert.SerialNumber.Bytes()))
+	fmt.Printf("Not Before: %s\n", cert.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Printf("Not After: %s (%s)\n", cert.NotAfter.UTC().Format(time.RFC3339), validityStatus(cert, time.Now()))
+	fmt.Printf("Signature Algorithm: %s\n", cert.SignatureAlgorithm)
+	printSubjectAltNames(cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, cert.URIs)
+
+	var extensions []string
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(certificate.OIDSubjectAltName) {
+			continue
+		}
+
+		name := certificate.ExtensionName(ext.Id)
+		if ext.Critical {
+			name += " (critical)"
+		}
+		extensions = append(extensions, fmt.Sprintf("  %s: %s", name, describeCertificateExtension(cert, ext)))
+	}
+
+	if len(extensions) > 0 {
+		fmt.Println("Extensions:")
+		fmt.Println(strings.Join(extensions, "\n"))
+	}
+}
+
+func validityStatus(cert *x509.Certificate, now time.Time) string {
+	switch {
+	case now.Before(cert.NotBefore):
+		return "not yet valid"
+	case now.After(cert.NotAfter):
+		return "expired"
+	default:
+		return fmt.Sprintf("valid, %d days left", int(cert.NotAfter.Sub(now).Hours()/24))
+	}
+}
+
+// describeCertificateExtension prints the extensions crypto/x509 parses from
+// the certificate fields, and the others with certificate.DescribeExtension.
+func describeCertificateExtension(cert *x509.Certificate, ext pkix.Extension) string {
+	switch {
+	case ext.Id.Equal(certificate.OIDSubjectKeyID):
+		return colonHex(cert.SubjectKeyId)
+	case ext.Id.Equal(certificate.OIDAuthorityKeyID):
+		return colonHex(cert.AuthorityKeyId)
+	case ext.Id.Equal(certificate.OIDCRLDistribution):
+		return strings.Join(cert.CRLDistributionPoints, ", ")
+	case ext.Id.Equal(certificate.OIDAuthorityInfo):
+		var access []string
+		for _, url := range cert.OCSPServer {
+			access = append(access, "OCSP:"+url)
+		}
+		for _, url := range cert.IssuingCertificateURL {
+			access = append(access, "CA Issuers:"+url)
+		}
+		return strings.Join(access, ", ")
+	case ext.Id.Equal(certificate.OIDCertPolicies):
+		policies := make([]string, len(cert.Policies))
+		for i, policy := range cert.Policies {
+			policies[i] = policy.String()
+		}
+		return strings.Join(policies, ", ")
+	case ext.Id.Equal(certificate.OIDNameConstraints):
+		return describeNameConstraints(cert)
+	}
+
+	value, err := certificate.DescribeExtension(ext)
+	if err != nil {
+		return fmt.Sprintf("invalid (%s)", err)
+	}
+	return value
+}
+
+func describeNameConstraints(cert *x509.Certificate) string {
+	var constraints []string
+	add := func(kind string, values []string) {
+		for _, v := range values {
+			constraints = append(constraints, kind+v)
+		}
+	}
+
+	add("permitted DNS:", cert.PermittedDNSDomains)
+	add("excluded DNS:", cert.ExcludedDNSDomains)
+	for _, ip := range cert.PermittedIPRanges {
+		constraints = append(constraints, "permitted IP:"+ip.String())
+	}
+	for _, ip := range cert.ExcludedIPRanges {
+		constraints = append(constraints, "excluded IP:"+ip.String())
+	}
+	add("permitted email:", cert.PermittedEmailAddresses)
+	add("excluded email:", cert.ExcludedEmailAddresses)
+	add("permitted URI:", cert.PermittedURIDomains)
+	add("excluded URI:", cert.ExcludedURIDomains)
+
+	return strings.Join(constraints, ", ")
+}
+
+func printSubjectAltNames(dnsNames []string, ips []net.IP, emails []string, uris []*url.URL) {
+	var names []string
+	for _, name := range dnsNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, ip := range ips {
+		names = append(names, "IP:"+ip.String())
+	}
+	for _, email := range emails {
+		names = append(names, "email:"+email)
+	}
+	for _, uri := range uris {
+		names = append(names, "URI:"+uri.String())
+	}
+
+	if len(names) > 0 {
+		fmt.Printf("Subject Alternative Names: %s\n", strings.Join(names, ", "))
+	}
+}
+
+var certVerifyCmd = &cobra.Command{
+	Use:   "verify [leaf]",
+	Short: "Build and check the certification path of a certificate",
+	Long: `Build the path from a leaf certificate to one of --roots, through --intermediates, and check
+every certificate in it:
+\- Signature: signed by the next certificate in the path; roots are trust anchors
+\- Validity: within its validity window at --at
+\- Key: RSA keys have at least --min-rsa-bits
+\- CA: issuers are CAs allowed to sign certificates, within their path length; a v1 root,
+  which has no basic constraints, is reported but still trusted
+\- Key Usage: the leaf's key usages permit --purpose
+\- Extended Key Usage: the leaf, and every CA that restricts it, allows --purpose
+\- Name Constraints: the names are within the constraints of the CAs above
+\- Hostname: with --host, the leaf is valid for that DNS name or IP address
+
+Every step of the path is listed with the outcome of each check; checking goes on after a
+failure so that all problems are reported. A leaf file holding a chain, such as fullchain.pem,
+adds the certificates after the first to the intermediates. The purposes are the extended key
+usage names of "cert selfsign"; any skips the purpose checks.
+
+Exit codes:
+\- 0: the path is valid
+\- 1: no path to a root, or a check failed
+\- 2: usage error, or the certificates could not be read
+
+Example:
+  rsa cert verify --roots ca.pem --intermediates int.pem leaf.pem
+  rsa cert verify --roots root.crt --host api.example.com fullchain.pem
+  rsa cert verify --roots root.crt --purpose codeSigning --min-rsa-bits 3072 signer.crt`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := runCertVerify(args)
+		if err == nil {
+			return nil
+		}
+
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		return &exitCodeError{code: exitUsage, err: err}
+	},
+}
+
+func runCertVerify(args []string) error {
+	path := inputFile
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	if verifyRoots == "" {
+		return errors.New("missing --roots")
+	}
+
+	opts := certificate.VerifyOptions{Purpose: x509.ExtKeyUsageAny, Hostname: verifyHostname, MinRSABits: verifyMinRSABits, Time: time.Now()}
+	if !strings.EqualFold(verifyPurpose, "any") {
+		purposes, err := certificate.ParseExtKeyUsage([]string{verifyPurpose})
+		if err != nil {
+			return err
+		}
+		opts.Purpose = purposes[0]
+	}
+
+	if verifyAt != "" {
+		at, err := time.Parse(time.RFC3339, verifyAt)
+		if err != nil {
+			return fmt.Errorf("invalid --at %q: use RFC 3339", verifyAt)
+		}
+		opts.Time = at
+	}
+
+	var err error
+	if opts.Roots, err = readCertificates(verifyRoots); err != nil {
+		return err
+	}
+
+	if verifyIntermediates != "" {
+		if opts.Intermediates, err = readCertificates(verifyIntermediates); err != nil {
+			return err
+		}
+	}
+
+	certs, err := readCertificates(path)
+	if err != nil {
+		return err
+	}
+	opts.Intermediates = append(opts.Intermediates, certs[1:]...)
+
+	result := certificate.VerifyPath(certs[0], opts)
+
+	fmt.Printf("Certification Path (at %s, purpose %s):\n", opts.Time.UTC().Format(time.RFC3339), verifyPurpose)
+	for i, step := range result.Steps {
+		fmt.Printf("[%d] %s (%s)\n", i, step.Certificate.Subject, step.Role)
+		for _, check := range step.Checks {
+			if check.Err != nil {
+				fmt.Printf("    %s: FAILED (%s)\n", check.Name, check.Err)
+			} else {
+				fmt.Printf("    %s: ok (%s)\n", check.Name, check.Detail)
+			}
+		}
+	}
+
+	fmt.Println("")
+	problems := result.Problems()
+	if len(problems) == 0 {
+		fmt.Println("Result: valid")
+		return nil
+	}
+
+	fmt.Println("Result: INVALID")
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+
+	return &exitCodeError{code: exitVerificationFailed, err: fmt.Errorf("%d problems found", len(problems))}
+}
+
+// readCertificates reads the certificates of a PEM bundle, DER certificate or PKCS #7 file.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, _, err := certificate.ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return certs, nil
+}
+
+// encodeCertificate returns der as a PEM "CERTIFICATE" block or unchanged for the der format.
+func encodeCertificate(der []byte, format string) ([]byte, error) {
+	switch strings.ToUpper(format) {
+	case config.KeyFormatPEM:
+		return pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: der}), nil
+	case config.KeyFormatDER:
+		return der, nil
+	default:
+		return nil, fmt.Errorf("unsupported certificate format: %s (use pem or der)", format)
+	}
+}
+
+// addCertificateFlags adds the flags describing a certificate, shared by cert selfsign and generate --cert.
+func addCertificateFlags(c *cobra.Command) {
+	addRequestFlags(c)
+	c.Flags().IntVar(&certDays, "days", 365, "Validity in days")
+	c.Flags().StringVar(&certNotBefore, "not-before", "", "Start of the validity, RFC 3339 (default: now)")
+}
+
+// addRequestFlags adds the flags naming a certificate subject, its extensions and
+// signature algorithm, shared by certificates and certificate requests.
+func addRequestFlags(c *cobra.Command) {
+	addNameFlags(c)
+	c.Flags().StringSliceVar(&certKeyUsage, "key-usage", nil, "Key usages, e.g. digitalSignature,keyEncipherment")
+	c.Flags().StringSliceVar(&certExtKeyUsage, "ext-key-usage", nil, "Extended key usages, e.g. serverAuth,clientAuth")
+	c.Flags().BoolVar(&certIsCA, "ca", false, "Mark the certificate as a CA in its basic constraints")
+	c.Flags().IntVar(&certPathLen, "path-len", -1, "Maximum number of intermediate CAs below a CA (default: no limit)")
+	addSignatureFlags(c)
+}
+
+func addNameFlags(c *cobra.Command) {
+	c.Flags().StringVar(&certSubject, "subject", "", `Subject, e.g. "CN=example.com,O=Example" or "/O=Example/CN=example.com"`)
+	c.Flags().StringSliceVar(&certDNSNames, "dns", nil, "DNS subject alternative names (repeatable)")
+	c.Flags().StringSliceVar(&certIPs, "ip", nil, "IP address subject alternative names (repeatable)")
+	c.Flags().StringSliceVar(&certEmails, "email", nil, "Email subject alternative names (repeatable)")
+	c.Flags().StringSliceVar(&certURIs, "uri", nil, "URI subject alternative names (repeatable)")
+}
+
+func addSignatureFlags(c *cobra.Command) {
+	c.Flags().StringVar(&hashName, "hash", "sha256", "Signature hash: sha256, sha384, sha512")
+	c.Flags().StringVar(&signaturePadding, "padding", config.PaddingPKCS1v15, "Signature padding: pkcs1v15, pss")
+}
+
+func init() {
+	rootCmd.AddCommand(certCmd)
+	certCmd.AddCommand(certSelfSignCmd, certVerifyCmd)
+
+	certSelfSignCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key file")
+	certSelfSignCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Certificate file (default: stdout)")
+	certSelfSignCmd.Flags().StringVarP(&certFormat, "output-format", "f", "pem", "Certificate format: pem, der")
+	addCertificateFlags(certSelfSignCmd)
+
+	certVerifyCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Leaf certificate file (default: stdin)")
+	certVerifyCmd.Flags().StringVar(&verifyRoots, "roots", "", "Trusted root certificates (PEM bundle, DER or PKCS #7)")
+	certVerifyCmd.Flags().StringVar(&verifyIntermediates, "intermediates", "", "Intermediate certificates to build the path with")
+	certVerifyCmd.Flags().StringVar(&verifyPurpose, "purpose", "serverAuth", "Purpose: "+strings.Join(certificate.ExtKeyUsageNames(), ", "))
+	certVerifyCmd.Flags().StringVar(&verifyHostname, "host", "", "DNS name or IP address the leaf must be valid for")
+	certVerifyCmd.Flags().StringVar(&verifyAt, "at", "", "Verification time, RFC 3339 (default: now)")
+	certVerifyCmd.Flags().IntVar(&verifyMinRSABits, "min-rsa-bits", 2048, "Smallest RSA key allowed in the path")
+}
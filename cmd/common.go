@@ -1,74 +1,538 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
+	"github.com/tuanta7/keys/internal/certificate"
 	"github.com/tuanta7/keys/internal/config"
+	"github.com/tuanta7/keys/internal/key"
+	"github.com/tuanta7/keys/internal/keycrypt"
+	"github.com/tuanta7/keys/internal/sshkey"
 )
 
 var (
-	bits         int
-	outputFormat string
-	keyFile      string
+	bits           int
+	outputFormat   string
+	keyFile        string
+	inputFile      string
+	outputFile     string
+	inputEncoding  string
+	outputEncoding string
+	hashName       string
+	keyComment     string
 )
 
 type ParsedKey struct {
-	Kind    string
-	Private *rsa.PrivateKey
-	Public  *rsa.PublicKey
-	PKCS1   []byte // raw PKCS#1 bytes
-	JWK     []byte
+	Kind       string
+	Container  string // PKCS#1, PKCS#8, SPKI, JWK, OpenSSH, RFC 4716 or PuTTY PPK
+	Encryption string // how the key file was protected, empty if it wasn't
+	Comment    string
+	Options    []string // authorized_keys options, e.g. from="..." or restrict
+	Private    *rsa.PrivateKey
+	Public     *rsa.PublicKey
+	PKCS1      []byte // raw PKCS#1 bytes
+	JWK        []byte
+	JWKMeta    key.Key // members of a JWK other than the key itself, Value is nil
+}
+
+func newPrivateParsedKey(prv *rsa.PrivateKey, container string) *ParsedKey {
+	return &ParsedKey{
+		Kind:      config.KeyTypeRSAPrivateKey,
+		Container: container,
+		Private:   prv,
+		PKCS1:     x509.MarshalPKCS1PrivateKey(prv),
+	}
+}
+
+func newPublicParsedKey(pub *rsa.PublicKey, container string) *ParsedKey {
+	return &ParsedKey{
+		Kind:      config.KeyTypeRSAPublicKey,
+		Container: container,
+		Public:    pub,
+		PKCS1:     x509.MarshalPKCS1PublicKey(pub),
+	}
 }
 
 func parseKey(data []byte) (*ParsedKey, error) {
+	// Try JWK
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJWK(trimmed)
+	}
+
+	// Try the RFC 4716 public key and PuTTY private key formats, which PEM doesn't match
+	if sshkey.IsSSH2PublicKey(data) {
+		k, err := sshkey.ParseSSH2PublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse SSH2 public key: %w", err)
+		}
+
+		parsed := newPublicParsedKey(k.Key, config.ContainerSSH2)
+		parsed.Comment = k.Comment
+		return parsed, nil
+	}
+
+	if sshkey.IsPPK(data) {
+		return parsePPK(data)
+	}
+
 	// Try PEM
 	if block, _ := pem.Decode(data); block != nil {
+		if keycrypt.IsLegacyEncrypted(block) {
+			return parseLegacyEncryptedPEM(block)
+		}
+
 		switch block.Type {
 		case config.KeyTypeRSAPrivateKey:
 			prv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 			if err != nil {
 				return nil, fmt.Errorf("parse PKCS#1 private key: %w", err)
 			}
-			return &ParsedKey{
-				Kind:    config.KeyTypeRSAPrivateKey,
-				Private: prv,
-				PKCS1:   x509.MarshalPKCS1PrivateKey(prv),
-			}, nil
+			return newPrivateParsedKey(prv, config.ContainerPKCS1), nil
 		case config.KeyTypeRSAPublicKey:
 			pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
 			if err != nil {
 				return nil, fmt.Errorf("parse PKCS#1 public key: %w", err)
 			}
-			return &ParsedKey{
-				Kind:   config.KeyTypeRSAPublicKey,
-				Public: pub,
-				PKCS1:  x509.MarshalPKCS1PublicKey(pub),
-			}, nil
+			return newPublicParsedKey(pub, config.ContainerPKCS1), nil
+		case config.KeyTypePrivateKey:
+			return parsePKCS8(block.Bytes)
+		case config.KeyTypePublicKey:
+			return parseSPKI(block.Bytes)
+		case config.KeyTypeOpenSSHPrivateKey:
+			return parseOpenSSH(block.Bytes)
+		case config.KeyTypeEncryptedPrivateKey:
+			encrypted, err := keycrypt.ParseEncryptedPKCS8(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return parseEncryptedPKCS8(encrypted)
+		case config.PEMTypeCertificate, config.PEMTypePKCS7:
+			return parseCertificates(data)
 		default:
 			return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
 		}
 	}
 
+	// Try an authorized_keys line
+	if sshkey.IsAuthorizedKeys(data) {
+		k, err := sshkey.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse authorized_keys line: %w", err)
+		}
+
+		parsed := newPublicParsedKey(k.Key, config.ContainerOpenSSH)
+		parsed.Comment = k.Comment
+		parsed.Options = k.Options
+		return parsed, nil
+	}
+
 	// Assume DER
+	if encrypted, err := keycrypt.ParseEncryptedPKCS8(data); err == nil {
+		return parseEncryptedPKCS8(encrypted)
+	}
+
 	if prv, err := x509.ParsePKCS1PrivateKey(data); err == nil {
-		return &ParsedKey{
-			Kind:    config.KeyTypeRSAPrivateKey,
-			Private: prv,
-			PKCS1:   x509.MarshalPKCS1PrivateKey(prv),
-		}, nil
+		return newPrivateParsedKey(prv, config.ContainerPKCS1), nil
+	}
+
+	if parsed, err := parsePKCS8(data); err == nil {
+		return parsed, nil
 	}
 
 	if pub, err := x509.ParsePKCS1PublicKey(data); err == nil {
-		return &ParsedKey{
-			Kind:   config.KeyTypeRSAPublicKey,
-			Public: pub,
-			PKCS1:  x509.MarshalPKCS1PublicKey(pub),
-		}, nil
+		return newPublicParsedKey(pub, config.ContainerPKCS1), nil
+	}
+
+	if parsed, err := parseSPKI(data); err == nil {
+		return parsed, nil
+	}
+
+	if parsed, err := parseCertificates(data); !errors.Is(err, certificate.ErrNoCertificates) {
+		return parsed, err
+	}
+
+	return nil, errors.New("unrecognized key format (not JWK, PEM, SSH, PuTTY, PKCS#1, PKCS#8 or SPKI DER, or a certificate)")
+}
+
+// parseCertificates returns the public key of the first certificate, the
+// leaf of a chain, keeping every certificate as the JWK "x5c" chain.
+func parseCertificates(data []byte) (*ParsedKey, error) {
+	certs, container, err := certificate.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := certs[0].PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("certificate key is not an RSA key (%s)", certs[0].PublicKeyAlgorithm)
+	}
+
+	parsed := newPublicParsedKey(pub, container)
+	for _, cert := range certs {
+		parsed.JWKMeta.X509Chain = append(parsed.JWKMeta.X509Chain, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	return parsed, nil
+}
+
+func parsePKCS8(der []byte) (*ParsedKey, error) {
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#8 private key: %w", err)
+	}
+
+	prv, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PKCS#8 key is not an RSA key (%T)", k)
+	}
+
+	return newPrivateParsedKey(prv, config.ContainerPKCS8), nil
+}
+
+// parseLegacyEncryptedPEM decrypts a traditional OpenSSL "Proc-Type: 4,ENCRYPTED" block.
+func parseLegacyEncryptedPEM(block *pem.Block) (*ParsedKey, error) {
+	passphrase, err := readPassphrase("Enter passphrase for private key: ", false)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := keycrypt.DecryptLegacyPEM(block, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed *ParsedKey
+	switch block.Type {
+	case config.KeyTypeRSAPrivateKey:
+		prv, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS#1 private key: %w", err)
+		}
+		parsed = newPrivateParsedKey(prv, config.ContainerPKCS1)
+	case config.KeyTypePrivateKey:
+		if parsed, err = parsePKCS8(der); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encrypted PEM block type: %s", block.Type)
+	}
+
+	parsed.Encryption = fmt.Sprintf("OpenSSL legacy PEM (%s)", keycrypt.LegacyCipherName(block))
+	return parsed, nil
+}
+
+func parseEncryptedPKCS8(encrypted *keycrypt.EncryptedKey) (*ParsedKey, error) {
+	passphrase, err := readPassphrase("Enter passphrase for private key: ", false)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := encrypted.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parsePKCS8(der)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed.Encryption = encrypted.String()
+	return parsed, nil
+}
+
+func parseOpenSSH(data []byte) (*ParsedKey, error) {
+	k, err := sshkey.ParsePrivateKey(data, func() ([]byte, error) {
+		return readPassphrase("Enter passphrase for private key: ", false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := newPrivateParsedKey(k.Key, config.ContainerOpenSSH)
+	parsed.Comment = k.Comment
+	if k.Encrypted() {
+		parsed.Encryption = fmt.Sprintf("OpenSSH (%s, %s with %d rounds)", k.Cipher, k.KDF, k.Rounds)
+	}
+
+	return parsed, nil
+}
+
+func parsePPK(data []byte) (*ParsedKey, error) {
+	k, err := sshkey.ParsePPK(data, func() ([]byte, error) {
+		return readPassphrase("Enter passphrase for private key: ", false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := newPrivateParsedKey(k.Key, fmt.Sprintf("%s v%d", config.ContainerPPK, k.Version))
+	parsed.Comment = k.Comment
+	switch {
+	case !k.Encrypted():
+	case k.Version == 2:
+		parsed.Encryption = fmt.Sprintf("PuTTY (%s, SHA-1 key derivation)", k.Cipher)
+	default:
+		parsed.Encryption = fmt.Sprintf("PuTTY (%s, %s with %d KiB, %d passes, parallelism %d)",
+			k.Cipher, k.KDF, k.Memory, k.Passes, k.Parallelism)
+	}
+
+	return parsed, nil
+}
+
+func parseSPKI(der []byte) (*ParsedKey, error) {
+	k, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse SPKI public key: %w", err)
+	}
+
+	pub, ok := k.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("SPKI key is not an RSA key (%T)", k)
+	}
+
+	return newPublicParsedKey(pub, config.ContainerSPKI), nil
+}
+
+func parseJWK(data []byte) (*ParsedKey, error) {
+	var k key.Key
+	if strictJWK {
+		var err error
+		if k, err = key.ParseStrict(data); err != nil {
+			return nil, fmt.Errorf("parse JWK: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("parse JWK: %w", err)
+	}
+
+	var parsed *ParsedKey
+	switch v := k.Value.(type) {
+	case *rsa.PrivateKey:
+		parsed = newPrivateParsedKey(v, config.ContainerJWK)
+	case *rsa.PublicKey:
+		parsed = newPublicParsedKey(v, config.ContainerJWK)
+	default:
+		return nil, errors.New("unsupported JWK key type")
+	}
+
+	parsed.JWK = data
+	parsed.JWKMeta = k
+	parsed.JWKMeta.Value = nil
+	return parsed, nil
+}
+
+// publicKey returns the public half of the parsed key, whichever kind it is.
+func (p *ParsedKey) publicKey() *rsa.PublicKey {
+	if p.Private != nil {
+		return &p.Private.PublicKey
+	}
+	return p.Public
+}
+
+func loadKey(path string) (*ParsedKey, error) {
+	if path == "" {
+		return nil, errors.New("missing --key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	parsed, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// openInput opens path for reading, falling back to stdin when path is empty or "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func readInput(path string) ([]byte, error) {
+	r, err := openInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+
+	return data, nil
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func encodeData(data []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case config.EncodingRaw:
+		return data, nil
+	case config.EncodingBase64:
+		return []byte(base64.StdEncoding.EncodeToString(data) + "\n"), nil
+	case config.EncodingHex:
+		return []byte(hex.EncodeToString(data) + "\n"), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+func decodeData(data []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case config.EncodingRaw:
+		return data, nil
+	case config.EncodingBase64:
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	case config.EncodingHex:
+		return hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+func parseHash(name string) (crypto.Hash, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "sha1":
+		return crypto.SHA1, nil
+	case "sha224":
+		return crypto.SHA224, nil
+	case "sha256":
+		return crypto.SHA256, nil
+	case "sha384":
+		return crypto.SHA384, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash: %s", name)
+	}
+}
+
+// checkDistinctFiles refuses an output file that is also the input: it is
+// truncated before the streamed input has been read.
+func checkDistinctFiles(input, output string) error {
+	if input == "" || input == "-" || output == "" || output == "-" {
+		return nil
+	}
+
+	in, err := os.Stat(input)
+	if err != nil {
+		return nil // reported when the input is opened
+	}
+	out, err := os.Stat(output)
+	if err != nil {
+		return nil
+	}
+
+	if os.SameFile(in, out) {
+		return fmt.Errorf("--in and --out are the same file %s, write to another file", output)
+	}
+	return nil
+}
+
+// createOutput opens path for writing, falling back to stdout when path is empty or "-".
+// A new file is created with perm. When the returned discard function is called,
+// a partially written file is removed.
+func createOutput(path string, perm os.FileMode) (w io.WriteCloser, discard func(), err error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, func() {}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil, errors.New("unrecognized key format (not PEM or PKCS#1 DER)")
+	return f, func() {
+		f.Close()
+		os.Remove(path)
+	}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// encodeWriter is the streaming counterpart of encodeData.
+func encodeWriter(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch strings.ToLower(encoding) {
+	case config.EncodingRaw:
+		return nopWriteCloser{w}, nil
+	case config.EncodingBase64:
+		return base64.NewEncoder(base64.StdEncoding, w), nil
+	case config.EncodingHex:
+		return nopWriteCloser{hex.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// decodeReader is the streaming counterpart of decodeData.
+func decodeReader(r io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(encoding) {
+	case config.EncodingRaw:
+		return r, nil
+	case config.EncodingBase64:
+		return base64.NewDecoder(base64.StdEncoding, whitespaceFilter{r}), nil
+	case config.EncodingHex:
+		return hex.NewDecoder(whitespaceFilter{r}), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// whitespaceFilter drops ASCII whitespace, so wrapped or newline-terminated text decodes.
+type whitespaceFilter struct {
+	r io.Reader
+}
+
+func (f whitespaceFilter) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			switch b {
+			case ' ', '\t', '\r', '\n':
+			default:
+				p[kept] = b
+				kept++
+			}
+		}
+
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
@@ -11,7 +13,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/tuanta7/keys/internal/config"
-	"github.com/tuanta7/keys/internal/key"
+	"github.com/tuanta7/keys/internal/keycrypt"
+	"github.com/tuanta7/keys/internal/sshkey"
 )
 
 var convertCmd = &cobra.Command{
@@ -21,19 +24,51 @@ var convertCmd = &cobra.Command{
 
 Supported output formats:
 \- jwk
-\- pem
-\- der
+\- pem: PKCS#1 ("RSA PRIVATE KEY" / "RSA PUBLIC KEY")
+\- der: PKCS#1
+\- pem-pkcs8, der-pkcs8: PKCS#8 for private keys, SPKI for public keys
+\- pem-spki, der-spki: SPKI ("PUBLIC KEY"), for a private key its public key is written
+\- openssh: "OPENSSH PRIVATE KEY" for private keys, an authorized_keys line for public keys
+\- ssh: an authorized_keys line ("ssh-rsa AAAA... comment"), for a private key its public key is written
+\- ssh2: RFC 4716 "---- BEGIN SSH2 PUBLIC KEY ----", for a private key its public key is written
+\- ppk, ppk2: PuTTY private key file, version 3 or 2
+
+The input may also be an X.509 certificate, a PEM bundle or a PKCS#7 (.p7b) bundle: the public
+key of the first certificate is converted, and as a JWK it carries the certificates as "x5c".
 
 Example:
   rsa convert --key-file id_rsa --output-format jwk
   rsa convert --key-file id_rsa.pub --output-format pem
-  rsa convert --key-file id_rsa.pub --output-format jwk > id_rsa.pub.json`,
+  rsa convert --key-file id_rsa.pub --output-format jwk > id_rsa.pub.json
+  rsa convert --key-file id_rsa --output-format jwk --alg PS256 --use sig --key-ops sign
+  rsa convert --key-file id_rsa --output-format pem-pkcs8 > private.pem
+  rsa convert --key-file id_rsa --output-format der-spki > public.der
+  rsa convert --key-file id_rsa --output-format pem-pkcs8 --encrypt --kdf scrypt > private.pem
+  rsa convert --key-file legacy.pem --output-format pem-pkcs8 --encrypt > modern.pem
+  rsa convert --key-file private.pem --output-format openssh --comment alice@laptop > id_rsa
+  rsa convert --key-file server.crt --output-format pem-spki > server.pub
+  rsa convert --key-file public.pem --output-format ssh --comment alice@laptop >> ~/.ssh/authorized_keys
+  rsa convert --key-file partner.ppk --output-format pem-pkcs8 > partner.pem
+  rsa convert --key-file id_rsa --output-format ppk --encrypt > id_rsa.ppk
+
+JWK output carries "kid", "alg", "use" and "key_ops" from the input JWK or from --kid, --alg,
+--use and --key-ops. Without a kid, the RFC 7638 thumbprint of the key is used, hashed with
+--kid-hash.
+
+Encrypted private keys, including legacy OpenSSL "Proc-Type: 4,ENCRYPTED" PEM keys (DES-EDE3-CBC,
+AES-*-CBC) and passphrase-protected OpenSSH and PuTTY keys, are decrypted with the passphrase given by --passphrase-env,
+--passphrase-file or --passphrase-fd, or typed on the terminal. With --encrypt the output
+is protected with the same passphrase, or a new one typed on the terminal. OpenSSH keys are
+encrypted with bcrypt_pbkdf and aes256-ctr, --iterations setting the bcrypt rounds. PuTTY keys
+are encrypted with aes256-cbc, version 3 deriving the key with Argon2id, --iterations setting
+its passes (default: 13).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if keyFile == "" {
 			return errors.New("missing --key-file")
 		}
 
-		if outputFormat == "" {
+		// outputFormat is shared with generate, whose default would otherwise apply.
+		if !cmd.Flags().Changed("output-format") || outputFormat == "" {
 			outputFormat = "jwk"
 		}
 
@@ -47,13 +82,36 @@ Example:
 			return fmt.Errorf("parse key: %w", err)
 		}
 
-		out, err := marshalKey(parsed, strings.ToLower(outputFormat))
+		format := strings.ToLower(outputFormat)
+		switch format {
+		case "pem-pkcs8", "der-pkcs8", "openssh", "ppk", "ppk2":
+		default:
+			if encryptKey {
+				return errors.New("--encrypt requires --output-format pem-pkcs8, der-pkcs8, openssh, ppk or ppk2")
+			}
+		}
+
+		if cmd.Flags().Changed("comment") {
+			parsed.Comment = keyComment
+		}
+
+		if format == "jwk" {
+			if parsed.JWKMeta, err = jwkMetadata(cmd, parsed.JWKMeta, parsed.publicKey()); err != nil {
+				return err
+			}
+		}
+
+		out, err := marshalKey(parsed, format)
 		if err != nil {
 			return fmt.Errorf("marshal key: %w", err)
 		}
 
-		fmt.Println(string(out))
-		return nil
+		if !bytes.HasSuffix(out, []byte("\n")) && !isBinaryFormat(outputFormat) {
+			out = append(out, '\n')
+		}
+
+		_, err = os.Stdout.Write(out)
+		return err
 	},
 }
 
@@ -65,11 +123,41 @@ func marshalKey(p *ParsedKey, format string) ([]byte, error) {
 		return marshalPEM(p), nil
 	case "jwk":
 		return marshalJWK(p)
+	case "pem-pkcs8", "der-pkcs8":
+		der, blockType, err := marshalPKCS8(p)
+		if err != nil {
+			return nil, err
+		}
+		if format == "der-pkcs8" {
+			return der, nil
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+	case "pem-spki", "der-spki":
+		der, err := x509.MarshalPKIXPublicKey(p.publicKey())
+		if err != nil {
+			return nil, err
+		}
+		if format == "der-spki" {
+			return der, nil
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: config.KeyTypePublicKey, Bytes: der}), nil
+	case "openssh":
+		return marshalOpenSSH(p)
+	case "ssh":
+		return sshkey.MarshalAuthorizedKey(p.publicKey(), p.Comment, p.Options), nil
+	case "ssh2":
+		return sshkey.MarshalSSH2PublicKey(p.publicKey(), p.Comment), nil
+	case "ppk", "ppk2":
+		return marshalPPK(p, format)
 	default:
 		return nil, fmt.Errorf("unsupported output format: %s", format)
 	}
 }
 
+func isBinaryFormat(format string) bool {
+	return strings.HasPrefix(strings.ToLower(format), "der")
+}
+
 func marshalPEM(p *ParsedKey) []byte {
 	var block *pem.Block
 	if p.Kind == config.KeyTypeRSAPrivateKey {
@@ -80,15 +168,94 @@ func marshalPEM(p *ParsedKey) []byte {
 	return pem.EncodeToMemory(block)
 }
 
+// marshalPKCS8 encodes private keys as PKCS#8, encrypted with --encrypt, and
+// public keys as SPKI, returning the matching PEM block type.
+func marshalPKCS8(p *ParsedKey) ([]byte, string, error) {
+	if p.Private != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(p.Private)
+		if err != nil {
+			return nil, "", err
+		}
+
+		opts := keyEncryptionOptions()
+		if opts == nil {
+			return der, config.KeyTypePrivateKey, nil
+		}
+
+		passphrase, err := readPassphrase("Enter new passphrase: ", true)
+		if err != nil {
+			return nil, "", err
+		}
+
+		der, err = keycrypt.EncryptPKCS8(der, passphrase, *opts)
+		return der, config.KeyTypeEncryptedPrivateKey, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(p.Public)
+	return der, config.KeyTypePublicKey, err
+}
+
+// marshalOpenSSH encodes private keys in the openssh-key-v1 format, encrypted
+// with --encrypt, and public keys as an authorized_keys line.
+func marshalOpenSSH(p *ParsedKey) ([]byte, error) {
+	if p.Private == nil {
+		return sshkey.MarshalAuthorizedKey(p.Public, p.Comment, p.Options), nil
+	}
+
+	var passphrase []byte
+	if encryptKey {
+		var err error
+		passphrase, err = readPassphrase("Enter new passphrase: ", true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := sshkey.MarshalPrivateKey(p.Private, p.Comment, passphrase, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: config.KeyTypeOpenSSHPrivateKey, Bytes: data}), nil
+}
+
+// marshalPPK encodes a private key as a PuTTY key file, encrypted with --encrypt.
+func marshalPPK(p *ParsedKey, format string) ([]byte, error) {
+	if p.Private == nil {
+		return nil, errors.New("PuTTY key files hold private keys, use ssh or ssh2 for a public key")
+	}
+
+	opts := sshkey.PPKOptions{Version: 3, Comment: p.Comment, Passes: uint32(max(kdfIterations, 0))}
+	if format == "ppk2" {
+		opts.Version = 2
+	}
+
+	if encryptKey {
+		var err error
+		opts.Passphrase, err = readPassphrase("Enter new passphrase: ", true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sshkey.MarshalPPK(p.Private, opts)
+}
+
+// marshalJWK encodes the key with the metadata in p.JWKMeta.
 func marshalJWK(p *ParsedKey) ([]byte, error) {
+	k := p.JWKMeta
 	if p.Kind == config.KeyTypeRSAPrivateKey {
-		return json.MarshalIndent(key.Key{Value: p.Private}, "", "\t")
+		k.Value = p.Private
+	} else {
+		k.Value = p.Public
 	}
-	return json.MarshalIndent(key.Key{Value: p.Public}, "", "\t")
+	return json.MarshalIndent(k, "", "\t")
 }
 
 func init() {
 	rootCmd.AddCommand(convertCmd)
 	convertCmd.Flags().StringVarP(&keyFile, "key-file", "k", "", "Key file to convert")
-	convertCmd.Flags().StringVarP(&outputFormat, "output-format", "f", "", "Target format: pem, der, jwk")
+	convertCmd.Flags().StringVarP(&outputFormat, "output-format", "f", "", "Target format: pem, der, jwk, pem-pkcs8, der-pkcs8, pem-spki, der-spki, openssh, ssh, ssh2, ppk, ppk2")
+	convertCmd.Flags().StringVar(&keyComment, "comment", "", "Comment stored in SSH and PuTTY keys (default: the input key's comment)")
+	addKeyEncryptionFlags(convertCmd)
+	addJWKFlags(convertCmd)
 }
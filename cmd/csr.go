@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/certificate"
+	"github.com/tuanta7/keys/internal/config"
+)
+
+var csrPublicKeyFile string
+
+// csrCmd represents the csr command
+var csrCmd = &cobra.Command{
+	Use:   "csr",
+	Short: "Create and inspect certificate signing requests",
+}
+
+var csrCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a PKCS #10 certificate signing request",
+	Long: `Create a PKCS #10 certificate signing request (CSR) for any private key inspect reads,
+to be signed by a CA.
+
+The subject, subject alternative names, key usages and extended key usages take the same
+values as in "cert selfsign". Key usages, extended key usages and --ca are sent as requested
+extensions; the CA decides what the certificate finally contains. The request is signed with
+RSASSA-PKCS1-v1_5, or RSASSA-PSS with --padding pss, using --hash.
+
+With --public-key the request is only created when the private key belongs to that public
+key, e.g. the one already registered with the CA.
+
+Example:
+  rsa csr create --key id_rsa --subject "CN=api.example.com,O=Example,C=US" --dns api.example.com --out api.csr
+  rsa csr create --key id_rsa --public-key registered.pub --dns api.example.com --padding pss --out api.csr
+  rsa csr create --key ca.pem --subject "CN=Example Issuing CA" --ca --path-len 0 --out issuing.csr`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		if parsed.Private == nil {
+			return errors.New("a certificate signing request requires a private key")
+		}
+
+		if csrPublicKeyFile != "" {
+			if err := checkPublicKey(csrPublicKeyFile, &parsed.Private.PublicKey); err != nil {
+				return err
+			}
+		}
+
+		opts, err := requestOptions()
+		if err != nil {
+			return err
+		}
+
+		der, err := certificate.CreateRequest(parsed.Private, opts)
+		if err != nil {
+			return err
+		}
+
+		data := der
+		switch strings.ToUpper(certFormat) {
+		case config.KeyFormatPEM:
+			data = pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificateRequest, Bytes: der})
+		case config.KeyFormatDER:
+		default:
+			return fmt.Errorf("unsupported request format: %s (use pem or der)", certFormat)
+		}
+
+		return writeOutput(outputFile, data)
+	},
+}
+
+var csrInspectCmd = &cobra.Command{
+	Use:   "inspect [file]",
+	Short: "Verify and print a certificate signing request",
+	Long: `Verify the self-signature of a PKCS #10 certificate signing request (PEM or DER) and print
+its subject, subject alternative names, requested extensions and public key.
+
+With --key the request must hold that key, given in any format inspect reads.
+The exit code is 1 when the signature is invalid or the key doesn't match.
+
+Example:
+  rsa csr inspect api.csr
+  rsa csr inspect --key id_rsa.pub api.csr`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := inputFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		data, err := readInput(path)
+		if err != nil {
+			return err
+		}
+
+		csr, err := parseCertificateRequest(data)
+		if err != nil {
+			return err
+		}
+
+		signatureErr := csr.CheckSignature()
+		printCertificateRequest(csr, signatureErr)
+
+		cmd.SilenceUsage = true
+		if signatureErr != nil {
+			return &exitCodeError{code: 1, err: fmt.Errorf("invalid request signature: %w", signatureErr)}
+		}
+
+		if keyFile != "" {
+			pub, ok := csr.PublicKey.(*rsa.PublicKey)
+			if !ok {
+				return &exitCodeError{code: 1, err: fmt.Errorf("the request holds a %s key, not RSA", csr.PublicKeyAlgorithm)}
+			}
+			if err := checkPublicKey(keyFile, pub); err != nil {
+				return &exitCodeError{code: 1, err: err}
+			}
+		}
+
+		return nil
+	},
+}
+
+// parseCertificateRequest reads a PEM or DER certificate signing request.
+func parseCertificateRequest(data []byte) (*x509.CertificateRequest, error) {
+	der := data
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("failed to decode PEM block")
+		}
+		if block.Type != config.PEMTypeCertificateRequest && block.Type != config.PEMTypeNewCertificateRequest {
+			return nil, fmt.Errorf("unsupported PEM block type: %s (expected %s)", block.Type, config.PEMTypeCertificateRequest)
+		}
+		der = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate request: %w", err)
+	}
+
+	return csr, nil
+}
+
+// checkPublicKey reports an error unless the key in path, in any format, is publicKey.
+func checkPublicKey(path string, publicKey *rsa.PublicKey) error {
+	parsed, err := loadKey(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if !parsed.publicKey().Equal(publicKey) {
+		return fmt.Errorf("the key doesn't match the public key in %s", path)
+	}
+
+	return nil
+}
+
+func printCertificateRequest(csr *x509.CertificateRequest, signatureErr error) {
+	fmt.Printf("Subject: %s\n", csr.Subject)
+	fmt.Printf("Signature Algorithm: %s\n", csr.SignatureAlgorithm)
+	if signatureErr != nil {
+		fmt.Printf("Signature: INVALID (%s)\n", signatureErr)
+	} else {
+		fmt.Printf("Signature: valid\n")
+	}
+
+	printSubjectAltNames(csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs)
+
+	var requested []string
+	for _, ext := range csr.Extensions {
+		if ext.Id.Equal(certificate.OIDSubjectAltName) {
+			continue
+		}
+
+		value, err := certificate.DescribeExtension(ext)
+		if err != nil {
+			value = fmt.Sprintf("invalid (%s)", err)
+		}
+
+		name := certificate.ExtensionName(ext.Id)
+		if ext.Critical {
+			name += " (critical)"
+		}
+		requested = append(requested, fmt.Sprintf("  %s: %s", name, value))
+	}
+
+	if len(requested) > 0 {
+		fmt.Println("Requested Extensions:")
+		fmt.Println(strings.Join(requested, "\n"))
+	}
+
+	fmt.Println("")
+	pub, ok := csr.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		fmt.Printf("Public Key Algorithm: %s\n", csr.PublicKeyAlgorithm)
+		return
+	}
+
+	fmt.Printf("Value Type: %s\n", config.KeyTypeRSAPublicKey)
+	printPublicKeyValues(pub)
+}
+
+func init() {
+	rootCmd.AddCommand(csrCmd)
+	csrCmd.AddCommand(csrCreateCmd, csrInspectCmd)
+
+	csrCreateCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key file")
+	csrCreateCmd.Flags().StringVar(&csrPublicKeyFile, "public-key", "", "Public key the private key must match")
+	csrCreateCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Request file (default: stdout)")
+	csrCreateCmd.Flags().StringVarP(&certFormat, "output-format", "f", "pem", "Request format: pem, der")
+	addRequestFlags(csrCreateCmd)
+
+	csrInspectCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Request file (default: stdin)")
+	csrInspectCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Key the request must hold")
+}
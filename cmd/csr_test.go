@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// opensslRequest was created by OpenSSL 3.0 for the key of opensslRequestKey:
+//
+//	openssl req -new -key id_rsa -subj "/C=US/O=Example/CN=api.example.com" \
+//	  -addext "subjectAltName=DNS:api.example.com,IP:127.0.0.1,email:ops@example.com" \
+//	  -addext "keyUsage=critical,digitalSignature,keyEncipherment" \
+//	  -addext "extendedKeyUsage=serverAuth"
+const opensslRequest = `-----BEGIN CERTIFICATE REQUEST-----
+MIIB4jCCAUsCAQAwOTELMAkGA1UEBhMCVVMxEDAOBgNVBAoMB0V4YW1wbGUxGDAW
+BgNVBAMMD2FwaS5leGFtcGxlLmNvbTCBnzANBgkqhkiG9w0BAQEFAAOBjQAwgYkC
+gYEA4uzqZHazb4CuH14l3ScB/NXHZbxYBezzY2NpDnM1ZurWMLeXx0QXslOEylDJ
+DGm2PHYz1tVD8lcmLyaptOhk7KIg+9X5Niav6pVKaR71vwc96YWDtgO4j+FNVYgo
+1oXWo5nWCFtIp9jUCtRTDbU7Sv0dqdJiAuyb9LqlKXxXaW0CAwEAAaBpMGcGCSqG
+SIb3DQEJDjFaMFgwMQYDVR0RBCowKIIPYXBpLmV4YW1wbGUuY29thwR/AAABgQ9v
+cHNAZXhhbXBsZS5jb20wDgYDVR0PAQH/BAQDAgWgMBMGA1UdJQQMMAoGCCsGAQUF
+BwMBMA0GCSqGSIb3DQEBCwUAA4GBAEJW06f2eak74jq57aWwJNs6MzdkNBxqoP0N
+DiKFZlpAN34292B+lRlLknlAvfPX0peRhxfkg4PaO3rpFqq11Eel60Y+ehfxXFe4
+DvO/zM3tzJ4aO7jamzXwB812itVaOkZ4CCJGgGF8Txk1qeHqwDA9Afl7jwUstI15
+ZAbAgO6P
+-----END CERTIFICATE REQUEST-----
+`
+
+// opensslRequestKey is the public key of opensslRequest, as written by ssh-keygen.
+const opensslRequestKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDi7OpkdrNvgK4fXiXdJwH81cdlvFgF7PNjY2kOczVm6tYwt5fHRBeyU4TKUMkMabY8djPW1UPyVyYvJqm06GTsoiD71fk2Jq/qlUppHvW/Bz3phYO2A7iP4U1ViCjWhdajmdYIW0in2NQK1FMNtTtK/R2p0mIC7Jv0uqUpfFdpbQ== alice@laptop\n"
+
+func TestParseCertificateRequest(t *testing.T) {
+	block, _ := pem.Decode([]byte(opensslRequest))
+
+	inputs := map[string][]byte{
+		"PEM":     []byte(opensslRequest),
+		"new PEM": pem.EncodeToMemory(&pem.Block{Type: "NEW CERTIFICATE REQUEST", Bytes: block.Bytes}),
+		"DER":     block.Bytes,
+	}
+
+	for name, data := range inputs {
+		csr, err := parseCertificateRequest(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := csr.CheckSignature(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if csr.Subject.String() != "CN=api.example.com,O=Example,C=US" ||
+			!slices.Equal(csr.DNSNames, []string{"api.example.com"}) ||
+			len(csr.IPAddresses) != 1 || !csr.IPAddresses[0].Equal(net.IPv4(127, 0, 0, 1)) ||
+			!slices.Equal(csr.EmailAddresses, []string{"ops@example.com"}) {
+			t.Fatalf("%s: got subject %s, names %v %v %v", name, csr.Subject, csr.DNSNames, csr.IPAddresses, csr.EmailAddresses)
+		}
+		if csr.SignatureAlgorithm != x509.SHA256WithRSA || len(csr.Extensions) != 3 {
+			t.Fatalf("%s: got %s with %d extensions", name, csr.SignatureAlgorithm, len(csr.Extensions))
+		}
+	}
+
+	malformed := map[string]string{
+		"certificate PEM": "-----BEGIN CERTIFICATE-----\n" + strings.SplitN(opensslRequest, "\n", 2)[1],
+		"truncated PEM":   opensslRequest[:100],
+		"truncated DER":   string(block.Bytes[:len(block.Bytes)-10]),
+	}
+	for name, data := range malformed {
+		if _, err := parseCertificateRequest([]byte(data)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestCertificateRequestTamperedSignature(t *testing.T) {
+	block, _ := pem.Decode([]byte(opensslRequest))
+	der := slices.Clone(block.Bytes)
+	der[len(der)-1] ^= 1
+
+	csr, err := parseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csr.CheckSignature(); err == nil {
+		t.Fatal("accepted a tampered signature")
+	}
+}
+
+func TestCheckPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "id_rsa.pub")
+	if err := os.WriteFile(path, []byte(opensslRequestKey), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := parseCertificateRequest([]byte(opensslRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkPublicKey(path, csr.PublicKey.(*rsa.PublicKey)); err != nil {
+		t.Fatalf("matching key: %v", err)
+	}
+
+	other := testPrivateKey(t)
+	if err := checkPublicKey(path, &other.PublicKey); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Fatalf("other key: got %v", err)
+	}
+
+	if err := checkPublicKey(filepath.Join(dir, "missing.pub"), &other.PublicKey); err == nil {
+		t.Fatal("missing file: accepted")
+	}
+}
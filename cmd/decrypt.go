@@ -1,36 +1,200 @@
 package cmd
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"math/big"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/config"
+	"github.com/tuanta7/keys/internal/envelope"
 )
 
+var sessionKeySize int
+
+// errDecryption is deliberately the only error returned once the private key
+// operation has run, so the command can't be used as a padding oracle.
+var errDecryption = errors.New("decryption failed: wrong key, wrong padding mode/hash/label, or corrupted ciphertext")
+
 // decryptCmd represents the decrypt command
 var decryptCmd = &cobra.Command{
 	Use:   "decrypt",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("decrypt called")
+	Short: "Decrypt data with an RSA private key",
+	Long: `Decrypt a message produced by the encrypt command (or any RSAES-OAEP / RSAES-PKCS1-v1_5 implementation).
+
+The ciphertext is read from --in (or stdin) in the given --input-encoding and the plaintext
+is written to --out (or stdout).
+
+Use --hybrid to decrypt data produced by "encrypt --hybrid". The output is streamed; if the
+envelope turns out to be corrupted or truncated, the output file is removed. The recipient
+entry is selected by the JWK thumbprint of the private key.
+
+Padding schemes:
+- oaep (default): must use the same --hash, --mgf-hash and --label as the encryption
+- pkcs1v15: RSAES-PKCS1-v1_5. By default the padding is checked and the command fails when
+  it is invalid, so whoever can submit ciphertexts and see the outcome has a padding oracle
+  (Bleichenbacher's attack); a warning is printed. Don't expose it to untrusted input.
+  With --session-key-size N the session key API is used instead, for unwrapping N-byte keys
+  without a padding oracle: if the padding is invalid or the plaintext isn't exactly N bytes,
+  N random bytes are written and the command succeeds, so the caller only finds out when
+  the key fails to decrypt its data.
+
+Example:
+  rsa decrypt --key id_rsa --in secret.bin
+  rsa decrypt --key id_rsa --input-encoding base64 --hash sha512 < secret.b64
+  rsa decrypt --key id_rsa --padding pkcs1v15 --in legacy.bin
+  rsa decrypt --key id_rsa --padding pkcs1v15 --session-key-size 32 --in wrapped.bin --out aes.key
+  rsa decrypt --key id_rsa --hybrid --in backup.tar.enc --out backup.tar`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		if parsed.Private == nil {
+			return errors.New("decryption requires a private key")
+		}
+
+		if hybrid {
+			return decryptHybrid(parsed.Private)
+		}
+
+		data, err := readInput(inputFile)
+		if err != nil {
+			return err
+		}
+
+		ciphertext, err := decodeData(data, inputEncoding)
+		if err != nil {
+			return fmt.Errorf("corrupted ciphertext: invalid %s encoding: %w", inputEncoding, err)
+		}
+
+		if strings.EqualFold(encryptionPadding, config.PaddingPKCS1v15) && sessionKeySize == 0 {
+			fmt.Fprintln(os.Stderr, "Warning: pkcs1v15 decryption reports padding errors, a padding oracle; use --session-key-size to unwrap keys")
+		}
+
+		plaintext, err := decrypt(parsed.Private, ciphertext)
+		if err != nil {
+			return err
+		}
+
+		if outputFile == "" || outputFile == "-" {
+			return writeOutput(outputFile, plaintext)
+		}
+
+		return os.WriteFile(outputFile, plaintext, 0600)
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(decryptCmd)
+func decrypt(privateKey *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	// Everything checked before the private key operation only depends on
+	// public information, so it is safe to report precisely.
+	if len(ciphertext) != privateKey.Size() {
+		return nil, fmt.Errorf("wrong key size: ciphertext is %d bytes but the key modulus is %d bytes (%d bits)",
+			len(ciphertext), privateKey.Size(), privateKey.Size()*8)
+	}
+
+	if new(big.Int).SetBytes(ciphertext).Cmp(privateKey.N) >= 0 {
+		return nil, errors.New("corrupted ciphertext: value is not smaller than the key modulus")
+	}
 
-	// Here you will define your flags and configuration settings.
+	switch strings.ToLower(encryptionPadding) {
+	case config.PaddingOAEP:
+		hash, mgfHash, err := oaepHashes()
+		if err != nil {
+			return nil, err
+		}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// decryptCmd.PersistentFlags().String("foo", "", "A help for foo")
+		plaintext, err := privateKey.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{
+			Hash:    hash,
+			MGFHash: mgfHash,
+			Label:   []byte(oaepLabel),
+		})
+		if err != nil {
+			return nil, errDecryption
+		}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// decryptCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+		return plaintext, nil
+	case config.PaddingPKCS1v15:
+		if sessionKeySize == 0 {
+			plaintext, err := rsa.DecryptPKCS1v15(nil, privateKey, ciphertext)
+			if err != nil {
+				return nil, errDecryption
+			}
+
+			return plaintext, nil
+		}
+
+		if sessionKeySize < 0 || sessionKeySize > privateKey.Size()-11 {
+			return nil, fmt.Errorf("--session-key-size must be between 1 and %d bytes for a %d-bit key",
+				privateKey.Size()-11, privateKey.Size()*8)
+		}
+
+		sessionKey := make([]byte, sessionKeySize)
+		if _, err := rand.Read(sessionKey); err != nil {
+			return nil, err
+		}
+
+		if err := rsa.DecryptPKCS1v15SessionKey(rand.Reader, privateKey, ciphertext, sessionKey); err != nil {
+			return nil, errDecryption
+		}
+
+		return sessionKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported padding: %s", encryptionPadding)
+	}
+}
+
+func decryptHybrid(privateKey *rsa.PrivateKey) error {
+	if err := checkDistinctFiles(inputFile, outputFile); err != nil {
+		return err
+	}
+
+	in, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	dec, err := decodeReader(in, inputEncoding)
+	if err != nil {
+		return err
+	}
+
+	kid, err := recipientID(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	// Like private keys, plaintext files are readable only by the owner.
+	out, discard, err := createOutput(outputFile, 0600)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+
+	if err := envelope.Decrypt(out, dec, privateKey, kid); err != nil {
+		discard()
+		return fmt.Errorf("decrypt: %w", err)
+	}
+
+	return out.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+	decryptCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key file")
+	decryptCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Ciphertext file (default: stdin)")
+	decryptCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Plaintext file (default: stdout)")
+	decryptCmd.Flags().StringVarP(&inputEncoding, "input-encoding", "e", config.EncodingRaw, "Ciphertext encoding: raw, base64, hex")
+	decryptCmd.Flags().StringVarP(&encryptionPadding, "padding", "p", config.PaddingOAEP, "Padding scheme: oaep, pkcs1v15 (a padding oracle unless --session-key-size is set)")
+	decryptCmd.Flags().StringVar(&hashName, "hash", "sha256", "OAEP hash: sha1, sha224, sha256, sha384, sha512")
+	decryptCmd.Flags().StringVar(&mgfHashName, "mgf-hash", "", "OAEP MGF1 hash (default: same as --hash)")
+	decryptCmd.Flags().StringVar(&oaepLabel, "label", "", "OAEP label")
+	decryptCmd.Flags().BoolVar(&hybrid, "hybrid", false, "Decrypt data produced by encrypt --hybrid")
+	decryptCmd.Flags().IntVar(&sessionKeySize, "session-key-size", 0, "Unwrap a session key of this many bytes with pkcs1v15, random bytes on failure")
 }
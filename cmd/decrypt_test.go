@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+func TestDecryptPKCS1v15(t *testing.T) {
+	key := testPrivateKey(t)
+	msg := bytes.Repeat([]byte("m"), 55)
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, savedSize := encryptionPadding, sessionKeySize
+	t.Cleanup(func() { encryptionPadding, sessionKeySize = saved, savedSize })
+	encryptionPadding = config.PaddingPKCS1v15
+
+	sessionKeySize = 0
+	plaintext, err := decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("got %d bytes %q, want %q", len(plaintext), plaintext, msg)
+	}
+
+	oaep, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &key.PublicKey, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(key, oaep); !errors.Is(err, errDecryption) {
+		t.Fatalf("OAEP ciphertext with pkcs1v15: got %v, want %v", err, errDecryption)
+	}
+
+	// The session key API only succeeds for plaintexts of the expected size.
+	sessionKeySize = 32
+	plaintext, err = decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plaintext) != 32 || bytes.Equal(plaintext[:32], msg[:32]) {
+		t.Fatalf("session key API returned %q, want 32 random bytes", plaintext)
+	}
+}
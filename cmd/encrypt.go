@@ -1,36 +1,257 @@
 package cmd
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"math/big"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/config"
+	"github.com/tuanta7/keys/internal/envelope"
+)
+
+var (
+	encryptionPadding string
+	mgfHashName       string
+	oaepLabel         string
+	hybrid            bool
 )
 
 // encryptCmd represents the encrypt command
 var encryptCmd = &cobra.Command{
 	Use:   "encrypt",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("encrypt called")
+	Short: "Encrypt data with an RSA public key",
+	Long: `Encrypt a message with an RSA public key (a private key may be given, its public half is used).
+
+The key can be in any format accepted by the other commands. The plaintext is read from --in
+(or stdin) and the ciphertext is written to --out (or stdout).
+
+Padding schemes:
+- oaep (default): RSAES-OAEP, with --hash, --mgf-hash and an optional --label
+- pkcs1v15: RSAES-PKCS1-v1_5, only for interoperability with legacy systems
+
+RSA can only encrypt messages shorter than the key modulus: with OAEP the limit is
+k - 2*hLen - 2 bytes, with PKCS#1 v1.5 it is k - 11 bytes, where k is the key size in bytes.
+
+For anything larger use --hybrid: a random AES-256-GCM data key is wrapped with RSA-OAEP
+and the input is encrypted as a stream of authenticated segments, so files of any size
+can be encrypted. The padding and hash options don't apply to hybrid encryption.
+
+Repeat --recipient to encrypt a file for several people: the data key is wrapped once for each
+public key (PEM, DER or JWK) and tagged with the key's JWK thumbprint, so that every recipient
+can decrypt the file with their own private key. --recipient implies --hybrid.
+
+Example:
+  rsa encrypt --key id_rsa.pub --in secret.txt --out secret.bin
+  echo -n "hello" | rsa encrypt --key id_rsa.pub --hash sha512 --output-encoding base64
+  rsa encrypt --key id_rsa.pub --padding pkcs1v15 --in secret.txt --output-encoding hex
+  rsa encrypt --key id_rsa.pub --hybrid --in backup.tar --out backup.tar.enc
+  rsa encrypt --recipient alice.pub --recipient bob.json --in report.pdf --out report.pdf.enc`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if hybrid || len(recipientFiles) > 0 {
+			paths := recipientFiles
+			if keyFile != "" {
+				paths = append([]string{keyFile}, paths...)
+			}
+
+			if len(paths) == 0 {
+				return errors.New("missing --key or --recipient")
+			}
+
+			recipients, err := loadRecipients(paths)
+			if err != nil {
+				return err
+			}
+
+			return encryptHybrid(recipients)
+		}
+
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := readInput(inputFile)
+		if err != nil {
+			return err
+		}
+
+		ciphertext, err := encrypt(parsed.publicKey(), plaintext)
+		if err != nil {
+			return err
+		}
+
+		out, err := encodeData(ciphertext, outputEncoding)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(outputFile, out)
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(encryptCmd)
+func encrypt(publicKey *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	switch strings.ToLower(encryptionPadding) {
+	case config.PaddingOAEP:
+		hash, mgfHash, err := oaepHashes()
+		if err != nil {
+			return nil, err
+		}
+
+		limit := publicKey.Size() - 2*hash.Size() - 2
+		if len(plaintext) > limit {
+			return nil, fmt.Errorf("plaintext is %d bytes, the maximum for a %d-bit key with OAEP/%s is %d bytes (use --hybrid for larger data)",
+				len(plaintext), publicKey.Size()*8, hash, max(limit, 0))
+		}
+
+		return encryptOAEP(publicKey, plaintext, []byte(oaepLabel), hash, mgfHash)
+	case config.PaddingPKCS1v15:
+		limit := publicKey.Size() - 11
+		if len(plaintext) > limit {
+			return nil, fmt.Errorf("plaintext is %d bytes, the maximum for a %d-bit key with PKCS#1 v1.5 is %d bytes (use --hybrid for larger data)",
+				len(plaintext), publicKey.Size()*8, limit)
+		}
+
+		return rsa.EncryptPKCS1v15(rand.Reader, publicKey, plaintext)
+	default:
+		return nil, fmt.Errorf("unsupported padding: %s", encryptionPadding)
+	}
+}
+
+func encryptHybrid(recipients []envelope.Recipient) error {
+	if err := checkDistinctFiles(inputFile, outputFile); err != nil {
+		return err
+	}
 
-	// Here you will define your flags and configuration settings.
+	in, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// encryptCmd.PersistentFlags().String("foo", "", "A help for foo")
+	out, discard, err := createOutput(outputFile, 0644)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// encryptCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	enc, err := encodeWriter(out, outputEncoding)
+	if err != nil {
+		discard()
+		return err
+	}
+
+	if err := envelope.Encrypt(enc, in, recipients); err != nil {
+		discard()
+		return fmt.Errorf("encrypt: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		discard()
+		return err
+	}
+
+	return out.Close()
+}
+
+// oaepHashes resolves --hash and --mgf-hash; the MGF1 hash defaults to the OAEP hash.
+func oaepHashes() (crypto.Hash, crypto.Hash, error) {
+	hash, err := parseHash(hashName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if mgfHashName == "" {
+		return hash, hash, nil
+	}
+
+	mgfHash, err := parseHash(mgfHashName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("mgf hash: %w", err)
+	}
+
+	return hash, mgfHash, nil
+}
+
+// encryptOAEP is rsa.EncryptOAEP with a separate MGF1 hash, which the standard
+// library only exposes for decryption (rsa.OAEPOptions).
+func encryptOAEP(publicKey *rsa.PublicKey, msg, label []byte, hash, mgfHash crypto.Hash) ([]byte, error) {
+	if hash == mgfHash {
+		return rsa.EncryptOAEP(hash.New(), rand.Reader, publicKey, msg, label)
+	}
+
+	k := publicKey.Size()
+	hLen := hash.Size()
+	if len(msg) > k-2*hLen-2 {
+		return nil, rsa.ErrMessageTooLong
+	}
+
+	// EM = 0x00 || maskedSeed || maskedDB, DB = lHash || PS || 0x01 || M
+	em := make([]byte, k)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+
+	lHash := hash.New()
+	lHash.Write(label)
+	copy(db, lHash.Sum(nil))
+	db[len(db)-len(msg)-1] = 0x01
+	copy(db[len(db)-len(msg):], msg)
+
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	mgf1XOR(db, mgfHash, seed)
+	mgf1XOR(seed, mgfHash, db)
+
+	m := new(big.Int).SetBytes(em)
+	if m.Cmp(publicKey.N) >= 0 {
+		return nil, errors.New("encoded message is larger than the modulus")
+	}
+
+	c := new(big.Int).Exp(m, big.NewInt(int64(publicKey.E)), publicKey.N)
+	return c.FillBytes(make([]byte, k)), nil
+}
+
+// mgf1XOR XORs out with the MGF1 mask generated from seed (RFC 8017, B.2.1).
+func mgf1XOR(out []byte, hash crypto.Hash, seed []byte) {
+	var counter [4]byte
+	done := 0
+	for done < len(out) {
+		h := hash.New()
+		h.Write(seed)
+		h.Write(counter[:])
+		for _, b := range h.Sum(nil) {
+			if done >= len(out) {
+				break
+			}
+			out[done] ^= b
+			done++
+		}
+
+		for i := 3; i >= 0; i-- {
+			counter[i]++
+			if counter[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	encryptCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Public (or private) key file")
+	encryptCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Plaintext file (default: stdin)")
+	encryptCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Ciphertext file (default: stdout)")
+	encryptCmd.Flags().StringVarP(&outputEncoding, "output-encoding", "e", config.EncodingRaw, "Ciphertext encoding: raw, base64, hex")
+	encryptCmd.Flags().StringVarP(&encryptionPadding, "padding", "p", config.PaddingOAEP, "Padding scheme: oaep, pkcs1v15")
+	encryptCmd.Flags().StringVar(&hashName, "hash", "sha256", "OAEP hash: sha1, sha224, sha256, sha384, sha512")
+	encryptCmd.Flags().StringVar(&mgfHashName, "mgf-hash", "", "OAEP MGF1 hash (default: same as --hash)")
+	encryptCmd.Flags().StringVar(&oaepLabel, "label", "", "OAEP label")
+	encryptCmd.Flags().BoolVar(&hybrid, "hybrid", false, "Encrypt data of any size with a wrapped AES-256-GCM key")
+	encryptCmd.Flags().StringArrayVarP(&recipientFiles, "recipient", "r", nil, "Recipient public key file (repeatable, implies --hybrid)")
 }
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+func testPrivateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// setOAEPFlags sets the encrypt/decrypt flags for the duration of the test.
+func setOAEPFlags(t *testing.T, hash, mgfHash, label string) {
+	t.Helper()
+	saved := []string{encryptionPadding, hashName, mgfHashName, oaepLabel}
+	t.Cleanup(func() {
+		encryptionPadding, hashName, mgfHashName, oaepLabel = saved[0], saved[1], saved[2], saved[3]
+	})
+	encryptionPadding, hashName, mgfHashName, oaepLabel = config.PaddingOAEP, hash, mgfHash, label
+}
+
+func TestEncryptOAEPSeparateMGFHash(t *testing.T) {
+	key := testPrivateKey(t)
+	msg := []byte("attack at dawn")
+
+	tests := []struct {
+		hash, mgfHash crypto.Hash
+	}{
+		{crypto.SHA256, crypto.SHA1},
+		{crypto.SHA1, crypto.SHA256},
+		{crypto.SHA512, crypto.SHA256},
+		{crypto.SHA256, crypto.SHA256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hash.String()+"/"+tt.mgfHash.String(), func(t *testing.T) {
+			ciphertext, err := encryptOAEP(&key.PublicKey, msg, []byte("label"), tt.hash, tt.mgfHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plaintext, err := key.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{
+				Hash:    tt.hash,
+				MGFHash: tt.mgfHash,
+				Label:   []byte("label"),
+			})
+			if err != nil {
+				t.Fatalf("crypto/rsa rejects the ciphertext: %v", err)
+			}
+			if !bytes.Equal(plaintext, msg) {
+				t.Fatalf("got %q, want %q", plaintext, msg)
+			}
+		})
+	}
+}
+
+func TestEncryptOAEPMessageTooLong(t *testing.T) {
+	key := testPrivateKey(t)
+	msg := make([]byte, key.Size()-2*crypto.SHA256.Size()-1)
+	if _, err := encryptOAEP(&key.PublicKey, msg, nil, crypto.SHA256, crypto.SHA1); !errors.Is(err, rsa.ErrMessageTooLong) {
+		t.Fatalf("got %v, want %v", err, rsa.ErrMessageTooLong)
+	}
+}
+
+func TestDecryptRejectsWrongMGFHash(t *testing.T) {
+	key := testPrivateKey(t)
+	ciphertext, err := encryptOAEP(&key.PublicKey, []byte("secret"), nil, crypto.SHA256, crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setOAEPFlags(t, "sha256", "sha256", "")
+	if _, err := decrypt(key, ciphertext); !errors.Is(err, errDecryption) {
+		t.Fatalf("decrypting with MGF1/SHA-256: got %v, want %v", err, errDecryption)
+	}
+
+	setOAEPFlags(t, "sha256", "sha1", "")
+	plaintext, err := decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "secret" {
+		t.Fatalf("got %q, want %q", plaintext, "secret")
+	}
+}
+
+// TestEncryptOAEPOpenSSL checks both directions against openssl pkeyutl with
+// -pkeyopt rsa_mgf1_md.
+func TestEncryptOAEPOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	key := testPrivateKey(t)
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pkeyopts := []string{
+		"-pkeyopt", "rsa_padding_mode:oaep",
+		"-pkeyopt", "rsa_oaep_md:sha256",
+		"-pkeyopt", "rsa_mgf1_md:sha1",
+	}
+	msg := []byte("interoperable")
+
+	ciphertext, err := encryptOAEP(&key.PublicKey, msg, nil, crypto.SHA256, crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(openssl, append([]string{"pkeyutl", "-decrypt", "-inkey", keyPath}, pkeyopts...)...)
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	plaintext, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl pkeyutl -decrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("openssl decrypted %q, want %q", plaintext, msg)
+	}
+
+	cmd = exec.Command(openssl, append([]string{"pkeyutl", "-encrypt", "-inkey", keyPath}, pkeyopts...)...)
+	cmd.Stdin = bytes.NewReader(msg)
+	ciphertext, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl pkeyutl -encrypt: %v", err)
+	}
+
+	setOAEPFlags(t, "sha256", "sha1", "")
+	plaintext, err = decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("decrypted %q, want %q", plaintext, msg)
+	}
+}
+
+func TestHybridRefusesSameInputAndOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.bin")
+	data := bytes.Repeat([]byte("plaintext"), 10000)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.bin")
+	if err := os.Link(path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	savedIn, savedOut := inputFile, outputFile
+	t.Cleanup(func() { inputFile, outputFile = savedIn, savedOut })
+
+	key := testPrivateKey(t)
+	for _, out := range []string{path, link, filepath.Join(dir, ".", "big.bin")} {
+		inputFile, outputFile = path, out
+		if err := encryptHybrid(nil); err == nil {
+			t.Fatalf("encrypt --out %s: accepted", out)
+		}
+		if err := decryptHybrid(key); err == nil {
+			t.Fatalf("decrypt --out %s: accepted", out)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("the input was overwritten")
+	}
+
+	if err := checkDistinctFiles(path, filepath.Join(dir, "new.bin")); err != nil {
+		t.Fatalf("new output file: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/fingerprint"
+	"github.com/tuanta7/keys/internal/key"
+	"github.com/tuanta7/keys/internal/sshkey"
+)
+
+const (
+	flavorSSHSHA256 = "ssh-sha256"
+	flavorSSHMD5    = "ssh-md5"
+	flavorRandomart = "randomart"
+	flavorJWK       = "jwk"
+	flavorSPKI      = "spki"
+	flavorSKI       = "ski"
+	flavorPGP       = "pgp"
+)
+
+var fingerprintFlavors = []string{flavorSSHSHA256, flavorSSHMD5, flavorRandomart, flavorJWK, flavorSPKI, flavorSKI, flavorPGP}
+
+var (
+	fingerprintTypes []string
+	fingerprintJSON  bool
+	pgpCreated       string
+)
+
+// fingerprints holds every identifier of a key; only the selected ones are set.
+type fingerprints struct {
+	SSHSHA256     string `json:"ssh_sha256,omitempty"`
+	SSHMD5        string `json:"ssh_md5,omitempty"`
+	Randomart     string `json:"randomart,omitempty"`
+	JWKThumbprint string `json:"jwk_thumbprint,omitempty"`
+	SPKISHA256    string `json:"spki_sha256,omitempty"`
+	SubjectKeyID  string `json:"subject_key_id,omitempty"`
+	OpenPGPv4     string `json:"openpgp_v4,omitempty"`
+}
+
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint <keyfile>",
+	Short: "Print the fingerprints and key IDs of an RSA key",
+	Long: `Print the identifiers different ecosystems give an RSA key, for any key format inspect reads:
+
+\- ssh-sha256: SSH SHA-256 fingerprint, as printed by "ssh-keygen -l"
+\- ssh-md5: legacy SSH MD5 fingerprint
+\- randomart: SSH randomart of the SHA-256 fingerprint, as printed by "ssh-keygen -lv"
+\- jwk: RFC 7638 JWK thumbprint (SHA-256, base64url)
+\- spki: SHA-256 of the SubjectPublicKeyInfo (base64), used for HPKP and certificate pinning
+\- ski: SHA-1 subject key identifier (RFC 5280 method 1), as in X.509 certificates
+\- pgp: OpenPGP v4 fingerprint
+
+An OpenPGP fingerprint covers the key creation time, which other key files don't record:
+set it with --pgp-created to match a key exported from GnuPG.
+
+With a single --type only the value is printed, which makes it easy to use in scripts.
+
+Example:
+  rsa fingerprint id_rsa.pub
+  rsa fingerprint --type ssh-sha256 --type jwk private.pem
+  rsa fingerprint --type pgp --pgp-created 2024-05-01T12:00:00Z key.pem
+  rsa fingerprint --json id_rsa.ppk`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flavors := fingerprintTypes
+		if len(flavors) == 0 {
+			flavors = fingerprintFlavors
+		}
+
+		for i, f := range flavors {
+			flavors[i] = strings.ToLower(f)
+			if !slices.Contains(fingerprintFlavors, flavors[i]) {
+				return fmt.Errorf("unsupported fingerprint type: %s (supported: %s)", f, strings.Join(fingerprintFlavors, ", "))
+			}
+		}
+
+		created, err := parseCreationTime(pgpCreated)
+		if err != nil {
+			return err
+		}
+
+		parsed, err := loadKey(args[0])
+		if err != nil {
+			return err
+		}
+
+		fp, err := computeFingerprints(parsed, flavors, created)
+		if err != nil {
+			return err
+		}
+
+		if fingerprintJSON {
+			out, err := json.MarshalIndent(fp, "", "\t")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(os.Stdout, "%s\n", out)
+			return err
+		}
+
+		printFingerprints(fp, flavors)
+		return nil
+	},
+}
+
+func computeFingerprints(parsed *ParsedKey, flavors []string, created time.Time) (*fingerprints, error) {
+	pub := parsed.publicKey()
+	fp := &fingerprints{}
+
+	for _, flavor := range flavors {
+		switch flavor {
+		case flavorSSHSHA256:
+			fp.SSHSHA256 = sshkey.FingerprintSHA256(pub)
+		case flavorSSHMD5:
+			fp.SSHMD5 = sshkey.FingerprintMD5(pub)
+		case flavorRandomart:
+			fp.Randomart = sshkey.Randomart(pub)
+		case flavorJWK:
+			thumbprint, err := key.Key{Value: pub}.Thumbprint(crypto.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			fp.JWKThumbprint = base64.RawURLEncoding.EncodeToString(thumbprint)
+		case flavorSPKI:
+			pin, err := fingerprint.SPKIPin(pub)
+			if err != nil {
+				return nil, err
+			}
+			fp.SPKISHA256 = base64.StdEncoding.EncodeToString(pin)
+		case flavorSKI:
+			fp.SubjectKeyID = colonHex(fingerprint.SubjectKeyID(pub))
+		case flavorPGP:
+			fp.OpenPGPv4 = strings.ToUpper(hex.EncodeToString(fingerprint.OpenPGPv4(pub, created)))
+		}
+	}
+
+	return fp, nil
+}
+
+func printFingerprints(fp *fingerprints, flavors []string) {
+	values := map[string]string{
+		flavorSSHSHA256: fp.SSHSHA256,
+		flavorSSHMD5:    fp.SSHMD5,
+		flavorRandomart: fp.Randomart,
+		flavorJWK:       fp.JWKThumbprint,
+		flavorSPKI:      fp.SPKISHA256,
+		flavorSKI:       fp.SubjectKeyID,
+		flavorPGP:       fp.OpenPGPv4,
+	}
+
+	if len(flavors) == 1 {
+		fmt.Println(strings.TrimSuffix(values[flavors[0]], "\n"))
+		return
+	}
+
+	labels := map[string]string{
+		flavorSSHSHA256: "SSH SHA-256",
+		flavorSSHMD5:    "SSH MD5",
+		flavorJWK:       "JWK Thumbprint",
+		flavorSPKI:      "SPKI SHA-256",
+		flavorSKI:       "Subject Key ID",
+		flavorPGP:       "OpenPGP v4",
+	}
+
+	// The randomart spans several lines and goes last.
+	for _, flavor := range fingerprintFlavors {
+		if label, ok := labels[flavor]; ok && slices.Contains(flavors, flavor) {
+			fmt.Printf("%-16s%s\n", label+":", values[flavor])
+		}
+	}
+
+	if slices.Contains(flavors, flavorRandomart) {
+		fmt.Print(fp.Randomart)
+	}
+}
+
+// parseCreationTime accepts an RFC 3339 time or Unix seconds; empty is the epoch.
+func parseCreationTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Unix(0, 0), nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --pgp-created %q: use RFC 3339 or Unix seconds", value)
+	}
+
+	return t, nil
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+func init() {
+	rootCmd.AddCommand(fingerprintCmd)
+	fingerprintCmd.Flags().StringSliceVarP(&fingerprintTypes, "type", "t", nil, "Fingerprints to print (repeatable): "+strings.Join(fingerprintFlavors, ", ")+" (default: all)")
+	fingerprintCmd.Flags().BoolVar(&fingerprintJSON, "json", false, "Print the fingerprints as a JSON object")
+	fingerprintCmd.Flags().StringVar(&pgpCreated, "pgp-created", "", "OpenPGP key creation time, RFC 3339 or Unix seconds (default: 1970-01-01T00:00:00Z)")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestComputeFingerprints(t *testing.T) {
+	// The key of RFC 7638, section 3.1.
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := &ParsedKey{Public: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}}
+
+	fp, err := computeFingerprints(parsed, []string{flavorJWK}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; fp.JWKThumbprint != want {
+		t.Fatalf("JWK thumbprint: got %s, want %s", fp.JWKThumbprint, want)
+	}
+	if fp.SSHSHA256 != "" || fp.OpenPGPv4 != "" {
+		t.Fatalf("computed flavors that weren't selected: %+v", fp)
+	}
+
+	fp, err = computeFingerprints(parsed, fingerprintFlavors, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp.SSHSHA256 == "" || fp.SSHMD5 == "" || fp.Randomart == "" || fp.SPKISHA256 == "" || fp.SubjectKeyID == "" || len(fp.OpenPGPv4) != 40 {
+		t.Fatalf("missing flavors: %+v", fp)
+	}
+}
+
+func TestParseCreationTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1714564800", 1714564800, false},
+		{"2024-05-01T12:00:00Z", 1714564800, false},
+		{"2024-05-01T14:00:00+02:00", 1714564800, false},
+		{"2024-05-01", 0, true},
+		{"yesterday", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCreationTime(tt.value)
+		if (err != nil) != tt.wantErr || err == nil && got.Unix() != tt.want {
+			t.Errorf("%q: got %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestColonHex(t *testing.T) {
+	if got := colonHex([]byte{0xb8, 0x2d, 0x06}); got != "B8:2D:06" {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -7,7 +7,15 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/tuanta7/keys/internal/certificate"
+	"github.com/tuanta7/keys/internal/config"
 	"github.com/tuanta7/keys/internal/generator"
+	"github.com/tuanta7/keys/internal/key"
+)
+
+var (
+	primes       int
+	generateCert bool
 )
 
 // generateCmd represents the generate command
@@ -21,11 +29,39 @@ The command creates two files in the output directory:
 - id_rsa.pub: The public key file
 
 You can customize the key size with --bits flag and the output format with --output-format flag.
+With --encrypt the private key is written as an encrypted PKCS#8 key (pem-pkcs8 or der-pkcs8),
+protected by a passphrase typed on the terminal or given with --passphrase-env, --passphrase-file
+or --passphrase-fd.
+
+JWK files carry the same "kid" in the private and the public key: --kid, or by default the
+RFC 7638 thumbprint hashed with --kid-hash. --alg, --use and --key-ops set the other members;
+the public key only lists the operations it can perform (verify, encrypt, wrapKey).
+
+The openssh format writes the key pair ssh and ssh-keygen use: an "OPENSSH PRIVATE KEY" in
+id_rsa and an authorized_keys line in id_rsa.pub. Encrypted OpenSSH keys use bcrypt_pbkdf and
+aes256-ctr, with --iterations setting the bcrypt rounds (default: 16). The ssh format keeps the
+traditional PKCS#1 "RSA PRIVATE KEY" in id_rsa, which ssh also accepts, next to an authorized_keys
+line in id_rsa.pub.
+
+--primes creates a multi-prime key (RFC 8017): the extra primes are written as PKCS#1
+otherPrimeInfos, inside PKCS#8 as well, and as the JWK "oth" member. OpenSSH and PuTTY keys only
+hold two primes. Each additional prime makes private key operations faster but factoring easier;
+keep at most 3 primes for 2048-bit keys and 4 for 4096-bit keys.
+
+With --cert a self-signed X.509 certificate for the key is written next to it, id_rsa.crt in PEM
+or id_rsa.crt.der for the DER formats; it takes the same flags as "cert selfsign" (--subject,
+--dns, --ip, --days, --key-usage, --ext-key-usage, --ca, ...).
 
 Example usage:
   rsa generate /path/to/keys
   rsa generate --bits=4096 . 
-  rsa generate --output-format=der /home/user/.ssh`,
+  rsa generate --output-format=der /home/user/.ssh
+  rsa generate --output-format=pem-pkcs8 .
+  rsa generate --output-format=pem-pkcs8 --encrypt --kdf=scrypt --cipher=aes-256-gcm .
+  rsa generate --output-format=openssh --comment=alice@laptop --encrypt ~/.ssh
+  rsa generate --output-format=jwk --alg=RS256 --use=sig --key-ops=sign,verify .
+  rsa generate --bits=4096 --primes=3 .
+  rsa generate --cert --dns=localhost --ip=127.0.0.1 --ext-key-usage=serverAuth .`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return fmt.Errorf("missing output directory path")
@@ -40,14 +76,45 @@ Example usage:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outputDirectory := args[0]
 
-		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if primes < 2 {
+			return fmt.Errorf("--primes must be at least 2, got %d", primes)
+		}
+
+		if primes > 2 && strings.EqualFold(outputFormat, config.KeyFormatOpenSSH) {
+			return fmt.Errorf("OpenSSH keys can't hold more than two primes, use another format")
+		}
+
+		var certOptions *certificate.Options
+		if generateCert {
+			opts, err := certificateOptions()
+			if err != nil {
+				return err
+			}
+			certOptions = &opts
+		}
+
+		privateKey, err := generateRSAKey(bits, primes)
 		if err != nil {
 			return fmt.Errorf("failed to generate RSA key: %w", err)
 		}
 
 		generator := &generator.RSAKeyGenerator{
-			OutputDir: outputDirectory,
-			Format:    strings.ToUpper(outputFormat),
+			OutputDir:   outputDirectory,
+			Format:      strings.ToUpper(outputFormat),
+			Comment:     keyComment,
+			Encryption:  keyEncryptionOptions(),
+			Certificate: certOptions,
+		}
+
+		if generator.JWK, err = jwkMetadata(cmd, key.Key{}, &privateKey.PublicKey); err != nil {
+			return err
+		}
+
+		if generator.Encryption != nil {
+			generator.Passphrase, err = readPassphrase("Enter passphrase for the new key: ", true)
+			if err != nil {
+				return err
+			}
 		}
 
 		if err := generator.WriteKeyPair(privateKey); err != nil {
@@ -59,8 +126,21 @@ Example usage:
 	},
 }
 
+func generateRSAKey(bits, primes int) (*rsa.PrivateKey, error) {
+	if primes == 2 {
+		return rsa.GenerateKey(rand.Reader, bits)
+	}
+	return rsa.GenerateMultiPrimeKey(rand.Reader, primes, bits)
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().IntVarP(&bits, "bits", "b", 2048, "RSA key size (e.g., 2048, 4096)")
-	generateCmd.Flags().StringVarP(&outputFormat, "output-format", "f", "pem", "Output format: pem, der, jwk")
+	generateCmd.Flags().IntVar(&primes, "primes", 2, "Number of primes, more than 2 for a multi-prime key")
+	generateCmd.Flags().StringVarP(&outputFormat, "output-format", "f", "pem", "Output format: pem, der, jwk, pem-pkcs8, der-pkcs8 (pkcs8 private key, spki public key), openssh, ssh")
+	generateCmd.Flags().StringVar(&keyComment, "comment", "", "Comment stored in OpenSSH keys")
+	addKeyEncryptionFlags(generateCmd)
+	addJWKFlags(generateCmd)
+	generateCmd.Flags().BoolVar(&generateCert, "cert", false, "Also write a self-signed certificate, id_rsa.crt")
+	addCertificateFlags(generateCmd)
 }
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"crypto/rsa"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/tuanta7/keys/internal/certificate"
 	"github.com/tuanta7/keys/internal/config"
 )
 
@@ -16,9 +21,15 @@ var inspectCmd = &cobra.Command{
 	Long: `Analyze RSA key files and display detailed information about the key.
 	
 This command supports various RSA key formats including:
-- PEM encoded PKCS#1 keys
+- PEM encoded PKCS#1, PKCS#8 and SPKI keys
 - DER formatted keys
 - JWK (JSON Web Value) format
+- OpenSSH private keys and authorized_keys lines ("ssh-rsa AAAA... comment")
+- X.509 certificates, PEM bundles of several certificates and PKCS#7 (.p7b) bundles
+
+The container the key was found in (PKCS#1, PKCS#8, SPKI, JWK or OpenSSH) is reported, and for
+encrypted private keys the encryption scheme as well. OpenSSH keys also show their comment, and
+authorized_keys lines their options (from=, command=, restrict, ...).
 
 For public keys, it displays:
 - Value type and size
@@ -26,13 +37,19 @@ For public keys, it displays:
 - Modulus
 
 For private keys, it additionally displays:
-- Prime factors (p and q)
-- CRT (Chinese Remainder Theorem) values
+- Prime factors (p and q, and r3, r4, ... for multi-prime keys)
+- CRT (Chinese Remainder Theorem) values, including d_i and t_i of every additional prime
 - Additional private key parameters
 
+For every certificate of a file, it displays the subject, issuer, serial number, validity,
+subject alternative names and extensions, followed by the details of its public key.
+
 Example:
   rsa-tools inspect private.pem
-  rsa-tools inspect public_key.der`,
+  rsa-tools inspect public_key.der
+  rsa-tools inspect ~/.ssh/authorized_keys
+  rsa-tools inspect fullchain.pem
+  rsa-tools inspect bundle.p7b`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return fmt.Errorf("missing key file path")
@@ -52,6 +69,17 @@ Example:
 			return err
 		}
 
+		// A key followed by its certificate is inspected as a key.
+		if block, _ := pem.Decode(contents); block == nil || block.Type == config.PEMTypeCertificate || block.Type == config.PEMTypePKCS7 {
+			certs, container, err := certificate.ParseCertificates(contents)
+			if err == nil {
+				return inspectCertificates(certs, container)
+			}
+			if !errors.Is(err, certificate.ErrNoCertificates) {
+				return err
+			}
+		}
+
 		parsedKey, err := parseKey(contents)
 		if err != nil {
 			return err
@@ -59,51 +87,113 @@ Example:
 
 		switch parsedKey.Kind {
 		case config.KeyTypeRSAPrivateKey:
-			return inspectPrivateKey(parsedKey.PKCS1)
+			return inspectPrivateKey(parsedKey)
 		case config.KeyTypeRSAPublicKey:
-			return inspectPublicKey(parsedKey.PKCS1)
+			return inspectPublicKey(parsedKey)
 		default:
 			return fmt.Errorf("unsupported block type: %s", parsedKey.Kind)
 		}
 	},
 }
 
-func inspectPublicKey(keyBody []byte) error {
-	publicKey, err := x509.ParsePKCS1PublicKey(keyBody)
+func inspectCertificates(certs []*x509.Certificate, container string) error {
+	for i, cert := range certs {
+		if i > 0 {
+			fmt.Println("")
+		}
+
+		fmt.Printf("Certificate %d of %d\n", i+1, len(certs))
+		fmt.Printf("Container: %s\n", container)
+		printCertificate(cert)
+
+		fmt.Println("")
+		publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			fmt.Printf("Public Key Algorithm: %s (not RSA)\n", cert.PublicKeyAlgorithm)
+			continue
+		}
+
+		fmt.Printf("Value Type: %s\n", config.KeyTypeRSAPublicKey)
+		printPublicKeyValues(publicKey)
+	}
+
+	return nil
+}
+
+func inspectPublicKey(parsedKey *ParsedKey) error {
+	publicKey, err := x509.ParsePKCS1PublicKey(parsedKey.PKCS1)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("Value Type: %s\n", config.KeyTypeRSAPublicKey)
+	fmt.Printf("Container: %s\n", parsedKey.Container)
+	if parsedKey.Comment != "" {
+		fmt.Printf("Comment: %s\n", parsedKey.Comment)
+	}
+	if len(parsedKey.Options) > 0 {
+		fmt.Printf("Options: %s\n", strings.Join(parsedKey.Options, ","))
+	}
+	printPublicKeyValues(publicKey)
+
+	return nil
+}
+
+func printPublicKeyValues(publicKey *rsa.PublicKey) {
 	fmt.Printf("Value Size: %d bits\n", publicKey.Size()*8)
 	fmt.Printf("Public Exponent (e): %d\n", publicKey.E)
 	fmt.Printf("Modulus (n): %s\n", publicKey.N)
-
-	return nil
 }
 
-func inspectPrivateKey(keyBody []byte) error {
-	privateKey, err := x509.ParsePKCS1PrivateKey(keyBody)
+func inspectPrivateKey(parsedKey *ParsedKey) error {
+	privateKey, err := x509.ParsePKCS1PrivateKey(parsedKey.PKCS1)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("Value Type: %s\n", config.KeyTypeRSAPrivateKey)
+	fmt.Printf("Container: %s\n", parsedKey.Container)
+	if parsedKey.Encryption != "" {
+		fmt.Printf("Encryption: %s\n", parsedKey.Encryption)
+	}
+	if parsedKey.Comment != "" {
+		fmt.Printf("Comment: %s\n", parsedKey.Comment)
+	}
 	fmt.Printf("Value Size: %d bits (%d bytes)\n", privateKey.Size()*8, privateKey.Size())
 	fmt.Printf("Public Exponent (e): %d\n", privateKey.E)
 	fmt.Printf("Private Exponent (d): %d\n", privateKey.D)
 	fmt.Printf("Modulus (n): %s (%d bits)\n", privateKey.N, privateKey.N.BitLen())
 	fmt.Println("")
-	fmt.Printf("Primes: p x q = n\n")
-	fmt.Printf("p (%d bits): %s\n", privateKey.Primes[0].BitLen(), privateKey.Primes[0])
-	fmt.Printf("n (%d bits): %s\n", privateKey.Primes[1].BitLen(), privateKey.Primes[1])
+	printPrimes(privateKey)
+
+	return nil
+}
+
+// printPrimes lists every prime with its CRT parameters: dp, dq and qi for the
+// first two, then the exponent d_i and coefficient t_i of each further prime
+// (RFC 8017, section 3.2).
+func printPrimes(privateKey *rsa.PrivateKey) {
+	names := []string{"p", "q"}
+	for i := 3; i <= len(privateKey.Primes); i++ {
+		names = append(names, fmt.Sprintf("r%d", i))
+	}
+
+	fmt.Printf("Primes: %s = n\n", strings.Join(names, " x "))
+	for i, prime := range privateKey.Primes {
+		fmt.Printf("%s (%d bits): %s\n", names[i], prime.BitLen(), prime)
+	}
 	fmt.Println("")
+
 	fmt.Printf("CRT Values\n")
 	fmt.Printf("dp = d mod (p-1): %s\n", privateKey.Precomputed.Dp)
 	fmt.Printf("dq = d mod (q-1): %s\n", privateKey.Precomputed.Dq)
 	fmt.Printf("qi = q ^ -1 mod p: %s\n", privateKey.Precomputed.Qinv)
 
-	return nil
+	for i, crt := range privateKey.Precomputed.CRTValues {
+		r := names[i+2]
+		fmt.Printf("d%d = d mod (%s-1): %s\n", i+3, r, crt.Exp)
+		fmt.Printf("t%d = (%s) ^ -1 mod %s: %s\n", i+3, strings.Join(names[:i+2], " x "), r, crt.Coeff)
+	}
 }
 
 func init() {
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/key"
+)
+
+var (
+	strictJWK bool
+
+	jwkKeyID     string
+	jwkAlgorithm string
+	jwkUse       string
+	jwkKeyOps    []string
+	kidHashName  string
+)
+
+// jwkCmd represents the jwk command
+var jwkCmd = &cobra.Command{
+	Use:   "jwk",
+	Short: "Work with JSON Web Keys",
+}
+
+var jwkValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Strictly validate a JWK or JWK Set",
+	Long: `Check a JWK or JWK Set against RFC 7517 and RFC 7518 more strictly than the other commands
+read it, reporting every problem with its JSONPath, e.g. "$.keys[2].qi: not canonical base64url".
+
+Reported problems include padded or standard-alphabet base64, leading zero octets in integers,
+CRT values (dp, dq, qi, oth) inconsistent with d and the primes, exponents over 2^31-1,
+"use", "key_ops" and "alg" that contradict each other, duplicate members, and in a set,
+duplicate kids and mixed private and public keys. The exit code is 1 when any is found.
+
+The global --strict-jwk flag applies the same checks wherever a command reads a JWK.
+
+Example:
+  rsa jwk validate key.json
+  curl -s https://login.example.com/.well-known/jwks.json | rsa jwk validate`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := inputFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		data, err := readInput(path)
+		if err != nil {
+			return err
+		}
+
+		errs := key.Validate(data)
+		if len(errs) == 0 {
+			fmt.Println("valid")
+			return nil
+		}
+
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return &exitCodeError{code: 1, err: fmt.Errorf("%d problems found", len(errs))}
+	},
+}
+
+// jwkMetadata returns the members to write with a JWK. Those of base, e.g. the
+// JWK being converted, are kept unless --kid, --alg, --use or --key-ops override them.
+// Without a kid, the RFC 7638 thumbprint of publicKey with --kid-hash is used.
+func jwkMetadata(cmd *cobra.Command, base key.Key, publicKey *rsa.PublicKey) (key.Key, error) {
+	meta := base
+	meta.Value = nil
+
+	if cmd.Flags().Changed("alg") {
+		if !slices.Contains(key.RSAAlgorithms, jwkAlgorithm) {
+			return key.Key{}, fmt.Errorf("unsupported alg %q for an RSA key (supported: %s)", jwkAlgorithm, strings.Join(key.RSAAlgorithms, ", "))
+		}
+		meta.Algorithm = jwkAlgorithm
+	}
+
+	if cmd.Flags().Changed("use") {
+		if jwkUse != key.UseSignature && jwkUse != key.UseEncryption {
+			return key.Key{}, fmt.Errorf("unsupported use %q (supported: %s, %s)", jwkUse, key.UseSignature, key.UseEncryption)
+		}
+		meta.Use = jwkUse
+	}
+
+	if cmd.Flags().Changed("key-ops") {
+		for _, op := range jwkKeyOps {
+			if !slices.Contains(key.KeyOps, op) {
+				return key.Key{}, fmt.Errorf("unsupported key operation %q (supported: %s)", op, strings.Join(key.KeyOps, ", "))
+			}
+		}
+		meta.KeyOps = jwkKeyOps
+	}
+
+	if cmd.Flags().Changed("kid") {
+		meta.KeyID = jwkKeyID
+	}
+
+	if meta.KeyID == "" {
+		hash, err := parseHash(kidHashName)
+		if err != nil {
+			return key.Key{}, fmt.Errorf("--kid-hash: %w", err)
+		}
+
+		thumbprint, err := key.Key{Value: publicKey}.Thumbprint(hash)
+		if err != nil {
+			return key.Key{}, err
+		}
+		meta.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	return meta, nil
+}
+
+func init() {
+	rootCmd.AddCommand(jwkCmd)
+	jwkCmd.AddCommand(jwkValidateCmd)
+	jwkValidateCmd.Flags().StringVarP(&inputFile, "in", "i", "", "JWK or JWK Set file (default: stdin)")
+	rootCmd.PersistentFlags().BoolVar(&strictJWK, "strict-jwk", false, "Reject JWK input that \"jwk validate\" finds problems with")
+}
+
+func addJWKFlags(c *cobra.Command) {
+	c.Flags().StringVar(&jwkKeyID, "kid", "", "JWK key ID (default: the RFC 7638 thumbprint)")
+	c.Flags().StringVar(&jwkAlgorithm, "alg", "", "JWK algorithm, e.g. RS256, PS256, RSA-OAEP-256")
+	c.Flags().StringVar(&jwkUse, "use", "", "JWK public key use: sig or enc")
+	c.Flags().StringSliceVar(&jwkKeyOps, "key-ops", nil, "JWK key operations, e.g. sign,verify")
+	c.Flags().StringVar(&kidHashName, "kid-hash", "sha256", "Hash of the thumbprint used as default kid: sha1, sha256, sha384, sha512")
+}
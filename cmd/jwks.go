@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/key"
+)
+
+var (
+	jwksKeyFiles []string
+	jwksKeyIDs   []string
+)
+
+// jwksCmd represents the jwks command
+var jwksCmd = &cobra.Command{
+	Use:   "jwks",
+	Short: "Manage JWK Sets",
+	Long: `Create and edit JWK Sets ({"keys":[...]}), as published by OpenID Connect and OAuth servers.
+
+Every key in a set needs a unique "kid", and a set holds either private or public keys, never
+both: "add" and "merge" refuse to break these rules, and "list" reports sets that do.
+
+Example:
+  rsa jwks add --key signing.pem --alg RS256 --use sig --out jwks.json
+  rsa jwks add --in jwks.json --key next.pem --alg RS256 --use sig --out jwks.json
+  rsa jwks list --in jwks.json
+  rsa jwks remove --in jwks.json --kid NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs --out jwks.json
+  rsa jwks merge current.json next.json --out jwks.json
+  rsa jwks public --in jwks.json --out public-jwks.json`,
+}
+
+var jwksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys of a JWK Set",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := readJWKS(inputFile)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range set.Keys {
+			kind := "public"
+			if k.IsPrivate() {
+				kind = "private"
+			}
+
+			bits := 0
+			if pub, ok := k.Public().Value.(*rsa.PublicKey); ok {
+				bits = pub.N.BitLen()
+			}
+
+			fmt.Printf("%s\tRSA %d\t%s\talg=%s\tuse=%s\tkey_ops=%s\n",
+				orNone(k.KeyID), bits, kind, orNone(k.Algorithm), orNone(k.Use), orNone(strings.Join(k.KeyOps, ",")))
+		}
+
+		if err := set.Validate(); err != nil {
+			return fmt.Errorf("invalid JWK Set:\n%w", err)
+		}
+
+		return nil
+	},
+}
+
+var jwksAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add keys to a JWK Set, creating it without --in",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(jwksKeyFiles) == 0 {
+			return errors.New("missing --key")
+		}
+
+		var set key.JSONWebKeySet
+		if inputFile != "" {
+			var err error
+			if set, err = readJWKS(inputFile); err != nil {
+				return err
+			}
+		}
+
+		for _, path := range jwksKeyFiles {
+			parsed, err := loadKey(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			k, err := jwkMetadata(cmd, parsed.JWKMeta, parsed.publicKey())
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			if parsed.Private != nil {
+				k.Value = parsed.Private
+			} else {
+				k.Value = parsed.Public
+			}
+
+			if err := set.Add(k); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		return writeJWKS(outputFile, set)
+	},
+}
+
+var jwksRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove keys from a JWK Set by kid",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(jwksKeyIDs) == 0 {
+			return errors.New("missing --kid")
+		}
+
+		set, err := readJWKS(inputFile)
+		if err != nil {
+			return err
+		}
+
+		for _, kid := range jwksKeyIDs {
+			if !set.Remove(kid) {
+				return fmt.Errorf("no key with kid %s", kid)
+			}
+		}
+
+		return writeJWKS(outputFile, set)
+	},
+}
+
+var jwksMergeCmd = &cobra.Command{
+	Use:   "merge <jwks>...",
+	Short: "Merge JWK Sets into one",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var merged key.JSONWebKeySet
+		for _, path := range args {
+			set, err := readJWKS(path)
+			if err != nil {
+				return err
+			}
+
+			if err := merged.Merge(set); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		return writeJWKS(outputFile, merged)
+	},
+}
+
+var jwksPublicCmd = &cobra.Command{
+	Use:   "public",
+	Short: "Strip the private members from every key of a JWK Set",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := readJWKS(inputFile)
+		if err != nil {
+			return err
+		}
+
+		return writeJWKS(outputFile, set.Public())
+	},
+}
+
+func readJWKS(path string) (key.JSONWebKeySet, error) {
+	var set key.JSONWebKeySet
+
+	data, err := readInput(path)
+	if err != nil {
+		return set, err
+	}
+
+	if strictJWK {
+		if set, err = key.ParseSetStrict(data); err != nil {
+			return set, fmt.Errorf("parse JWK Set: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &set); err != nil {
+		return set, fmt.Errorf("parse JWK Set: %w", err)
+	}
+
+	return set, nil
+}
+
+// writeJWKS writes the set, readable only by the owner when it holds private keys.
+func writeJWKS(path string, set key.JSONWebKeySet) error {
+	data, err := json.MarshalIndent(set, "", "\t")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" || path == "-" || !slices.ContainsFunc(set.Keys, key.Key.IsPrivate) {
+		return writeOutput(path, data)
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(jwksCmd)
+	jwksCmd.AddCommand(jwksListCmd, jwksAddCmd, jwksRemoveCmd, jwksMergeCmd, jwksPublicCmd)
+
+	for _, c := range []*cobra.Command{jwksListCmd, jwksRemoveCmd, jwksPublicCmd} {
+		c.Flags().StringVarP(&inputFile, "in", "i", "", "JWK Set file (default: stdin)")
+	}
+	jwksAddCmd.Flags().StringVarP(&inputFile, "in", "i", "", "JWK Set file to add to (default: a new set)")
+
+	for _, c := range []*cobra.Command{jwksAddCmd, jwksRemoveCmd, jwksMergeCmd, jwksPublicCmd} {
+		c.Flags().StringVarP(&outputFile, "out", "o", "", "Output file, may be the same as --in (default: stdout)")
+	}
+
+	jwksAddCmd.Flags().StringArrayVarP(&jwksKeyFiles, "key", "k", nil, "Key file in any supported format (repeatable)")
+	addJWKFlags(jwksAddCmd)
+	jwksRemoveCmd.Flags().StringArrayVar(&jwksKeyIDs, "kid", nil, "kid of the key to remove (repeatable)")
+}
@@ -0,0 +1,505 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/ca"
+	"github.com/tuanta7/keys/internal/ocsp"
+)
+
+// Exit codes of ocsp query.
+const (
+	exitCertificateNotGood = 1
+	exitResponseInvalid    = 2
+)
+
+// maxClockSkew is how far in the future a response's thisUpdate may lie.
+const maxClockSkew = 5 * time.Minute
+
+var (
+	ocspAddr           string
+	ocspIssuerFile     string
+	ocspResponderCert  string
+	ocspResponderKey   string
+	ocspRevocations    string
+	ocspCADir          string
+	ocspThisUpdate     string
+	ocspNextUpdate     string
+	ocspURL            string
+	ocspCertFiles      []string
+	ocspSerials        []string
+	ocspNonce          bool
+	ocspIDHash         string
+	ocspGet            bool
+	ocspTimeout        time.Duration
+	ocspResponseOutput string
+)
+
+// ocspCmd represents the ocsp command
+var ocspCmd = &cobra.Command{
+	Use:   "ocsp",
+	Short: "Run and query a local OCSP responder",
+}
+
+var ocspServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Answer OCSP requests over HTTP",
+	Long: `Answer RFC 6960 OCSP requests, sent with POST or GET, for the certificates of one issuer.
+
+The certificate statuses come from either:
+\- --revocations: a text file listing revoked certificates; every other certificate is good.
+  It is read again for every request, so revocations can be added while the responder runs.
+  Each line holds a serial number in hex, the revocation time in RFC 3339 and optionally a
+  reason; # starts a comment:
+    5A0F3C1B229E7104  2025-01-31T12:00:00Z  keyCompromise
+\- --ca-dir: the database of a "ca" directory, whose issuing CA is the issuer; certificates
+  the CA didn't issue are unknown.
+
+Responses are signed by the issuer's key (--key), or by a delegated responder certificate issued
+for OCSPSigning (--responder-cert and --responder-key), with RSASSA-PKCS1-v1_5 and --hash. A nonce
+in the request is returned in the response.
+
+--this-update is an offset from the time of the request, such as -1h, or a fixed RFC 3339 time.
+--next-update is an offset from thisUpdate, a fixed RFC 3339 time, or none. Fixed times in the
+past produce stale responses for testing clients.
+
+Example:
+  rsa ocsp serve --issuer ca.crt --key ca.key --revocations revoked.txt
+  rsa ocsp serve --ca-dir ca --addr 127.0.0.1:8888 --next-update 1h
+  rsa ocsp serve --issuer ca.crt --responder-cert ocsp.crt --responder-key ocsp.key --revocations revoked.txt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		responder, err := newResponder()
+		if err != nil {
+			return err
+		}
+
+		// Check the time flags once up front rather than on every request.
+		if _, _, err := ocspTimes(time.Now()); err != nil {
+			return err
+		}
+
+		logger := log.New(os.Stderr, "", log.LstdFlags)
+		responder.Logf = logger.Printf
+		responder.Times = func(now time.Time) (time.Time, time.Time) {
+			thisUpdate, nextUpdate, _ := ocspTimes(now)
+			return thisUpdate, nextUpdate
+		}
+
+		logger.Printf("OCSP responder for %s listening on http://%s", responder.Issuer.Subject, ocspAddr)
+		return http.ListenAndServe(ocspAddr, responder)
+	},
+}
+
+var ocspQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Ask an OCSP responder for the status of certificates",
+	Long: `Send an OCSP request for one or more certificates of --issuer, given with --cert or --serial,
+verify the signed response and print the status of each certificate.
+
+The responder URL defaults to the OCSP URL in the first certificate. The request carries a random
+nonce, which the response must return, unless --nonce=false. The response must be signed by the
+issuer or by a responder certificate it issued for OCSPSigning, and be current.
+
+Exit codes:
+\- 0: every certificate is good
+\- 1: a certificate is revoked or unknown
+\- 2: usage error, the request failed, or the response is not successful, not validly signed,
+  stale or doesn't answer the request
+
+Example:
+  rsa ocsp query --issuer ca.crt --cert server.crt
+  rsa ocsp query --issuer ca.crt --serial 5A0F3C1B229E7104 --url http://127.0.0.1:8888
+  rsa ocsp query --issuer chain.pem --cert a.crt --cert b.crt --get --out response.der`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := runOCSPQuery()
+		if err == nil {
+			return nil
+		}
+
+		cmd.SilenceUsage = true
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			return err
+		}
+
+		return &exitCodeError{code: exitResponseInvalid, err: err}
+	},
+}
+
+// newResponder loads the issuer, signer and certificate statuses given by the serve flags.
+func newResponder() (*ocsp.Responder, error) {
+	hash, err := parseHash(hashName)
+	if err != nil {
+		return nil, err
+	}
+
+	responder := &ocsp.Responder{Hash: hash}
+	switch {
+	case ocspCADir != "" && (ocspRevocations != "" || ocspIssuerFile != "" || keyFile != ""):
+		return nil, errors.New("--ca-dir can't be combined with --revocations, --issuer or --key")
+	case ocspCADir != "":
+		authority, err := ca.Open(ocspCADir)
+		if err != nil {
+			return nil, err
+		}
+
+		responder.Issuer = authority.Certificate
+		responder.Certificate = authority.Certificate
+		responder.Key = authority.Key
+		responder.Source = ocsp.Database{CA: authority}
+	case ocspRevocations != "":
+		if ocspIssuerFile == "" {
+			return nil, errors.New("missing --issuer")
+		}
+
+		issuers, err := readCertificates(ocspIssuerFile)
+		if err != nil {
+			return nil, err
+		}
+		responder.Issuer = issuers[0]
+		responder.Certificate = issuers[0]
+
+		// Report a malformed file now rather than on the first request.
+		if _, err := ocsp.ReadRevocationFile(ocspRevocations); err != nil {
+			return nil, err
+		}
+		responder.Source = ocsp.RevocationFile{Path: ocspRevocations}
+	default:
+		return nil, errors.New("missing --revocations or --ca-dir")
+	}
+
+	switch {
+	case ocspResponderCert != "" || ocspResponderKey != "":
+		if ocspResponderCert == "" || ocspResponderKey == "" {
+			return nil, errors.New("--responder-cert and --responder-key are used together")
+		}
+		if keyFile != "" {
+			return nil, errors.New("use either --key or a delegated responder")
+		}
+
+		certs, err := readCertificates(ocspResponderCert)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ocsp.CheckResponderCertificate(certs[0], responder.Issuer, time.Now()); err != nil {
+			return nil, err
+		}
+		responder.Certificate = certs[0]
+		keyFile = ocspResponderKey
+	case responder.Key != nil:
+		return responder, nil
+	}
+
+	parsed, err := loadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Private == nil {
+		return nil, errors.New("signing responses requires a private key")
+	}
+
+	if !parsed.Private.PublicKey.Equal(responder.Certificate.PublicKey) {
+		return nil, fmt.Errorf("the key doesn't belong to %s", responder.Certificate.Subject)
+	}
+
+	responder.Key = parsed.Private
+	return responder, nil
+}
+
+// ocspTimes returns the thisUpdate and nextUpdate of a response produced at now.
+func ocspTimes(now time.Time) (thisUpdate, nextUpdate time.Time, err error) {
+	if thisUpdate, err = timeOrOffset(ocspThisUpdate, now); err != nil {
+		return thisUpdate, nextUpdate, fmt.Errorf("invalid --this-update: %w", err)
+	}
+
+	if strings.EqualFold(ocspNextUpdate, "none") {
+		return thisUpdate, time.Time{}, nil
+	}
+
+	if nextUpdate, err = timeOrOffset(ocspNextUpdate, thisUpdate); err != nil {
+		return thisUpdate, nextUpdate, fmt.Errorf("invalid --next-update: %w", err)
+	}
+
+	if !nextUpdate.After(thisUpdate) {
+		return thisUpdate, nextUpdate, errors.New("--next-update must be after --this-update")
+	}
+
+	return thisUpdate, nextUpdate, nil
+}
+
+// timeOrOffset parses an RFC 3339 time, or a duration added to base.
+func timeOrOffset(s string, base time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	offset, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+
+	return base.Add(offset), nil
+}
+
+func runOCSPQuery() error {
+	if ocspIssuerFile == "" {
+		return errors.New("missing --issuer")
+	}
+
+	issuers, err := readCertificates(ocspIssuerFile)
+	if err != nil {
+		return err
+	}
+	issuer := issuers[0]
+
+	hash, err := parseHash(ocspIDHash)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ids       []ocsp.CertID
+		serverURL = ocspURL
+	)
+
+	addID := func(serial *big.Int) error {
+		id, err := ocsp.NewCertID(issuer, serial, hash)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	}
+
+	for _, path := range ocspCertFiles {
+		certs, err := readCertificates(path)
+		if err != nil {
+			return err
+		}
+
+		cert := certs[0]
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			return fmt.Errorf("%s is not issued by %s: %w", path, issuer.Subject, err)
+		}
+
+		if serverURL == "" && len(cert.OCSPServer) > 0 {
+			serverURL = cert.OCSPServer[0]
+		}
+
+		if err := addID(cert.SerialNumber); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range ocspSerials {
+		serial, err := ca.ParseSerial(s)
+		if err != nil {
+			return err
+		}
+
+		if err := addID(serial); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) == 0 {
+		return errors.New("missing --cert or --serial")
+	}
+
+	if serverURL == "" {
+		return errors.New("missing --url: the certificate names no OCSP responder")
+	}
+
+	var nonce []byte
+	if ocspNonce {
+		nonce = make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+	}
+
+	request, err := ocsp.CreateRequest(ids, nonce)
+	if err != nil {
+		return err
+	}
+
+	der, err := sendOCSPRequest(serverURL, request)
+	if err != nil {
+		return err
+	}
+
+	if ocspResponseOutput != "" {
+		if err := os.WriteFile(ocspResponseOutput, der, 0644); err != nil {
+			return err
+		}
+	}
+
+	resp, err := ocsp.ParseResponse(der)
+	if err != nil {
+		return err
+	}
+
+	return checkOCSPResponse(resp, issuer, ids, nonce, time.Now())
+}
+
+// sendOCSPRequest posts request to serverURL, or with --get sends it base64 encoded in the path.
+func sendOCSPRequest(serverURL string, request []byte) ([]byte, error) {
+	client := &http.Client{Timeout: ocspTimeout}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	if ocspGet {
+		resp, err = client.Get(strings.TrimSuffix(serverURL, "/") + "/" + url.PathEscape(base64.StdEncoding.EncodeToString(request)))
+	} else {
+		resp, err = client.Post(serverURL, "application/ocsp-request", bytes.NewReader(request))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OCSP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP request: %s answered %s", serverURL, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// checkOCSPResponse prints resp and checks that it is a valid, current answer to the request.
+func checkOCSPResponse(resp *ocsp.Response, issuer *x509.Certificate, ids []ocsp.CertID, nonce []byte, now time.Time) error {
+	fmt.Printf("Response Status: %s\n", resp.Status)
+	if resp.Status != ocsp.Successful {
+		return fmt.Errorf("the responder answered %s", resp.Status)
+	}
+
+	signer, err := resp.Verify(issuer, now)
+	if err != nil {
+		fmt.Printf("Signature: INVALID (%s)\n", err)
+		return err
+	}
+
+	responder := "the issuer"
+	if signer != issuer {
+		responder = "delegated"
+	}
+	fmt.Printf("Responder: %s (%s)\n", signer.Subject, responder)
+	fmt.Printf("Signature: valid (%s)\n", resp.SignatureAlgorithm)
+	fmt.Printf("Produced At: %s\n", resp.ProducedAt.UTC().Format(time.RFC3339))
+
+	var problems []string
+	switch {
+	case nonce == nil:
+		fmt.Println("Nonce: not requested")
+	case resp.Nonce == nil:
+		fmt.Println("Nonce: MISSING")
+		problems = append(problems, "the response doesn't return the nonce")
+	case !bytes.Equal(resp.Nonce, nonce):
+		fmt.Println("Nonce: MISMATCH")
+		problems = append(problems, "the response returns a different nonce")
+	default:
+		fmt.Printf("Nonce: matched (%s)\n", colonHex(nonce))
+	}
+
+	notGood := 0
+	for _, id := range ids {
+		fmt.Println("")
+		fmt.Printf("Serial Number: %s\n", colonHex(id.SerialNumber.Bytes()))
+
+		single, ok := findSingleResponse(resp, id)
+		if !ok {
+			fmt.Println("Certificate Status: MISSING from the response")
+			problems = append(problems, fmt.Sprintf("no status for serial %s", ca.FormatSerial(id.SerialNumber)))
+			continue
+		}
+
+		fmt.Printf("Certificate Status: %s\n", single.Status)
+		if single.Status == ocsp.Revoked {
+			fmt.Printf("Revocation Time: %s\n", single.RevokedAt.UTC().Format(time.RFC3339))
+			fmt.Printf("Revocation Reason: %s\n", ca.ReasonName(single.Reason))
+		}
+		if single.Status != ocsp.Good {
+			notGood++
+		}
+
+		fmt.Printf("This Update: %s\n", single.ThisUpdate.UTC().Format(time.RFC3339))
+		if single.NextUpdate.IsZero() {
+			fmt.Println("Next Update: none")
+		} else {
+			fmt.Printf("Next Update: %s\n", single.NextUpdate.UTC().Format(time.RFC3339))
+		}
+
+		switch {
+		case single.ThisUpdate.After(now.Add(maxClockSkew)):
+			problems = append(problems, fmt.Sprintf("serial %s: thisUpdate is in the future", ca.FormatSerial(id.SerialNumber)))
+		case !single.NextUpdate.IsZero() && single.NextUpdate.Before(now):
+			problems = append(problems, fmt.Sprintf("serial %s: the response is stale, nextUpdate has passed", ca.FormatSerial(id.SerialNumber)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	if notGood > 0 {
+		return &exitCodeError{code: exitCertificateNotGood, err: fmt.Errorf("%d of %d certificates are not good", notGood, len(ids))}
+	}
+
+	return nil
+}
+
+// findSingleResponse returns the status of the certificate id names.
+func findSingleResponse(resp *ocsp.Response, id ocsp.CertID) (ocsp.SingleResponse, bool) {
+	for _, single := range resp.Responses {
+		if single.CertID.SerialNumber.Cmp(id.SerialNumber) == 0 &&
+			bytes.Equal(single.CertID.IssuerNameHash, id.IssuerNameHash) &&
+			bytes.Equal(single.CertID.IssuerKeyHash, id.IssuerKeyHash) {
+			return single, true
+		}
+	}
+	return ocsp.SingleResponse{}, false
+}
+
+func init() {
+	rootCmd.AddCommand(ocspCmd)
+	ocspCmd.AddCommand(ocspServeCmd, ocspQueryCmd)
+
+	ocspServeCmd.Flags().StringVar(&ocspAddr, "addr", "127.0.0.1:8888", "Address to listen on")
+	ocspServeCmd.Flags().StringVar(&ocspIssuerFile, "issuer", "", "Certificate of the CA whose certificates are answered for")
+	ocspServeCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key of the issuer, signing the responses")
+	ocspServeCmd.Flags().StringVar(&ocspResponderCert, "responder-cert", "", "Delegated responder certificate signing the responses")
+	ocspServeCmd.Flags().StringVar(&ocspResponderKey, "responder-key", "", "Private key of the delegated responder")
+	ocspServeCmd.Flags().StringVar(&ocspRevocations, "revocations", "", "Revocation file: serial, time and reason per line")
+	ocspServeCmd.Flags().StringVar(&ocspCADir, "ca-dir", "", `Answer from the database of a "ca" directory`)
+	ocspServeCmd.Flags().StringVar(&ocspThisUpdate, "this-update", "0s", "thisUpdate: offset from now, or RFC 3339 time")
+	ocspServeCmd.Flags().StringVar(&ocspNextUpdate, "next-update", "24h", "nextUpdate: offset from thisUpdate, RFC 3339 time, or none")
+	ocspServeCmd.Flags().StringVar(&hashName, "hash", "sha256", "Signature hash: sha256, sha384, sha512")
+
+	ocspQueryCmd.Flags().StringVar(&ocspIssuerFile, "issuer", "", "Certificate of the issuing CA")
+	ocspQueryCmd.Flags().StringSliceVar(&ocspCertFiles, "cert", nil, "Certificate to check (repeatable)")
+	ocspQueryCmd.Flags().StringSliceVar(&ocspSerials, "serial", nil, "Serial number to check, in hex (repeatable)")
+	ocspQueryCmd.Flags().StringVar(&ocspURL, "url", "", "Responder URL (default: from the certificate)")
+	ocspQueryCmd.Flags().BoolVar(&ocspNonce, "nonce", true, "Send a nonce and require it in the response")
+	ocspQueryCmd.Flags().StringVar(&ocspIDHash, "id-hash", "sha1", "Hash identifying the issuer: sha1, sha256, sha384, sha512")
+	ocspQueryCmd.Flags().BoolVar(&ocspGet, "get", false, "Send the request with GET instead of POST")
+	ocspQueryCmd.Flags().DurationVar(&ocspTimeout, "timeout", 10*time.Second, "Request timeout")
+	ocspQueryCmd.Flags().StringVarP(&ocspResponseOutput, "out", "o", "", "Save the DER response to this file")
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/tuanta7/keys/internal/keycrypt"
+)
+
+var (
+	passphraseEnv  string
+	passphraseFile string
+	passphraseFD   int
+
+	encryptKey    bool
+	kdfName       string
+	cipherName    string
+	kdfIterations int
+
+	// cachedPassphrase keeps a passphrase read from a non-interactive source,
+	// which can only be consumed once (e.g. a file descriptor).
+	cachedPassphrase []byte
+)
+
+// readPassphrase returns the passphrase from --passphrase-env, --passphrase-file or
+// --passphrase-fd, or prompts for it on the terminal. With confirm, a prompted
+// passphrase has to be typed twice.
+func readPassphrase(prompt string, confirm bool) ([]byte, error) {
+	if cachedPassphrase != nil {
+		return cachedPassphrase, nil
+	}
+
+	switch {
+	case passphraseEnv != "":
+		value, ok := os.LookupEnv(passphraseEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", passphraseEnv)
+		}
+		cachedPassphrase = []byte(value)
+	case passphraseFile != "":
+		data, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("read passphrase file: %w", err)
+		}
+		cachedPassphrase = firstLine(data)
+	case rootCmd.PersistentFlags().Changed("passphrase-fd"):
+		if passphraseFD < 0 {
+			return nil, fmt.Errorf("invalid file descriptor: %d", passphraseFD)
+		}
+		line, err := readLine(os.NewFile(uintptr(passphraseFD), "passphrase-fd"))
+		if err != nil {
+			return nil, fmt.Errorf("read passphrase from fd %d: %w", passphraseFD, err)
+		}
+		cachedPassphrase = line
+	default:
+		return promptPassphrase(prompt, confirm)
+	}
+
+	return cachedPassphrase, nil
+}
+
+func promptPassphrase(prompt string, confirm bool) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New("a passphrase is required: no terminal available, use --passphrase-env, --passphrase-file or --passphrase-fd")
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+		return term.ReadPassword(int(tty.Fd()))
+	}
+
+	passphrase, err := read(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+
+	if confirm {
+		again, err := read("Confirm passphrase: ")
+		if err != nil {
+			return nil, fmt.Errorf("read passphrase: %w", err)
+		}
+
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New("passphrases do not match")
+		}
+
+		if len(passphrase) == 0 {
+			return nil, errors.New("empty passphrase")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// readLine reads a single line one byte at a time, so that nothing after it is
+// consumed when the descriptor also carries other input (e.g. stdin).
+func readLine(r io.Reader) ([]byte, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+func firstLine(data []byte) []byte {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+// keyEncryptionOptions returns the options for --encrypt, or nil when the output
+// is not to be encrypted.
+func keyEncryptionOptions() *keycrypt.Options {
+	if !encryptKey {
+		return nil
+	}
+
+	return &keycrypt.Options{
+		KDF:        strings.ToLower(kdfName),
+		Cipher:     strings.ToLower(cipherName),
+		Iterations: kdfIterations,
+	}
+}
+
+func addKeyEncryptionFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&encryptKey, "encrypt", false, "Encrypt the private key with a passphrase (PKCS#8 PBES2)")
+	c.Flags().StringVar(&kdfName, "kdf", keycrypt.KDFPBKDF2, "Key derivation function: pbkdf2, scrypt")
+	c.Flags().StringVar(&cipherName, "cipher", keycrypt.CipherAES256CBC, "Cipher: aes-128-cbc, aes-256-cbc, aes-128-gcm, aes-256-gcm")
+	c.Flags().IntVar(&kdfIterations, "iterations", 0, "PBKDF2 iterations or scrypt cost N (default: 600000 or 32768)")
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&passphraseEnv, "passphrase-env", "", "Read the key passphrase from this environment variable")
+	rootCmd.PersistentFlags().StringVar(&passphraseFile, "passphrase-file", "", "Read the key passphrase from the first line of this file")
+	rootCmd.PersistentFlags().IntVar(&passphraseFD, "passphrase-fd", -1, "Read the key passphrase from this file descriptor")
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/envelope"
+	"github.com/tuanta7/keys/internal/key"
+)
+
+var (
+	recipientFiles []string
+	recipientKeyID string
+)
+
+// recipientCmd represents the recipient command
+var recipientCmd = &cobra.Command{
+	Use:   "recipient",
+	Short: "Manage the recipients of a hybrid-encrypted file",
+	Long: `List, add or remove the recipients of a file produced by "encrypt --hybrid".
+
+Each recipient entry holds the data key wrapped for one RSA public key and is identified by the
+RFC 7638 JWK thumbprint (SHA-256) of that key. Adding or removing a recipient only rewrites the
+header: the encrypted body is copied unchanged.
+
+Example:
+  rsa recipient list --in report.pdf.enc
+  rsa recipient add --key id_rsa --recipient bob.pub --in report.pdf.enc --out report.pdf.enc
+  rsa recipient remove --kid NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs --in report.pdf.enc --out report.pdf.enc`,
+}
+
+var recipientListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the recipient key IDs of a hybrid-encrypted file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, err := openInput(inputFile)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer in.Close()
+
+		header, err := envelope.ReadHeader(in)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range header.Stanzas {
+			kid := s.KeyID
+			if kid == "" {
+				kid = "(no key ID)"
+			}
+			fmt.Printf("%s\t%d-bit\n", kid, len(s.WrappedKey)*8)
+		}
+
+		return nil
+	},
+}
+
+var recipientAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Wrap the data key for additional recipients",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(recipientFiles) == 0 {
+			return errors.New("missing --recipient")
+		}
+
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		if parsed.Private == nil {
+			return errors.New("adding a recipient requires the private key of an existing recipient")
+		}
+
+		recipients, err := loadRecipients(recipientFiles)
+		if err != nil {
+			return err
+		}
+
+		kid, err := recipientID(&parsed.Private.PublicKey)
+		if err != nil {
+			return err
+		}
+
+		return rewriteEnvelope(func(h *envelope.Header) error {
+			dataKey, err := h.UnwrapKey(parsed.Private, kid)
+			if err != nil {
+				return err
+			}
+
+			for _, r := range recipients {
+				if err := h.AddRecipient(dataKey, r); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	},
+}
+
+var recipientRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove recipients by key ID or public key",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kids := make([]string, 0, len(recipientFiles)+1)
+		if recipientKeyID != "" {
+			kids = append(kids, recipientKeyID)
+		}
+
+		recipients, err := loadRecipients(recipientFiles)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range recipients {
+			kids = append(kids, r.KeyID)
+		}
+
+		if len(kids) == 0 {
+			return errors.New("missing --kid or --recipient")
+		}
+
+		return rewriteEnvelope(func(h *envelope.Header) error {
+			for _, kid := range kids {
+				if !h.RemoveRecipient(kid) {
+					return fmt.Errorf("no recipient with key ID %s", kid)
+				}
+			}
+			return nil
+		})
+	},
+}
+
+// recipientID identifies a recipient by the base64url SHA-256 JWK thumbprint of its key.
+func recipientID(publicKey *rsa.PublicKey) (string, error) {
+	thumbprint, err := key.Key{Value: publicKey}.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func loadRecipients(paths []string) ([]envelope.Recipient, error) {
+	recipients := make([]envelope.Recipient, 0, len(paths))
+	for _, path := range paths {
+		parsed, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", path, err)
+		}
+
+		kid, err := recipientID(parsed.publicKey())
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", path, err)
+		}
+
+		recipients = append(recipients, envelope.Recipient{KeyID: kid, PublicKey: parsed.publicKey()})
+	}
+
+	return recipients, nil
+}
+
+// rewriteEnvelope rewrites the header of --in into --out. When both name the same
+// file, the result goes to a temporary file which then replaces the input.
+func rewriteEnvelope(modify func(h *envelope.Header) error) error {
+	in, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	target := outputFile
+	inPlace := target != "" && target != "-" && sameFile(inputFile, target)
+	if inPlace {
+		info, err := os.Stat(outputFile)
+		if err != nil {
+			return err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(target), ".recipient-*")
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		target = tmp.Name()
+
+		// The temporary file replaces the input, so it takes over its permissions.
+		if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+			os.Remove(target)
+			return err
+		}
+	}
+
+	out, discard, err := createOutput(target, 0644)
+	if err != nil {
+		if inPlace {
+			os.Remove(target)
+		}
+		return fmt.Errorf("create output: %w", err)
+	}
+
+	if err := envelope.Rewrite(out, in, modify); err != nil {
+		discard()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		discard()
+		return err
+	}
+
+	if inPlace {
+		if err := os.Rename(target, outputFile); err != nil {
+			os.Remove(target)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameFile(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(sa, sb)
+}
+
+func init() {
+	rootCmd.AddCommand(recipientCmd)
+	recipientCmd.AddCommand(recipientListCmd, recipientAddCmd, recipientRemoveCmd)
+
+	for _, c := range []*cobra.Command{recipientListCmd, recipientAddCmd, recipientRemoveCmd} {
+		c.Flags().StringVarP(&inputFile, "in", "i", "", "Encrypted file (default: stdin)")
+	}
+
+	for _, c := range []*cobra.Command{recipientAddCmd, recipientRemoveCmd} {
+		c.Flags().StringVarP(&outputFile, "out", "o", "", "Output file, may be the same as --in (default: stdout)")
+		c.Flags().StringArrayVarP(&recipientFiles, "recipient", "r", nil, "Recipient public key file (repeatable)")
+	}
+
+	recipientAddCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key of an existing recipient")
+	recipientRemoveCmd.Flags().StringVar(&recipientKeyID, "kid", "", "Key ID of the recipient to remove")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -27,11 +28,35 @@ Examples:
 Use "rsa-tools [command] --help" for more information about a command.`,
 }
 
+// Exit codes of the verify commands: verify and cert verify.
+const (
+	exitVerificationFailed = 1 // the signature or certification path is invalid
+	exitUsage              = 2
+)
+
+// exitCodeError makes Execute exit with a specific code instead of 1.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -1,26 +1,198 @@
 package cmd
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+var (
+	signaturePadding string
+	saltLength       string
+	prehashed        bool
 )
 
 // signCmd represents the sign command
 var signCmd = &cobra.Command{
 	Use:   "sign",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("sign called")
+	Short: "Sign data with an RSA private key",
+	Long: `Create an RSASSA-PKCS1-v1_5 or RSASSA-PSS signature over a file or stdin.
+
+The input is hashed as a stream, so files of any size can be signed. With --prehashed the
+input is the digest itself (decoded with --input-encoding), e.g. the output of sha256sum.
+
+Padding schemes:
+- pkcs1v15 (default): RSASSA-PKCS1-v1_5
+- pss: RSASSA-PSS with MGF1 using the same hash. --salt-length is one of
+  auto (as large as the key allows), hash (equal to the hash length) or a number of bytes.
+
+Example:
+  rsa sign --key id_rsa --in data.tar --out data.sig
+  rsa sign --key id_rsa --padding pss --hash sha512 --in data.tar --output-encoding base64
+  sha256sum data.tar | cut -d' ' -f1 | rsa sign --key id_rsa --prehashed --input-encoding hex`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed, err := loadKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		if parsed.Private == nil {
+			return errors.New("signing requires a private key")
+		}
+
+		hash, err := signatureHash()
+		if err != nil {
+			return err
+		}
+
+		digest, err := digestInput(inputFile, hash)
+		if err != nil {
+			return err
+		}
+
+		var signature []byte
+		switch strings.ToLower(signaturePadding) {
+		case config.PaddingPKCS1v15:
+			signature, err = rsa.SignPKCS1v15(rand.Reader, parsed.Private, hash, digest)
+		case config.PaddingPSS:
+			var opts *rsa.PSSOptions
+			opts, err = pssOptions(hash, saltLength)
+			if err != nil {
+				return err
+			}
+			signature, err = rsa.SignPSS(rand.Reader, parsed.Private, hash, digest, opts)
+		default:
+			return fmt.Errorf("unsupported padding: %s", signaturePadding)
+		}
+		if err != nil {
+			return fmt.Errorf("sign: %w", err)
+		}
+
+		out, err := encodeData(signature, outputEncoding)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(outputFile, out)
 	},
 }
 
+func signatureHash() (crypto.Hash, error) {
+	hash, err := parseHash(hashName)
+	if err != nil {
+		return 0, err
+	}
+
+	switch hash {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		return hash, nil
+	default:
+		return 0, fmt.Errorf("unsupported signature hash: %s (use sha256, sha384 or sha512)", hashName)
+	}
+}
+
+func pssOptions(hash crypto.Hash, salt string) (*rsa.PSSOptions, error) {
+	opts := &rsa.PSSOptions{Hash: hash}
+
+	switch strings.ToLower(salt) {
+	case "auto":
+		opts.SaltLength = rsa.PSSSaltLengthAuto
+	case "hash":
+		opts.SaltLength = rsa.PSSSaltLengthEqualsHash
+	default:
+		n, err := strconv.Atoi(salt)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid salt length: %s (use auto, hash or a number of bytes)", salt)
+		}
+		opts.SaltLength = n
+	}
+
+	return opts, nil
+}
+
+// digestInput hashes the input as a stream or, with --prehashed, reads the digest itself.
+func digestInput(path string, hash crypto.Hash) ([]byte, error) {
+	digests, err := digestInputs(path, []crypto.Hash{hash})
+	if err != nil {
+		return nil, err
+	}
+
+	digest, ok := digests[hash]
+	if !ok {
+		return nil, fmt.Errorf("digest length does not match %s (%d bytes)", hash, hash.Size())
+	}
+
+	return digest, nil
+}
+
+// digestInputs is digestInput for several hashes in a single pass over the input.
+// With --prehashed only the hashes matching the digest length are returned.
+func digestInputs(path string, hashes []crypto.Hash) (map[crypto.Hash][]byte, error) {
+	digests := make(map[crypto.Hash][]byte, len(hashes))
+
+	if prehashed {
+		data, err := readInput(path)
+		if err != nil {
+			return nil, err
+		}
+
+		digest, err := decodeData(data, inputEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("decode digest: %w", err)
+		}
+
+		for _, hash := range hashes {
+			if len(digest) == hash.Size() {
+				digests[hash] = digest
+			}
+		}
+
+		return digests, nil
+	}
+
+	r, err := openInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer r.Close()
+
+	writers := make([]io.Writer, len(hashes))
+	hashers := make([]hash.Hash, len(hashes))
+	for i, h := range hashes {
+		hashers[i] = h.New()
+		writers[i] = hashers[i]
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+
+	for i, h := range hashes {
+		digests[h] = hashers[i].Sum(nil)
+	}
+
+	return digests, nil
+}
+
 func init() {
 	rootCmd.AddCommand(signCmd)
+	signCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key file")
+	signCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Data file (default: stdin)")
+	signCmd.Flags().StringVarP(&outputFile, "out", "o", "", "Signature file (default: stdout)")
+	signCmd.Flags().StringVarP(&outputEncoding, "output-encoding", "e", config.EncodingRaw, "Signature encoding: raw, base64, hex")
+	signCmd.Flags().StringVarP(&signaturePadding, "padding", "p", config.PaddingPKCS1v15, "Padding scheme: pkcs1v15, pss")
+	signCmd.Flags().StringVar(&hashName, "hash", "sha256", "Hash: sha256, sha384, sha512")
+	signCmd.Flags().StringVar(&saltLength, "salt-length", "hash", "PSS salt length: auto, hash or a number of bytes")
+	signCmd.Flags().BoolVar(&prehashed, "prehashed", false, "Input is an already computed digest")
+	signCmd.Flags().StringVar(&inputEncoding, "input-encoding", config.EncodingRaw, "Digest encoding with --prehashed: raw, base64, hex")
 }
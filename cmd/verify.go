@@ -1,36 +1,180 @@
 package cmd
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+var (
+	signatureFile     string
+	signatureEncoding string
+	verifySaltLength  string
+	autoDetect        bool
 )
 
 // verifyCmd represents the verify command
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("verify called")
+	Short: "Verify an RSA signature",
+	Long: `Verify an RSASSA-PKCS1-v1_5 or RSASSA-PSS signature created by the sign command
+(or any compatible implementation). The options mirror the sign command.
+
+With --auto, every supported combination (PKCS#1 v1.5 and PSS with SHA-256, SHA-384
+and SHA-512) is tried and the one that matched is reported.
+
+Exit codes:
+- 0: the signature is valid
+- 1: the signature is invalid
+- 2: usage error, or the key, data or signature could not be read
+
+Example:
+  rsa verify --key id_rsa.pub --in data.tar --sig data.sig
+  rsa verify --key id_rsa.pub --in data.tar --sig data.sig.b64 --signature-encoding base64 --padding pss
+  rsa verify --key id_rsa.pub --in data.tar --sig data.sig --auto`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := runVerify(cmd)
+		if err == nil {
+			return nil
+		}
+
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		return &exitCodeError{code: exitUsage, err: err}
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(verifyCmd)
+type signatureScheme struct {
+	padding string
+	hash    crypto.Hash
+}
+
+func (s signatureScheme) String() string {
+	if s.padding == config.PaddingPSS {
+		return fmt.Sprintf("RSASSA-PSS with %s", s.hash)
+	}
+	return fmt.Sprintf("RSASSA-PKCS1-v1_5 with %s", s.hash)
+}
+
+func runVerify(cmd *cobra.Command) error {
+	if signatureFile == "" {
+		return errors.New("missing --sig")
+	}
+
+	parsed, err := loadKey(keyFile)
+	if err != nil {
+		return err
+	}
+	publicKey := parsed.publicKey()
+
+	data, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return fmt.Errorf("read signature: %w", err)
+	}
 
-	// Here you will define your flags and configuration settings.
+	signature, err := decodeData(data, signatureEncoding)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// verifyCmd.PersistentFlags().String("foo", "", "A help for foo")
+	var schemes []signatureScheme
+	if autoDetect {
+		for _, padding := range []string{config.PaddingPKCS1v15, config.PaddingPSS} {
+			for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+				schemes = append(schemes, signatureScheme{padding: padding, hash: hash})
+			}
+		}
+	} else {
+		hash, err := signatureHash()
+		if err != nil {
+			return err
+		}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// verifyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+		padding := strings.ToLower(signaturePadding)
+		if padding != config.PaddingPKCS1v15 && padding != config.PaddingPSS {
+			return fmt.Errorf("unsupported padding: %s", signaturePadding)
+		}
+
+		schemes = append(schemes, signatureScheme{padding: padding, hash: hash})
+	}
+
+	// Each hash is computed once, however many schemes use it.
+	var hashes []crypto.Hash
+	for _, s := range schemes {
+		if !slices.Contains(hashes, s.hash) {
+			hashes = append(hashes, s.hash)
+		}
+	}
+
+	digests, err := digestInputs(inputFile, hashes)
+	if err != nil {
+		return err
+	}
+
+	if len(digests) == 0 {
+		return errors.New("digest length does not match any supported hash")
+	}
+
+	for _, s := range schemes {
+		digest, ok := digests[s.hash]
+		if !ok {
+			continue
+		}
+
+		if err := verifySignature(publicKey, s, digest, signature); err == nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Signature valid (%s)\n", s)
+			return nil
+		} else if !errors.Is(err, rsa.ErrVerification) {
+			return err
+		}
+	}
+
+	return &exitCodeError{code: exitVerificationFailed, err: errors.New("signature invalid")}
+}
+
+func verifySignature(publicKey *rsa.PublicKey, s signatureScheme, digest, signature []byte) error {
+	if s.padding == config.PaddingPKCS1v15 {
+		return rsa.VerifyPKCS1v15(publicKey, s.hash, digest, signature)
+	}
+
+	salt := verifySaltLength
+	if autoDetect {
+		salt = "auto"
+	}
+
+	opts, err := pssOptions(s.hash, salt)
+	if err != nil {
+		return err
+	}
+
+	return rsa.VerifyPSS(publicKey, s.hash, digest, signature, opts)
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &exitCodeError{code: exitUsage, err: err}
+	})
+	verifyCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Public (or private) key file")
+	verifyCmd.Flags().StringVarP(&inputFile, "in", "i", "", "Data file (default: stdin)")
+	verifyCmd.Flags().StringVarP(&signatureFile, "sig", "s", "", "Signature file")
+	verifyCmd.Flags().StringVarP(&signatureEncoding, "signature-encoding", "e", config.EncodingRaw, "Signature encoding: raw, base64, hex")
+	verifyCmd.Flags().StringVarP(&signaturePadding, "padding", "p", config.PaddingPKCS1v15, "Padding scheme: pkcs1v15, pss")
+	verifyCmd.Flags().StringVar(&hashName, "hash", "sha256", "Hash: sha256, sha384, sha512")
+	verifyCmd.Flags().StringVar(&verifySaltLength, "salt-length", "auto", "PSS salt length: auto, hash or a number of bytes")
+	verifyCmd.Flags().BoolVar(&prehashed, "prehashed", false, "Input is an already computed digest")
+	verifyCmd.Flags().StringVar(&inputEncoding, "input-encoding", config.EncodingRaw, "Digest encoding with --prehashed: raw, base64, hex")
+	verifyCmd.Flags().BoolVar(&autoDetect, "auto", false, "Try every padding and hash combination and report the match")
 }
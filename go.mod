@@ -2,7 +2,12 @@ module github.com/tuanta7/keys
 
 go 1.24.5
 
-require github.com/spf13/cobra v1.9.1
+require (
+	github.com/spf13/cobra v1.9.1
+	golang.org/x/crypto v0.45.0
+	golang.org/x/sys v0.38.0
+	golang.org/x/term v0.37.0
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
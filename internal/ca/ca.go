@@ -0,0 +1,401 @@
+// Package ca implements a certificate authority that lives in a directory:
+// the CA certificates and keys, every issued certificate, and a JSON database
+// of serial numbers and revocations. Changes to the database are serialized
+// with a lock file, so that parallel processes can share a CA.
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tuanta7/keys/internal/certificate"
+	"github.com/tuanta7/keys/internal/config"
+)
+
+const (
+	rootCertFile         = "root.crt"
+	rootKeyFile          = "root.key"
+	intermediateCertFile = "intermediate.crt"
+	intermediateKeyFile  = "intermediate.key"
+	chainFile            = "chain.pem"
+	databaseFile         = "database.json"
+	lockFileName         = "database.lock"
+	certsDir             = "certs"
+)
+
+var (
+	ErrExists        = errors.New("directory already holds a CA")
+	ErrUnknownSerial = errors.New("no certificate with serial number")
+	ErrRevoked       = errors.New("certificate already revoked")
+)
+
+// CA is a CA directory opened for issuing. Certificate and Key belong to the
+// issuing CA: the intermediate CA when there is one, the root CA otherwise.
+type CA struct {
+	Dir         string
+	Certificate *x509.Certificate
+	Key         *rsa.PrivateKey
+
+	// Chain lists the CA certificates from the issuing CA to the root.
+	Chain []*x509.Certificate
+}
+
+// InitOptions describes the CA hierarchy Init creates.
+type InitOptions struct {
+	Bits         int
+	Root         certificate.Options
+	Intermediate *certificate.Options // optional
+
+	CRLURLs  []string
+	OCSPURLs []string
+}
+
+// Init creates a root CA, and an intermediate CA signed by it when
+// opts.Intermediate is set, in dir. It refuses to overwrite an existing CA.
+func Init(dir string, opts InitOptions) error {
+	if err := os.MkdirAll(filepath.Join(dir, certsDir), 0700); err != nil {
+		return err
+	}
+
+	unlock, err := lock(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(filepath.Join(dir, databaseFile)); err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, dir)
+	}
+
+	rootKey, err := rsa.GenerateKey(rand.Reader, opts.Bits)
+	if err != nil {
+		return fmt.Errorf("generate root key: %w", err)
+	}
+
+	opts.Root.IsCA = true
+	root, err := certificate.SelfSign(rootKey, opts.Root)
+	if err != nil {
+		return fmt.Errorf("root certificate: %w", err)
+	}
+
+	if err := writeKeyPair(dir, rootCertFile, rootKeyFile, root, rootKey); err != nil {
+		return err
+	}
+	chain := [][]byte{root}
+
+	if opts.Intermediate != nil {
+		rootCert, err := x509.ParseCertificate(root)
+		if err != nil {
+			return err
+		}
+
+		intermediateKey, err := rsa.GenerateKey(rand.Reader, opts.Bits)
+		if err != nil {
+			return fmt.Errorf("generate intermediate key: %w", err)
+		}
+
+		opts.Intermediate.IsCA = true
+		intermediate, err := sign(*opts.Intermediate, &intermediateKey.PublicKey, rootCert, rootKey)
+		if err != nil {
+			return fmt.Errorf("intermediate certificate: %w", err)
+		}
+
+		if err := writeKeyPair(dir, intermediateCertFile, intermediateKeyFile, intermediate, intermediateKey); err != nil {
+			return err
+		}
+		chain = [][]byte{intermediate, root}
+	}
+
+	var pemChain []byte
+	for _, der := range chain {
+		pemChain = append(pemChain, pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: der})...)
+	}
+	if err := os.WriteFile(filepath.Join(dir, chainFile), pemChain, 0644); err != nil {
+		return err
+	}
+
+	first, err := certificate.SerialNumber()
+	if err != nil {
+		return err
+	}
+
+	return writeDatabase(dir, &Database{
+		NextSerial:   FormatSerial(first.Rsh(first, 64)), // 63 bits, room to count up
+		CRLURLs:      opts.CRLURLs,
+		OCSPURLs:     opts.OCSPURLs,
+		Certificates: []Record{},
+	})
+}
+
+// Open loads the issuing CA of dir.
+func Open(dir string) (*CA, error) {
+	if _, err := readDatabase(dir); err != nil {
+		return nil, err
+	}
+
+	root, _, err := readKeyPair(dir, rootCertFile, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, intermediateCertFile)); err == nil {
+		intermediate, key, err := readKeyPair(dir, intermediateCertFile, intermediateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &CA{Dir: dir, Certificate: intermediate, Key: key, Chain: []*x509.Certificate{intermediate, root}}, nil
+	}
+
+	_, key, err := readKeyPair(dir, rootCertFile, rootKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{Dir: dir, Certificate: root, Key: key, Chain: []*x509.Certificate{root}}, nil
+}
+
+// Issue signs a certificate for pub with the given profile, assigning it the
+// next serial number, and stores it in the certs directory.
+func (c *CA) Issue(opts certificate.Options, pub *rsa.PublicKey, profile Profile) (*x509.Certificate, error) {
+	opts.KeyUsage = profile.KeyUsage
+	opts.ExtKeyUsage = profile.ExtKeyUsage
+	opts.IsCA = false
+	opts.MaxPathLen = -1
+
+	if opts.NotAfter.After(c.Certificate.NotAfter) {
+		return nil, fmt.Errorf("the certificate would outlive the issuing CA, which expires %s", c.Certificate.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	var cert *x509.Certificate
+	err := c.update(func(db *Database) error {
+		serial, err := db.allocateSerial()
+		if err != nil {
+			return err
+		}
+
+		opts.SerialNumber = serial
+		opts.CRLDistributionPoints = db.CRLURLs
+		opts.OCSPServers = db.OCSPURLs
+
+		der, err := sign(opts, pub, c.Certificate, c.Key)
+		if err != nil {
+			return err
+		}
+
+		if cert, err = x509.ParseCertificate(der); err != nil {
+			return err
+		}
+
+		file := filepath.Join(certsDir, FormatSerial(serial)+".crt")
+		if err := os.WriteFile(filepath.Join(c.Dir, file), pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: der}), 0644); err != nil {
+			return err
+		}
+
+		db.Certificates = append(db.Certificates, Record{
+			Serial:    FormatSerial(serial),
+			Subject:   cert.Subject.String(),
+			Profile:   profile.Name,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			File:      filepath.ToSlash(file),
+		})
+		return nil
+	})
+
+	return cert, err
+}
+
+// Revoke records the revocation of the certificate with the given serial number.
+func (c *CA) Revoke(serial *big.Int, reason string, at time.Time) error {
+	if _, err := ParseReason(reason); err != nil {
+		return err
+	}
+
+	return c.update(func(db *Database) error {
+		record, err := db.lookup(serial)
+		if err != nil {
+			return err
+		}
+
+		if record.Revoked() {
+			return fmt.Errorf("%w: %s on %s", ErrRevoked, record.Serial, record.RevokedAt.Format(time.RFC3339))
+		}
+
+		at = at.UTC().Truncate(time.Second)
+		record.RevokedAt = &at
+		record.Reason = reason
+		return nil
+	})
+}
+
+// CRL returns a DER CRL of every revoked certificate, signed by the issuing
+// CA, with the next CRL number.
+func (c *CA) CRL(thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	var crl []byte
+	err := c.update(func(db *Database) error {
+		db.CRLNumber++
+
+		template := &x509.RevocationList{
+			Number:     big.NewInt(db.CRLNumber),
+			ThisUpdate: thisUpdate,
+			NextUpdate: nextUpdate,
+		}
+
+		for _, record := range db.Certificates {
+			if !record.Revoked() {
+				continue
+			}
+
+			serial, err := record.SerialNumber()
+			if err != nil {
+				return err
+			}
+
+			template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+				SerialNumber:   serial,
+				RevocationTime: *record.RevokedAt,
+				ReasonCode:     ReasonCode(record.Reason),
+			})
+		}
+
+		var err error
+		crl, err = x509.CreateRevocationList(rand.Reader, template, c.Certificate, c.Key)
+		if err != nil {
+			return fmt.Errorf("create CRL: %w", err)
+		}
+		return nil
+	})
+
+	return crl, err
+}
+
+// Records returns the issued certificates.
+func (c *CA) Records() ([]Record, error) {
+	db, err := readDatabase(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return db.Certificates, nil
+}
+
+// update runs fn on the database while holding the lock, and saves the
+// database when fn succeeds.
+func (c *CA) update(fn func(db *Database) error) error {
+	unlock, err := lock(c.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := readDatabase(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(db); err != nil {
+		return err
+	}
+
+	return writeDatabase(c.Dir, db)
+}
+
+// lock takes the lock of the CA directory.
+func lock(dir string) (unlock func(), err error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", f.Name(), err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func sign(opts certificate.Options, pub *rsa.PublicKey, issuer *x509.Certificate, issuerKey *rsa.PrivateKey) ([]byte, error) {
+	template, err := certificate.Template(opts, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, pub, issuerKey)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	return der, nil
+}
+
+func writeKeyPair(dir, certFile, keyFile string, der []byte, key *rsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, keyFile), pem.EncodeToMemory(&pem.Block{Type: config.KeyTypePrivateKey, Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, certFile), pem.EncodeToMemory(&pem.Block{Type: config.PEMTypeCertificate, Bytes: der}), 0644)
+}
+
+// readKeyPair reads a certificate and, unless keyFile is empty, its key.
+func readKeyPair(dir, certFile, keyFile string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	cert, err := readPEM(filepath.Join(dir, certFile), config.PEMTypeCertificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", certFile, err)
+	}
+
+	if keyFile == "" {
+		return parsed, nil, nil
+	}
+
+	der, err := readPEM(filepath.Join(dir, keyFile), config.KeyTypePrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", keyFile, err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok || !rsaKey.PublicKey.Equal(parsed.PublicKey) {
+		return nil, nil, fmt.Errorf("%s doesn't hold the key of %s", keyFile, certFile)
+	}
+
+	return parsed, rsaKey, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: expected a PEM %q block", path, blockType)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,329 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/keys/internal/certificate"
+)
+
+func caOptions(cn string, days int) certificate.Options {
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	return certificate.Options{
+		Subject:    pkix.Name{CommonName: cn},
+		NotBefore:  notBefore,
+		NotAfter:   notBefore.AddDate(0, 0, days),
+		MaxPathLen: -1,
+	}
+}
+
+func leafOptions(cn string) certificate.Options {
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	return certificate.Options{
+		Subject:   pkix.Name{CommonName: cn},
+		DNSNames:  []string{cn},
+		NotBefore: notBefore,
+		NotAfter:  notBefore.AddDate(0, 0, 30),
+	}
+}
+
+// newCA creates a CA with an intermediate in a temporary directory.
+func newCA(t *testing.T) *CA {
+	t.Helper()
+	dir := t.TempDir()
+	intermediate := caOptions("Test Issuing CA", 365)
+	err := Init(dir, InitOptions{
+		Bits:         2048,
+		Root:         caOptions("Test Root CA", 3650),
+		Intermediate: &intermediate,
+		CRLURLs:      []string{"http://127.0.0.1:8080/ca.crl"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authority, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority
+}
+
+func TestInit(t *testing.T) {
+	authority := newCA(t)
+
+	if len(authority.Chain) != 2 || authority.Certificate.Subject.CommonName != "Test Issuing CA" {
+		t.Fatalf("got chain %v", authority.Chain)
+	}
+	if err := authority.Certificate.CheckSignatureFrom(authority.Chain[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{rootKeyFile, intermediateKeyFile} {
+		info, err := os.Stat(filepath.Join(authority.Dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			t.Errorf("%s has mode %o", name, perm)
+		}
+	}
+
+	err := Init(authority.Dir, InitOptions{Bits: 2048, Root: caOptions("Other Root CA", 3650)})
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("got %v, want %v", err, ErrExists)
+	}
+
+	again, err := Open(authority.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Chain[1].Equal(authority.Chain[1]) {
+		t.Fatal("Init replaced the root CA")
+	}
+}
+
+func TestOpenNotCA(t *testing.T) {
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Fatal("opened an empty directory")
+	}
+}
+
+// TestIssueConcurrent issues certificates from goroutines that each open the
+// CA, as parallel processes would; the lock file keeps the serials unique.
+func TestIssueConcurrent(t *testing.T) {
+	authority := newCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LookupProfile(ProfileServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, perWorker = 8, 5
+	serials := make(chan *big.Int, workers*perWorker)
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := Open(authority.Dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for range perWorker {
+				cert, err := c.Issue(leafOptions("api.example.com"), &key.PublicKey, profile)
+				if err != nil {
+					errs <- err
+					return
+				}
+				serials <- cert.SerialNumber
+			}
+		}()
+	}
+	wg.Wait()
+	close(serials)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for serial := range serials {
+		s := FormatSerial(serial)
+		if seen[s] {
+			t.Fatalf("serial %s issued twice", s)
+		}
+		seen[s] = true
+	}
+
+	records, err := authority.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != workers*perWorker || len(records) != workers*perWorker {
+		t.Fatalf("issued %d certificates, recorded %d, want %d", len(seen), len(records), workers*perWorker)
+	}
+	for _, r := range records {
+		if !seen[r.Serial] {
+			t.Fatalf("recorded serial %s wasn't issued", r.Serial)
+		}
+		if _, err := os.Stat(filepath.Join(authority.Dir, r.File)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIssue(t *testing.T) {
+	authority := newCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LookupProfile(ProfileClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := authority.Issue(leafOptions("alice"), &key.PublicKey, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Chain[1])
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(authority.Chain[0])
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatal(err)
+	}
+	if cert.IsCA || len(cert.CRLDistributionPoints) != 1 {
+		t.Fatalf("got CA %v, CRL distribution points %v", cert.IsCA, cert.CRLDistributionPoints)
+	}
+
+	opts := leafOptions("bob")
+	opts.NotAfter = authority.Certificate.NotAfter.Add(time.Hour)
+	if _, err := authority.Issue(opts, &key.PublicKey, profile); err == nil {
+		t.Fatal("issued a certificate outliving the CA")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	authority := newCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LookupProfile(ProfileServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := authority.Issue(leafOptions("api.example.com"), &key.PublicKey, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := authority.Revoke(big.NewInt(1), "keyCompromise", time.Now()); !errors.Is(err, ErrUnknownSerial) {
+		t.Fatalf("unknown serial: got %v, want %v", err, ErrUnknownSerial)
+	}
+	if err := authority.Revoke(cert.SerialNumber, "stolen", time.Now()); err == nil {
+		t.Fatal("accepted an unknown reason")
+	}
+	if err := authority.Revoke(cert.SerialNumber, "keyCompromise", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := authority.Revoke(cert.SerialNumber, "superseded", time.Now()); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("revoked twice: got %v, want %v", err, ErrRevoked)
+	}
+
+	records, err := authority.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !records[0].Revoked() || records[0].Reason != "keyCompromise" {
+		t.Fatalf("got %+v", records[0])
+	}
+}
+
+func TestCRL(t *testing.T) {
+	authority := newCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LookupProfile(ProfileServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := []string{"keyCompromise", "superseded", "cessationOfOperation", "unspecified", ""}
+	want := map[string]int{}
+	for i, reason := range reasons {
+		cert, err := authority.Issue(leafOptions("api.example.com"), &key.PublicKey, profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason == "" {
+			continue // left valid
+		}
+		if err := authority.Revoke(cert.SerialNumber, reason, time.Now().Add(-time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		want[FormatSerial(cert.SerialNumber)] = ReasonCode(reason)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for number := int64(1); number <= 3; number++ {
+		der, err := authority.CRL(now, now.Add(24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crl.CheckSignatureFrom(authority.Certificate); err != nil {
+			t.Fatal(err)
+		}
+		if crl.Number.Int64() != number {
+			t.Fatalf("got CRL number %d, want %d", crl.Number, number)
+		}
+
+		if len(crl.RevokedCertificateEntries) != len(want) {
+			t.Fatalf("got %d entries, want %d", len(crl.RevokedCertificateEntries), len(want))
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			code, ok := want[FormatSerial(entry.SerialNumber)]
+			if !ok || entry.ReasonCode != code {
+				t.Fatalf("serial %s: got reason %d, want %d", FormatSerial(entry.SerialNumber), entry.ReasonCode, code)
+			}
+		}
+	}
+}
+
+func TestParseSerial(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"4704A96F31A6B25E", "4704A96F31A6B25E"},
+		{"47:04:a9:6f:31:a6:b2:5e", "4704A96F31A6B25E"},
+		{"0x4704a96f31a6b25e", "4704A96F31A6B25E"},
+		{"0X01", "1"},
+		{"00:01", "1"},
+	}
+
+	for _, tt := range tests {
+		serial, err := ParseSerial(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got := FormatSerial(serial); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "0", "00:00", "-1", "0x", "xyz", "12 34", "0x0x12"} {
+		if serial, err := ParseSerial(in); err == nil {
+			t.Errorf("%q: got %s", in, serial)
+		}
+	}
+}
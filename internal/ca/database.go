@@ -0,0 +1,141 @@
+package ca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Database is the issuance database of a CA directory, database.json.
+type Database struct {
+	// NextSerial is the serial number of the next certificate, in hex.
+	NextSerial string `json:"next_serial"`
+	CRLNumber  int64  `json:"crl_number"`
+
+	// CRLURLs and OCSPURLs are written into every issued certificate.
+	CRLURLs  []string `json:"crl_urls,omitempty"`
+	OCSPURLs []string `json:"ocsp_urls,omitempty"`
+
+	Certificates []Record `json:"certificates"`
+}
+
+// Record describes an issued certificate.
+type Record struct {
+	Serial    string    `json:"serial"` // hex
+	Subject   string    `json:"subject"`
+	Profile   string    `json:"profile"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	File      string    `json:"file"` // relative to the CA directory
+
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Reason    string     `json:"revocation_reason,omitempty"`
+}
+
+// SerialNumber returns the serial number of the record.
+func (r Record) SerialNumber() (*big.Int, error) {
+	return ParseSerial(r.Serial)
+}
+
+// Revoked reports whether the certificate has been revoked.
+func (r Record) Revoked() bool {
+	return r.RevokedAt != nil
+}
+
+// ParseSerial reads a hex serial number, with or without colons, as printed by
+// inspect and OpenSSL.
+func ParseSerial(s string) (*big.Int, error) {
+	hex := strings.TrimPrefix(strings.ToLower(strings.ReplaceAll(s, ":", "")), "0x")
+	serial, ok := new(big.Int).SetString(hex, 16)
+	if !ok || serial.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q: expected positive hex", s)
+	}
+	return serial, nil
+}
+
+// FormatSerial writes a serial number the way the database stores it.
+func FormatSerial(serial *big.Int) string {
+	return fmt.Sprintf("%X", serial)
+}
+
+// lookup returns the record of serial.
+func (db *Database) lookup(serial *big.Int) (*Record, error) {
+	for i := range db.Certificates {
+		s, err := db.Certificates[i].SerialNumber()
+		if err != nil {
+			return nil, err
+		}
+		if s.Cmp(serial) == 0 {
+			return &db.Certificates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSerial, FormatSerial(serial))
+}
+
+// allocateSerial returns the next serial number and advances the counter.
+func (db *Database) allocateSerial() (*big.Int, error) {
+	serial, err := ParseSerial(db.NextSerial)
+	if err != nil {
+		return nil, fmt.Errorf("database: %w", err)
+	}
+
+	db.NextSerial = FormatSerial(new(big.Int).Add(serial, big.NewInt(1)))
+	return serial, nil
+}
+
+func readDatabase(dir string) (*Database, error) {
+	data, err := os.ReadFile(filepath.Join(dir, databaseFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s is not a CA directory (no %s), run \"ca init\" first", dir, databaseFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var db Database
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", databaseFile, err)
+	}
+
+	return &db, nil
+}
+
+func writeDatabase(dir string, db *Database) error {
+	data, err := json.MarshalIndent(db, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, databaseFile), append(data, '\n'), 0644)
+}
+
+// writeFileAtomic replaces path in one step, so that a reader never sees a
+// partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
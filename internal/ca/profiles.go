@@ -0,0 +1,101 @@
+package ca
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// Profile is the kind of end-entity certificate a CA issues.
+type Profile struct {
+	Name        string
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+const (
+	ProfileServer      = "server"
+	ProfileClient      = "client"
+	ProfileCodeSigning = "code-signing"
+	ProfileOCSPSigning = "ocsp-signing"
+)
+
+var profiles = []Profile{
+	{ProfileServer, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+	{ProfileClient, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+	{ProfileCodeSigning, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}},
+	{ProfileOCSPSigning, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}},
+}
+
+// ProfileNames lists the profiles LookupProfile knows.
+func ProfileNames() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// LookupProfile returns the profile with the given name.
+func LookupProfile(name string) (Profile, error) {
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown profile: %s (supported: %s)", name, strings.Join(ProfileNames(), ", "))
+}
+
+// reasons are the CRLReason codes of RFC 5280, section 5.3.1, under the names
+// OpenSSL uses. removeFromCRL (8) only appears in delta CRLs.
+var reasons = []struct {
+	name string
+	code int
+}{
+	{"unspecified", 0},
+	{"keyCompromise", 1},
+	{"CACompromise", 2},
+	{"affiliationChanged", 3},
+	{"superseded", 4},
+	{"cessationOfOperation", 5},
+	{"certificateHold", 6},
+	{"privilegeWithdrawn", 9},
+	{"AACompromise", 10},
+}
+
+// ReasonNames lists the revocation reasons ParseReason accepts.
+func ReasonNames() []string {
+	names := make([]string, len(reasons))
+	for i, r := range reasons {
+		names[i] = r.name
+	}
+	return names
+}
+
+// ParseReason returns the CRLReason code of a revocation reason name.
+func ParseReason(name string) (int, error) {
+	for _, r := range reasons {
+		if strings.EqualFold(r.name, name) {
+			return r.code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revocation reason: %s (supported: %s)", name, strings.Join(ReasonNames(), ", "))
+}
+
+// ReasonCode is ParseReason for names already validated, such as those in the
+// database; unknown names are unspecified.
+func ReasonCode(name string) int {
+	code, _ := ParseReason(name)
+	return code
+}
+
+// ReasonName returns the name of a CRLReason code, or the code itself when it
+// is not known.
+func ReasonName(code int) string {
+	for _, r := range reasons {
+		if r.code == code {
+			return r.name
+		}
+	}
+	return fmt.Sprintf("reason %d", code)
+}
@@ -0,0 +1,173 @@
+// Package certificate builds X.509 certificates for RSA keys.
+package certificate
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/tuanta7/keys/internal/fingerprint"
+)
+
+// Options describes the certificate to create.
+type Options struct {
+	Subject        pkix.Name
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// KeyUsage defaults to digitalSignature and keyEncipherment, or to
+	// keyCertSign, cRLSign and digitalSignature for a CA.
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+
+	// IsCA sets the cA flag of the basic constraints. MaxPathLen limits the
+	// number of intermediate CAs below it; -1 means no limit.
+	IsCA       bool
+	MaxPathLen int
+
+	// CRLDistributionPoints and OCSPServers tell relying parties where to
+	// check the revocation status of the certificate.
+	CRLDistributionPoints []string
+	OCSPServers           []string
+
+	SignatureAlgorithm x509.SignatureAlgorithm
+
+	// SerialNumber is random when nil.
+	SerialNumber *big.Int
+}
+
+// Template returns the certificate template for pub. The subject key
+// identifier is the SHA-1 of the public key (RFC 5280, section 4.2.1.2).
+func Template(opts Options, pub *rsa.PublicKey) (*x509.Certificate, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	serial := opts.SerialNumber
+	if serial == nil {
+		var err error
+		if serial, err = SerialNumber(); err != nil {
+			return nil, err
+		}
+	}
+
+	usage := opts.KeyUsage
+	if usage == 0 {
+		usage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+		if opts.IsCA {
+			usage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+		}
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               opts.Subject,
+		DNSNames:              opts.DNSNames,
+		IPAddresses:           opts.IPAddresses,
+		EmailAddresses:        opts.EmailAddresses,
+		URIs:                  opts.URIs,
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotAfter,
+		KeyUsage:              usage,
+		ExtKeyUsage:           opts.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  opts.IsCA,
+		SubjectKeyId:          fingerprint.SubjectKeyID(pub),
+		CRLDistributionPoints: opts.CRLDistributionPoints,
+		OCSPServer:            opts.OCSPServers,
+		SignatureAlgorithm:    opts.SignatureAlgorithm,
+	}
+
+	switch {
+	case opts.MaxPathLen == 0:
+		template.MaxPathLenZero = true
+	case opts.MaxPathLen > 0:
+		template.MaxPathLen = opts.MaxPathLen
+	default:
+		template.MaxPathLen = -1
+	}
+
+	return template, nil
+}
+
+// Validate reports options no certificate can be created from.
+func (opts Options) Validate() error {
+	if err := opts.validateNames(); err != nil {
+		return err
+	}
+
+	if !opts.NotAfter.After(opts.NotBefore) {
+		return fmt.Errorf("validity ends (%s) before it starts (%s)", opts.NotAfter.Format(time.RFC3339), opts.NotBefore.Format(time.RFC3339))
+	}
+
+	if opts.MaxPathLen >= 0 && !opts.IsCA {
+		return errors.New("a path length constraint is only allowed on CA certificates")
+	}
+
+	return nil
+}
+
+func (opts Options) validateNames() error {
+	if len(opts.Subject.ToRDNSequence()) == 0 && len(opts.DNSNames)+len(opts.IPAddresses)+len(opts.EmailAddresses)+len(opts.URIs) == 0 {
+		return errors.New("a certificate needs a subject or a subject alternative name")
+	}
+	return nil
+}
+
+// SelfSign creates a certificate for key signed by key itself and returns its DER encoding.
+func SelfSign(key *rsa.PrivateKey, opts Options) ([]byte, error) {
+	template, err := Template(opts, &key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	return der, nil
+}
+
+// SerialNumber returns a random positive 127-bit serial number, within the
+// 20 octets RFC 5280 allows.
+func SerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	return serial.SetBit(serial, 126, 1), nil
+}
+
+// SignatureAlgorithm returns the RSA signature algorithm for hash, PKCS #1 v1.5 or PSS.
+func SignatureAlgorithm(hash crypto.Hash, pss bool) (x509.SignatureAlgorithm, error) {
+	switch {
+	case hash == crypto.SHA256 && pss:
+		return x509.SHA256WithRSAPSS, nil
+	case hash == crypto.SHA384 && pss:
+		return x509.SHA384WithRSAPSS, nil
+	case hash == crypto.SHA512 && pss:
+		return x509.SHA512WithRSAPSS, nil
+	case hash == crypto.SHA256:
+		return x509.SHA256WithRSA, nil
+	case hash == crypto.SHA384:
+		return x509.SHA384WithRSA, nil
+	case hash == crypto.SHA512:
+		return x509.SHA512WithRSA, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported certificate signature hash: %s (use sha256, sha384 or sha512)", hash)
+	}
+}
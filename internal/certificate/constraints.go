@@ -0,0 +1,126 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// checkNameConstraints reports the first subject alternative name of cert that
+// violates the name constraints of ca (RFC 5280, section 4.2.1.10): a name
+// matching an excluded subtree, or not matching any permitted subtree of its
+// type when there are some.
+func checkNameConstraints(cert, ca *x509.Certificate) error {
+	for _, name := range cert.DNSNames {
+		if err := checkName("DNS", name, ca.PermittedDNSDomains, ca.ExcludedDNSDomains, matchDomain); err != nil {
+			return err
+		}
+	}
+
+	for _, email := range cert.EmailAddresses {
+		if err := checkName("email", email, ca.PermittedEmailAddresses, ca.ExcludedEmailAddresses, matchEmail); err != nil {
+			return err
+		}
+	}
+
+	for _, uri := range cert.URIs {
+		host := uri.Hostname()
+		if (len(ca.PermittedURIDomains) > 0 || len(ca.ExcludedURIDomains) > 0) && (host == "" || net.ParseIP(host) != nil) {
+			return fmt.Errorf("URI:%s has no domain name to check against the constraints of %s", uri, ca.Subject)
+		}
+
+		if err := checkName("URI", host, ca.PermittedURIDomains, ca.ExcludedURIDomains, matchHost); err != nil {
+			return err
+		}
+	}
+
+	for _, ip := range cert.IPAddresses {
+		for _, excluded := range ca.ExcludedIPRanges {
+			if matchIP(ip, excluded) {
+				return fmt.Errorf("IP:%s is in the excluded range %s of %s", ip, excluded, ca.Subject)
+			}
+		}
+
+		if len(ca.PermittedIPRanges) == 0 {
+			continue
+		}
+
+		permitted := false
+		for _, r := range ca.PermittedIPRanges {
+			permitted = permitted || matchIP(ip, r)
+		}
+		if !permitted {
+			return fmt.Errorf("IP:%s is outside the permitted ranges of %s", ip, ca.Subject)
+		}
+	}
+
+	return nil
+}
+
+func checkName(kind, name string, permitted, excluded []string, match func(name, constraint string) bool) error {
+	for _, constraint := range excluded {
+		if match(name, constraint) {
+			return fmt.Errorf("%s:%s is in the excluded subtree %q", kind, name, constraint)
+		}
+	}
+
+	if len(permitted) == 0 {
+		return nil
+	}
+
+	for _, constraint := range permitted {
+		if match(name, constraint) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s:%s is outside the permitted subtrees %s", kind, name, strings.Join(permitted, ", "))
+}
+
+// matchDomain matches a DNS name against a dNSName constraint: the domain
+// itself and its subdomains, or only the subdomains when the constraint
+// starts with a period.
+func matchDomain(name, constraint string) bool {
+	name, constraint = strings.ToLower(name), strings.ToLower(constraint)
+	switch {
+	case constraint == "":
+		return true
+	case strings.HasPrefix(constraint, "."):
+		return strings.HasSuffix(name, constraint)
+	default:
+		return name == constraint || strings.HasSuffix(name, "."+constraint)
+	}
+}
+
+// matchHost matches a host against a constraint of the form used for email
+// domains and URIs: the host itself, or with a leading period any host below it.
+func matchHost(host, constraint string) bool {
+	host, constraint = strings.ToLower(host), strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint
+}
+
+// matchEmail matches an email address against an rfc822Name constraint: a
+// complete mailbox, or a host as in matchHost.
+func matchEmail(email, constraint string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(email, constraint)
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	return matchHost(email[at+1:], constraint)
+}
+
+// matchIP reports whether ip lies in r, comparing IPv4 with IPv4 and IPv6 with IPv6.
+func matchIP(ip net.IP, r *net.IPNet) bool {
+	if len(r.IP) == net.IPv4len {
+		ip = ip.To4()
+	}
+	return ip != nil && len(ip) == len(r.IP) && r.Contains(ip)
+}
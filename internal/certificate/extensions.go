@@ -0,0 +1,186 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Extension identifiers of RFC 5280, section 4.2, and of the CT precertificate SCT list.
+var (
+	OIDSubjectAltName    = asn1.ObjectIdentifier{2, 5, 29, 17}
+	OIDSubjectKeyID      = asn1.ObjectIdentifier{2, 5, 29, 14}
+	OIDKeyUsage          = asn1.ObjectIdentifier{2, 5, 29, 15}
+	OIDBasicConstraints  = asn1.ObjectIdentifier{2, 5, 29, 19}
+	OIDNameConstraints   = asn1.ObjectIdentifier{2, 5, 29, 30}
+	OIDCRLDistribution   = asn1.ObjectIdentifier{2, 5, 29, 31}
+	OIDCertPolicies      = asn1.ObjectIdentifier{2, 5, 29, 32}
+	OIDAuthorityKeyID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+	OIDExtKeyUsage       = asn1.ObjectIdentifier{2, 5, 29, 37}
+	OIDAuthorityInfo     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	OIDCTPrecertificates = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+var extensionNames = []struct {
+	oid  asn1.ObjectIdentifier
+	name string
+}{
+	{OIDSubjectKeyID, "Subject Key Identifier"},
+	{OIDKeyUsage, "Key Usage"},
+	{OIDSubjectAltName, "Subject Alternative Name"},
+	{OIDBasicConstraints, "Basic Constraints"},
+	{OIDNameConstraints, "Name Constraints"},
+	{OIDCRLDistribution, "CRL Distribution Points"},
+	{OIDCertPolicies, "Certificate Policies"},
+	{OIDAuthorityKeyID, "Authority Key Identifier"},
+	{OIDExtKeyUsage, "Extended Key Usage"},
+	{OIDAuthorityInfo, "Authority Information Access"},
+	{OIDCTPrecertificates, "CT Precertificate SCTs"},
+}
+
+// basicConstraints is the BasicConstraints extension of RFC 5280, section 4.2.1.9.
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+// ExtensionName returns the name of a well-known extension, or its OID.
+func ExtensionName(oid asn1.ObjectIdentifier) string {
+	for _, e := range extensionNames {
+		if e.oid.Equal(oid) {
+			return e.name
+		}
+	}
+	return oid.String()
+}
+
+// RequestExtensions returns the key usage, extended key usage and basic
+// constraints extensions a certificate request asks for. Unlike a
+// certificate, a request only carries what was explicitly set: no default
+// key usage, and basic constraints only for a CA.
+func RequestExtensions(opts Options) ([]pkix.Extension, error) {
+	var extensions []pkix.Extension
+
+	if opts.KeyUsage != 0 {
+		value, err := marshalKeyUsage(opts.KeyUsage)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: OIDKeyUsage, Critical: true, Value: value})
+	}
+
+	if len(opts.ExtKeyUsage) > 0 {
+		oids := make([]asn1.ObjectIdentifier, len(opts.ExtKeyUsage))
+		for i, usage := range opts.ExtKeyUsage {
+			oid, ok := extKeyUsageOID(usage)
+			if !ok {
+				return nil, fmt.Errorf("unsupported extended key usage %d", usage)
+			}
+			oids[i] = oid
+		}
+
+		value, err := asn1.Marshal(oids)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: OIDExtKeyUsage, Value: value})
+	}
+
+	if opts.IsCA {
+		value, err := asn1.Marshal(basicConstraints{IsCA: true, MaxPathLen: opts.MaxPathLen})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: OIDBasicConstraints, Critical: true, Value: value})
+	}
+
+	return extensions, nil
+}
+
+// DescribeExtension returns the value of a key usage, extended key usage or
+// basic constraints extension as OpenSSL prints it, and the hex of any other.
+func DescribeExtension(ext pkix.Extension) (string, error) {
+	switch {
+	case ext.Id.Equal(OIDKeyUsage):
+		usage, err := unmarshalKeyUsage(ext.Value)
+		if err != nil {
+			return "", fmt.Errorf("key usage: %w", err)
+		}
+		return strings.Join(KeyUsageString(usage), ", "), nil
+
+	case ext.Id.Equal(OIDExtKeyUsage):
+		var oids []asn1.ObjectIdentifier
+		if rest, err := asn1.Unmarshal(ext.Value, &oids); err != nil || len(rest) > 0 {
+			return "", fmt.Errorf("extended key usage: invalid encoding")
+		}
+
+		names := make([]string, len(oids))
+		for i, oid := range oids {
+			names[i] = oid.String()
+			for _, u := range extKeyUsages {
+				if u.oid.Equal(oid) {
+					names[i] = u.name
+				}
+			}
+		}
+		return strings.Join(names, ", "), nil
+
+	case ext.Id.Equal(OIDBasicConstraints):
+		var bc basicConstraints
+		if rest, err := asn1.Unmarshal(ext.Value, &bc); err != nil || len(rest) > 0 {
+			return "", fmt.Errorf("basic constraints: invalid encoding")
+		}
+		if !bc.IsCA {
+			return "CA:FALSE", nil
+		}
+		if bc.MaxPathLen >= 0 {
+			return fmt.Sprintf("CA:TRUE, pathlen:%d", bc.MaxPathLen), nil
+		}
+		return "CA:TRUE", nil
+
+	default:
+		return hex.EncodeToString(ext.Value), nil
+	}
+}
+
+// marshalKeyUsage encodes the KeyUsage BIT STRING, bit 0 (digitalSignature)
+// being the most significant bit of the first octet.
+func marshalKeyUsage(usage x509.KeyUsage) ([]byte, error) {
+	var b [2]byte
+	bitLength := 0
+	for i := 0; i < 9; i++ {
+		if usage&(1<<i) != 0 {
+			b[i/8] |= 0x80 >> (i % 8)
+			bitLength = i + 1
+		}
+	}
+
+	return asn1.Marshal(asn1.BitString{Bytes: b[:(bitLength+7)/8], BitLength: bitLength})
+}
+
+func unmarshalKeyUsage(der []byte) (x509.KeyUsage, error) {
+	var bits asn1.BitString
+	if rest, err := asn1.Unmarshal(der, &bits); err != nil || len(rest) > 0 {
+		return 0, fmt.Errorf("invalid encoding")
+	}
+
+	var usage x509.KeyUsage
+	for i := 0; i < 9; i++ {
+		if bits.At(i) != 0 {
+			usage |= 1 << i
+		}
+	}
+	return usage, nil
+}
+
+func extKeyUsageOID(usage x509.ExtKeyUsage) (asn1.ObjectIdentifier, bool) {
+	for _, u := range extKeyUsages {
+		if u.usage == usage {
+			return u.oid, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,229 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// keyUsages lists the key usage bits in the order of RFC 5280, section 4.2.1.3,
+// under the names OpenSSL uses.
+var keyUsages = []struct {
+	name  string
+	usage x509.KeyUsage
+}{
+	{"digitalSignature", x509.KeyUsageDigitalSignature},
+	{"nonRepudiation", x509.KeyUsageContentCommitment},
+	{"keyEncipherment", x509.KeyUsageKeyEncipherment},
+	{"dataEncipherment", x509.KeyUsageDataEncipherment},
+	{"keyAgreement", x509.KeyUsageKeyAgreement},
+	{"keyCertSign", x509.KeyUsageCertSign},
+	{"cRLSign", x509.KeyUsageCRLSign},
+	{"encipherOnly", x509.KeyUsageEncipherOnly},
+	{"decipherOnly", x509.KeyUsageDecipherOnly},
+}
+
+var keyUsageAliases = map[string]x509.KeyUsage{
+	"contentcommitment": x509.KeyUsageContentCommitment,
+	"certsign":          x509.KeyUsageCertSign,
+}
+
+var extKeyUsages = []struct {
+	name  string
+	usage x509.ExtKeyUsage
+	oid   asn1.ObjectIdentifier
+}{
+	{"serverAuth", x509.ExtKeyUsageServerAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}},
+	{"clientAuth", x509.ExtKeyUsageClientAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+	{"codeSigning", x509.ExtKeyUsageCodeSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 3}},
+	{"emailProtection", x509.ExtKeyUsageEmailProtection, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}},
+	{"timeStamping", x509.ExtKeyUsageTimeStamping, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}},
+	{"OCSPSigning", x509.ExtKeyUsageOCSPSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}},
+	{"any", x509.ExtKeyUsageAny, asn1.ObjectIdentifier{2, 5, 29, 37, 0}},
+}
+
+// KeyUsageNames lists the names ParseKeyUsage accepts.
+func KeyUsageNames() []string {
+	names := make([]string, len(keyUsages))
+	for i, u := range keyUsages {
+		names[i] = u.name
+	}
+	return names
+}
+
+// ExtKeyUsageNames lists the names ParseExtKeyUsage accepts.
+func ExtKeyUsageNames() []string {
+	names := make([]string, len(extKeyUsages))
+	for i, u := range extKeyUsages {
+		names[i] = u.name
+	}
+	return names
+}
+
+// KeyUsageString names the bits set in usage.
+func KeyUsageString(usage x509.KeyUsage) []string {
+	var names []string
+	for _, u := range keyUsages {
+		if usage&u.usage != 0 {
+			names = append(names, u.name)
+		}
+	}
+	return names
+}
+
+// ExtKeyUsageString names the extended key usages, and gives the OID of unknown ones.
+func ExtKeyUsageString(usages []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	var names []string
+
+next:
+	for _, usage := range usages {
+		for _, u := range extKeyUsages {
+			if u.usage == usage {
+				names = append(names, u.name)
+				continue next
+			}
+		}
+		names = append(names, fmt.Sprintf("unknown (%d)", usage))
+	}
+
+	for _, oid := range unknown {
+		names = append(names, oid.String())
+	}
+
+	return names
+}
+
+// ParseKeyUsage combines key usages named as in OpenSSL, e.g. digitalSignature
+// or keyCertSign. Names are case-insensitive.
+func ParseKeyUsage(names []string) (x509.KeyUsage, error) {
+	var usage x509.KeyUsage
+
+next:
+	for _, name := range names {
+		if u, ok := keyUsageAliases[strings.ToLower(name)]; ok {
+			usage |= u
+			continue
+		}
+
+		for _, u := range keyUsages {
+			if strings.EqualFold(u.name, name) {
+				usage |= u.usage
+				continue next
+			}
+		}
+
+		return 0, fmt.Errorf("unknown key usage: %s (supported: %s)", name, strings.Join(KeyUsageNames(), ", "))
+	}
+
+	return usage, nil
+}
+
+// ParseExtKeyUsage returns the extended key usages named as in OpenSSL, e.g.
+// serverAuth or codeSigning. Names are case-insensitive.
+func ParseExtKeyUsage(names []string) ([]x509.ExtKeyUsage, error) {
+	var usages []x509.ExtKeyUsage
+
+next:
+	for _, name := range names {
+		for _, u := range extKeyUsages {
+			if strings.EqualFold(u.name, name) {
+				usages = append(usages, u.usage)
+				continue next
+			}
+		}
+
+		return nil, fmt.Errorf("unknown extended key usage: %s (supported: %s)", name, strings.Join(ExtKeyUsageNames(), ", "))
+	}
+
+	return usages, nil
+}
+
+// ParseName reads a distinguished name written as in RFC 4514
+// ("CN=example.com,O=Example,C=US", with backslash escapes) or as OpenSSL
+// prints it ("/C=US/O=Example/CN=example.com").
+func ParseName(s string) (pkix.Name, error) {
+	var name pkix.Name
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return name, nil
+	}
+
+	separator := ','
+	if strings.HasPrefix(s, "/") {
+		separator = '/'
+		s = s[1:]
+	}
+
+	attributes, err := splitName(s, separator)
+	if err != nil {
+		return name, err
+	}
+
+	for _, attribute := range attributes {
+		typ, value, ok := strings.Cut(attribute, "=")
+		if !ok {
+			return name, fmt.Errorf("invalid name attribute %q: expected TYPE=value", attribute)
+		}
+
+		typ = strings.ToUpper(strings.TrimSpace(typ))
+		value = strings.TrimSpace(value)
+
+		switch typ {
+		case "CN":
+			name.CommonName = value
+		case "SERIALNUMBER":
+			name.SerialNumber = value
+		case "C":
+			name.Country = append(name.Country, value)
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		case "ST", "S":
+			name.Province = append(name.Province, value)
+		case "STREET":
+			name.StreetAddress = append(name.StreetAddress, value)
+		case "POSTALCODE":
+			name.PostalCode = append(name.PostalCode, value)
+		default:
+			return name, fmt.Errorf("unsupported name attribute %s (supported: CN, O, OU, C, ST, L, STREET, POSTALCODE, SERIALNUMBER)", typ)
+		}
+	}
+
+	return name, nil
+}
+
+// splitName splits s at every unescaped separator and removes the escapes.
+func splitName(s string, separator rune) ([]string, error) {
+	var (
+		attributes []string
+		current    strings.Builder
+		escaped    bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == separator:
+			attributes = append(attributes, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("invalid name %q: trailing backslash", s)
+	}
+
+	return append(attributes, current.String()), nil
+}
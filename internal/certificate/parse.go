@@ -0,0 +1,63 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+// ErrNoCertificates is returned by ParseCertificates for data without certificates.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// ParseCertificates reads a DER certificate, a DER PKCS #7 bundle, or PEM data
+// with any number of "CERTIFICATE" and "PKCS7" blocks, ignoring other blocks
+// such as keys. It returns the certificates in order and the container they
+// were found in, config.ContainerX509 or config.ContainerPKCS7.
+func ParseCertificates(data []byte) ([]*x509.Certificate, string, error) {
+	if block, _ := pem.Decode(data); block == nil {
+		if cert, err := x509.ParseCertificate(data); err == nil {
+			return []*x509.Certificate{cert}, config.ContainerX509, nil
+		}
+		if certs, err := ParsePKCS7(data); err == nil {
+			return certs, config.ContainerPKCS7, nil
+		}
+		return nil, "", ErrNoCertificates
+	}
+
+	var (
+		certs     []*x509.Certificate
+		container = config.ContainerX509
+	)
+
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		switch block.Type {
+		case config.PEMTypeCertificate:
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, "", fmt.Errorf("certificate %d: %w", len(certs)+1, err)
+			}
+			certs = append(certs, cert)
+		case config.PEMTypePKCS7:
+			bundle, err := ParsePKCS7(block.Bytes)
+			if err != nil {
+				return nil, "", err
+			}
+			certs = append(certs, bundle...)
+			container = config.ContainerPKCS7
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, "", ErrNoCertificates
+	}
+
+	return certs, container, nil
+}
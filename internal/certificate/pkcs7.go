@@ -0,0 +1,54 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// contentInfo is the PKCS #7 ContentInfo of RFC 2315, section 7.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// signedData is the PKCS #7 SignedData of RFC 2315, section 9.1. Only the
+// certificates are read; a .p7b bundle has no content and no signers.
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// ParsePKCS7 returns the certificates of a DER PKCS #7 SignedData structure,
+// such as a .p7b certificate bundle.
+func ParsePKCS7(der []byte) ([]*x509.Certificate, error) {
+	var info contentInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("parse PKCS #7: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("parse PKCS #7: trailing data")
+	}
+
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("parse PKCS #7: content type %s is not signedData", info.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("parse PKCS #7 signedData: %w", err)
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS #7 certificates: %w", err)
+	}
+
+	return certs, nil
+}
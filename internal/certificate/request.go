@@ -0,0 +1,44 @@
+package certificate
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// CreateRequest creates a PKCS #10 certificate signing request for key and
+// returns its DER encoding. The validity and serial number of opts are left to
+// the CA; its key usages and basic constraints are requested as extensions.
+func CreateRequest(key *rsa.PrivateKey, opts Options) ([]byte, error) {
+	if err := opts.validateNames(); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxPathLen >= 0 && !opts.IsCA {
+		return nil, errors.New("a path length constraint can only be requested for a CA")
+	}
+
+	extensions, err := RequestExtensions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:            opts.Subject,
+		DNSNames:           opts.DNSNames,
+		IPAddresses:        opts.IPAddresses,
+		EmailAddresses:     opts.EmailAddresses,
+		URIs:               opts.URIs,
+		ExtraExtensions:    extensions,
+		SignatureAlgorithm: opts.SignatureAlgorithm,
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate request: %w", err)
+	}
+
+	return der, nil
+}
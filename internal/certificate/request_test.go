@@ -0,0 +1,153 @@
+package certificate
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]string // DescribeExtension of each requested extension
+	}{
+		{
+			name: "names only",
+			opts: Options{Subject: pkix.Name{CommonName: "api.example.com"}, DNSNames: []string{"api.example.com"}, MaxPathLen: -1},
+			want: map[string]string{},
+		},
+		{
+			name: "server with PSS",
+			opts: Options{
+				DNSNames:           []string{"api.example.com"},
+				KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+				ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+				MaxPathLen:         -1,
+				SignatureAlgorithm: x509.SHA384WithRSAPSS,
+			},
+			want: map[string]string{
+				OIDKeyUsage.String():    "digitalSignature, keyEncipherment",
+				OIDExtKeyUsage.String(): "serverAuth, clientAuth",
+			},
+		},
+		{
+			name: "CA with a path length",
+			opts: Options{Subject: pkix.Name{CommonName: "Example Issuing CA"}, IsCA: true, MaxPathLen: 0, KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign},
+			want: map[string]string{
+				OIDKeyUsage.String():         "keyCertSign, cRLSign",
+				OIDBasicConstraints.String(): "CA:TRUE, pathlen:0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := CreateRequest(key, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := csr.CheckSignature(); err != nil {
+				t.Fatal(err)
+			}
+			if !key.PublicKey.Equal(csr.PublicKey) {
+				t.Fatal("the request holds another key")
+			}
+			if want := tt.opts.SignatureAlgorithm; want != x509.UnknownSignatureAlgorithm && csr.SignatureAlgorithm != want {
+				t.Fatalf("signed with %s, want %s", csr.SignatureAlgorithm, want)
+			}
+
+			got := map[string]string{}
+			for _, ext := range csr.Extensions {
+				if ext.Id.Equal(OIDSubjectAltName) {
+					continue
+				}
+				value, err := DescribeExtension(ext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[ext.Id.String()] = value
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got extensions %q, want %q", got, tt.want)
+			}
+			for id, value := range tt.want {
+				if got[id] != value {
+					t.Fatalf("got extensions %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRequestErrors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]Options{
+		"no names":               {MaxPathLen: -1},
+		"path length without CA": {DNSNames: []string{"example.com"}, MaxPathLen: 1},
+		"ECDSA signature":        {DNSNames: []string{"example.com"}, MaxPathLen: -1, SignatureAlgorithm: x509.ECDSAWithSHA256},
+		"unknown extended usage": {DNSNames: []string{"example.com"}, MaxPathLen: -1, ExtKeyUsage: []x509.ExtKeyUsage{100}},
+	}
+
+	for name, opts := range tests {
+		if _, err := CreateRequest(key, opts); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+// TestCreateRequestOpenSSL has OpenSSL check the signature of a request.
+func TestCreateRequestOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, alg := range []x509.SignatureAlgorithm{x509.SHA256WithRSA, x509.SHA256WithRSAPSS} {
+		der, err := CreateRequest(key, Options{
+			Subject:            pkix.Name{CommonName: "api.example.com"},
+			DNSNames:           []string{"api.example.com"},
+			ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			MaxPathLen:         -1,
+			SignatureAlgorithm: alg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "req.pem")
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command(openssl, "req", "-in", path, "-noout", "-verify").CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: openssl req -verify: %v: %s", alg, err, out)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxPathLength bounds the paths VerifyPath builds.
+const maxPathLength = 10
+
+// VerifyOptions describes what VerifyPath checks a certificate against.
+type VerifyOptions struct {
+	Roots         []*x509.Certificate
+	Intermediates []*x509.Certificate
+
+	// Purpose is the extended key usage the leaf is checked for;
+	// x509.ExtKeyUsageAny accepts every purpose.
+	Purpose x509.ExtKeyUsage
+
+	// Hostname, when set, is a DNS name or IP address the leaf must be valid for.
+	Hostname string
+
+	// MinRSABits is the smallest RSA key allowed anywhere in the path.
+	MinRSABits int
+
+	Time time.Time
+}
+
+// Check is the outcome of one check of a certificate in the path.
+type Check struct {
+	Name   string
+	Detail string // what was found, when the check passed
+	Err    error
+}
+
+// Step is a certificate of the path with the outcome of its checks.
+type Step struct {
+	Certificate *x509.Certificate
+	Role        string // leaf, intermediate or root
+	Checks      []Check
+}
+
+// Path is the certification path built from a leaf to a root.
+type Path struct {
+	Steps []Step
+
+	// Err is set when no path to a root could be built, or when crypto/x509
+	// rejects a path whose checks all passed.
+	Err error
+}
+
+// Roles of the certificates in a path.
+const (
+	RoleLeaf         = "leaf"
+	RoleIntermediate = "intermediate"
+	RoleRoot         = "root"
+)
+
+// OK reports whether a path to a root was built and every check passed.
+func (p *Path) OK() bool {
+	return len(p.Problems()) == 0
+}
+
+// Problems lists every failed check, prefixed with the position of its
+// certificate in the path, followed by Err.
+func (p *Path) Problems() []string {
+	var problems []string
+	for i, step := range p.Steps {
+		for _, check := range step.Checks {
+			if check.Err != nil {
+				problems = append(problems, fmt.Sprintf("[%d] %s: %s", i, check.Name, check.Err))
+			}
+		}
+	}
+
+	if p.Err != nil {
+		problems = append(problems, p.Err.Error())
+	}
+
+	return problems
+}
+
+// VerifyPath builds the path from leaf to one of opts.Roots through
+// opts.Intermediates and checks every certificate in it: signatures, validity
+// windows, CA basic constraints and path lengths, name constraints, key usage
+// and extended key usage for opts.Purpose, RSA key sizes and unhandled
+// critical extensions. Unlike x509.Certificate.Verify it keeps going after a
+// failure, so that every problem of the path is reported.
+func VerifyPath(leaf *x509.Certificate, opts VerifyOptions) *Path {
+	chain, buildErr := buildPath(leaf, opts)
+
+	path := &Path{Err: buildErr}
+	for i, cert := range chain {
+		role := RoleIntermediate
+		switch {
+		case i == 0:
+			role = RoleLeaf
+		case i == len(chain)-1 && buildErr == nil:
+			role = RoleRoot
+		}
+
+		var issuer *x509.Certificate
+		if i+1 < len(chain) {
+			issuer = chain[i+1]
+		}
+
+		// Without an issuer, the signature is left to the path error.
+		step := Step{Certificate: cert, Role: role}
+		if issuer != nil || role == RoleRoot {
+			step.Checks = append(step.Checks, checkSignature(cert, issuer, role))
+		}
+		step.Checks = append(step.Checks, checkValidity(cert, opts.Time))
+		step.Checks = append(step.Checks, checkKeySize(cert, opts.MinRSABits))
+		if i > 0 {
+			step.Checks = append(step.Checks, checkCA(cert, chain[:i], role))
+		} else {
+			step.Checks = append(step.Checks, checkKeyUsage(cert, opts.Purpose))
+		}
+		step.Checks = append(step.Checks, checkExtKeyUsage(cert, opts.Purpose, role))
+		if check, ok := checkPathNameConstraints(cert, chain[i+1:], i == 0); ok {
+			step.Checks = append(step.Checks, check)
+		}
+		if i == 0 && opts.Hostname != "" {
+			step.Checks = append(step.Checks, checkHostname(cert, opts.Hostname))
+		}
+		if len(cert.UnhandledCriticalExtensions) > 0 {
+			step.Checks = append(step.Checks, checkCriticalExtensions(cert))
+		}
+
+		path.Steps = append(path.Steps, step)
+	}
+
+	// Have crypto/x509 confirm a path found valid, in case it enforces more.
+	if path.OK() {
+		if err := crossCheck(leaf, opts); err != nil {
+			path.Err = fmt.Errorf("crypto/x509 rejects the path: %w", err)
+		}
+	}
+
+	return path
+}
+
+// buildPath returns the path from leaf to a root, as far as it gets, and an
+// error when it doesn't reach one.
+func buildPath(leaf *x509.Certificate, opts VerifyOptions) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{leaf}
+	for {
+		cert := chain[len(chain)-1]
+		if isAmong(cert, opts.Roots) {
+			return chain, nil
+		}
+
+		if len(chain) > maxPathLength {
+			return chain, fmt.Errorf("no root within %d certificates", maxPathLength)
+		}
+
+		issuer := findIssuer(cert, chain, opts)
+		if issuer == nil {
+			if bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil {
+				return chain, fmt.Errorf("%s is self-signed but not among the roots", cert.Subject)
+			}
+			return chain, fmt.Errorf("no issuer of %s (issuer %s) among the roots and intermediates", cert.Subject, cert.Issuer)
+		}
+
+		chain = append(chain, issuer)
+	}
+}
+
+// findIssuer returns the most plausible issuer of cert that isn't already in
+// the chain: preferably one whose signature verifies, then a root, then one
+// valid at opts.Time.
+func findIssuer(cert *x509.Certificate, chain []*x509.Certificate, opts VerifyOptions) *x509.Certificate {
+	var (
+		best      *x509.Certificate
+		bestScore = -1
+	)
+
+	for _, candidate := range slices.Concat(opts.Roots, opts.Intermediates) {
+		if !bytes.Equal(candidate.RawSubject, cert.RawIssuer) || isAmong(candidate, chain) {
+			continue
+		}
+
+		if len(cert.AuthorityKeyId) > 0 && len(candidate.SubjectKeyId) > 0 && !bytes.Equal(cert.AuthorityKeyId, candidate.SubjectKeyId) {
+			continue
+		}
+
+		score := 0
+		if candidate.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil {
+			score += 4
+		}
+		if isAmong(candidate, opts.Roots) {
+			score += 2
+		}
+		if !opts.Time.Before(candidate.NotBefore) && !opts.Time.After(candidate.NotAfter) {
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+
+	return best
+}
+
+func isAmong(cert *x509.Certificate, certs []*x509.Certificate) bool {
+	return slices.ContainsFunc(certs, cert.Equal)
+}
+
+func checkSignature(cert, issuer *x509.Certificate, role string) Check {
+	check := Check{Name: "Signature"}
+	switch {
+	case role == RoleRoot:
+		check.Detail = "trust anchor"
+	default:
+		if err := issuer.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+			check.Err = fmt.Errorf("%s by %s: %w", cert.SignatureAlgorithm, issuer.Subject, err)
+		} else {
+			check.Detail = fmt.Sprintf("%s by %s", cert.SignatureAlgorithm, issuer.Subject)
+		}
+	}
+	return check
+}
+
+func checkValidity(cert *x509.Certificate, at time.Time) Check {
+	check := Check{Name: "Validity"}
+	switch {
+	case at.Before(cert.NotBefore):
+		check.Err = fmt.Errorf("not valid before %s", cert.NotBefore.UTC().Format(time.RFC3339))
+	case at.After(cert.NotAfter):
+		check.Err = fmt.Errorf("expired on %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	default:
+		check.Detail = fmt.Sprintf("%s to %s", cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return check
+}
+
+func checkKeySize(cert *x509.Certificate, minBits int) Check {
+	check := Check{Name: "Key"}
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		bits := pub.N.BitLen()
+		if bits < minBits {
+			check.Err = fmt.Errorf("RSA %d bits, the policy requires at least %d", bits, minBits)
+		} else {
+			check.Detail = fmt.Sprintf("RSA %d bits", bits)
+		}
+	case *ecdsa.PublicKey:
+		check.Detail = fmt.Sprintf("ECDSA %s, not subject to the RSA policy", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		check.Detail = "Ed25519, not subject to the RSA policy"
+	default:
+		check.Detail = fmt.Sprintf("%s, not subject to the RSA policy", cert.PublicKeyAlgorithm)
+	}
+	return check
+}
+
+// checkCA checks that cert may issue the certificates below it in the path.
+// A v1 root is a trust anchor that can't have extensions: its missing basic
+// constraints are reported but not enforced (RFC 5280, section 6.1.1). As in
+// x509.Certificate.Verify, v3 roots are held to their extensions.
+func checkCA(cert *x509.Certificate, below []*x509.Certificate, role string) Check {
+	check := Check{Name: "CA"}
+	var err error
+	switch {
+	case !cert.BasicConstraintsValid || !cert.IsCA:
+		err = errors.New("not a CA certificate (basic constraints CA:FALSE or missing)")
+	case cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0:
+		err = fmt.Errorf("key usage %s lacks keyCertSign", strings.Join(KeyUsageString(cert.KeyUsage), ", "))
+	}
+	if err != nil {
+		if role == RoleRoot && cert.Version < 3 {
+			check.Detail = err.Error() + ", not enforced for a v1 trust anchor"
+		} else {
+			check.Err = err
+		}
+		return check
+	}
+
+	// Self-issued intermediates don't count against the path length (RFC 5280, section 6.1.4).
+	intermediates := 0
+	for _, c := range below[1:] {
+		if !bytes.Equal(c.RawSubject, c.RawIssuer) {
+			intermediates++
+		}
+	}
+
+	limited := cert.MaxPathLen > 0 || cert.MaxPathLen == 0 && cert.MaxPathLenZero
+	switch {
+	case limited && intermediates > cert.MaxPathLen:
+		check.Err = fmt.Errorf("path length %d exceeded: %d intermediate CAs below it", cert.MaxPathLen, intermediates)
+	case limited:
+		check.Detail = fmt.Sprintf("path length %d", cert.MaxPathLen)
+	default:
+		check.Detail = "no path length limit"
+	}
+	return check
+}
+
+// purposeKeyUsages are the key usages that permit each purpose; a leaf needs
+// one of them when it has a key usage extension.
+var purposeKeyUsages = map[x509.ExtKeyUsage]x509.KeyUsage{
+	x509.ExtKeyUsageServerAuth:      x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+	x509.ExtKeyUsageClientAuth:      x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+	x509.ExtKeyUsageCodeSigning:     x509.KeyUsageDigitalSignature,
+	x509.ExtKeyUsageEmailProtection: x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+	x509.ExtKeyUsageTimeStamping:    x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	x509.ExtKeyUsageOCSPSigning:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+}
+
+func checkKeyUsage(cert *x509.Certificate, purpose x509.ExtKeyUsage) Check {
+	check := Check{Name: "Key Usage"}
+	allowed, ok := purposeKeyUsages[purpose]
+	switch {
+	case cert.KeyUsage == 0:
+		check.Detail = "no key usage extension, any usage"
+	case ok && cert.KeyUsage&allowed == 0:
+		check.Err = fmt.Errorf("%s doesn't permit %s, which needs one of %s", strings.Join(KeyUsageString(cert.KeyUsage), ", "),
+			ExtKeyUsageString([]x509.ExtKeyUsage{purpose}, nil)[0], strings.Join(KeyUsageString(allowed), ", "))
+	default:
+		check.Detail = strings.Join(KeyUsageString(cert.KeyUsage), ", ")
+	}
+	return check
+}
+
+// checkExtKeyUsage checks that cert allows purpose. CA certificates with an
+// extended key usage extension restrict the certificates they issue, as
+// crypto/x509 and browsers enforce.
+func checkExtKeyUsage(cert *x509.Certificate, purpose x509.ExtKeyUsage, role string) Check {
+	check := Check{Name: "Extended Key Usage"}
+	usages := ExtKeyUsageString(cert.ExtKeyUsage, cert.UnknownExtKeyUsage)
+	name := ExtKeyUsageString([]x509.ExtKeyUsage{purpose}, nil)[0]
+
+	switch {
+	case len(usages) == 0:
+		if role == RoleLeaf {
+			check.Detail = "no extended key usage extension, any purpose"
+		} else {
+			check.Detail = "unrestricted"
+		}
+	case purpose == x509.ExtKeyUsageAny:
+		check.Detail = strings.Join(usages, ", ")
+	case slices.Contains(cert.ExtKeyUsage, purpose) || slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageAny):
+		check.Detail = strings.Join(usages, ", ")
+	default:
+		check.Err = fmt.Errorf("%s doesn't include %s", strings.Join(usages, ", "), name)
+	}
+	return check
+}
+
+// checkPathNameConstraints checks the names of cert against the name
+// constraints of the CAs above it. It reports false when none has any.
+func checkPathNameConstraints(cert *x509.Certificate, above []*x509.Certificate, leaf bool) (Check, bool) {
+	check := Check{Name: "Name Constraints"}
+
+	// Self-issued intermediates are exempt (RFC 5280, section 6.1.3).
+	if !leaf && bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return check, false
+	}
+
+	var constrainedBy []string
+	for _, ca := range above {
+		if !hasNameConstraints(ca) {
+			continue
+		}
+
+		if err := checkNameConstraints(cert, ca); err != nil {
+			check.Err = err
+			return check, true
+		}
+		constrainedBy = append(constrainedBy, ca.Subject.String())
+	}
+
+	if len(constrainedBy) == 0 {
+		return check, false
+	}
+
+	check.Detail = "within the constraints of " + strings.Join(constrainedBy, ", ")
+	return check, true
+}
+
+func hasNameConstraints(cert *x509.Certificate) bool {
+	return len(cert.PermittedDNSDomains)+len(cert.ExcludedDNSDomains)+
+		len(cert.PermittedIPRanges)+len(cert.ExcludedIPRanges)+
+		len(cert.PermittedEmailAddresses)+len(cert.ExcludedEmailAddresses)+
+		len(cert.PermittedURIDomains)+len(cert.ExcludedURIDomains) > 0
+}
+
+func checkHostname(cert *x509.Certificate, hostname string) Check {
+	check := Check{Name: "Hostname"}
+	if err := cert.VerifyHostname(hostname); err != nil {
+		check.Err = err
+	} else {
+		check.Detail = hostname
+	}
+	return check
+}
+
+func checkCriticalExtensions(cert *x509.Certificate) Check {
+	names := make([]string, len(cert.UnhandledCriticalExtensions))
+	for i, oid := range cert.UnhandledCriticalExtensions {
+		names[i] = ExtensionName(oid)
+	}
+
+	return Check{Name: "Critical Extensions", Err: fmt.Errorf("unsupported critical extension %s", strings.Join(names, ", "))}
+}
+
+// crossCheck runs x509.Certificate.Verify with the same inputs.
+func crossCheck(leaf *x509.Certificate, opts VerifyOptions) error {
+	roots := x509.NewCertPool()
+	for _, cert := range opts.Roots {
+		roots.AddCert(cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range opts.Intermediates {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   opts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{opts.Purpose},
+		DNSName:       opts.Hostname,
+	})
+	return err
+}
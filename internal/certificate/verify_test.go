@@ -0,0 +1,334 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPath holds the templates of a root, an intermediate and a leaf, which
+// the test cases modify before the certificates are created.
+type testPath struct {
+	root, intermediate, leaf *x509.Certificate
+	leafKey                  *rsa.PrivateKey
+	v1Root                   bool
+}
+
+func newTestPath(now time.Time) *testPath {
+	validity := func(cert *x509.Certificate) *x509.Certificate {
+		cert.NotBefore, cert.NotAfter = now.Add(-time.Hour), now.Add(24*time.Hour)
+		return cert
+	}
+
+	return &testPath{
+		root: validity(&x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "Test Root CA"},
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}),
+		intermediate: validity(&x509.Certificate{
+			SerialNumber:          big.NewInt(2),
+			Subject:               pkix.Name{CommonName: "Test Issuing CA"},
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}),
+		leaf: validity(&x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      pkix.Name{CommonName: "api.example.com"},
+			DNSNames:     []string{"api.example.com"},
+			KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}),
+	}
+}
+
+// create signs the templates with keys, the first for the root, and returns
+// the leaf and the options to verify it as a server certificate at now.
+func (p *testPath) create(t *testing.T, keys []*rsa.PrivateKey, now time.Time) (*x509.Certificate, VerifyOptions) {
+	t.Helper()
+	leafKey := keys[2]
+	if p.leafKey != nil {
+		leafKey = p.leafKey
+	}
+
+	root := createCertificate(t, p.root, p.root, &keys[0].PublicKey, keys[0])
+	if p.v1Root {
+		root = downgradeToV1(t, root, keys[0])
+	}
+	intermediate := createCertificate(t, p.intermediate, root, &keys[1].PublicKey, keys[0])
+	leaf := createCertificate(t, p.leaf, intermediate, &leafKey.PublicKey, keys[1])
+
+	return leaf, VerifyOptions{
+		Roots:         []*x509.Certificate{root},
+		Intermediates: []*x509.Certificate{intermediate},
+		Purpose:       x509.ExtKeyUsageServerAuth,
+		MinRSABits:    2048,
+		Time:          now,
+	}
+}
+
+func createCertificate(t *testing.T, template, parent *x509.Certificate, pub *rsa.PublicKey, key *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// downgradeToV1 re-signs a self-signed certificate as v1, without
+// extensions, which crypto/x509 can't create.
+func downgradeToV1(t *testing.T, cert *x509.Certificate, key *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	var tbs struct {
+		Version            int `asn1:"optional,explicit,default:0,tag:0"`
+		SerialNumber       *big.Int
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Issuer             asn1.RawValue
+		Validity           asn1.RawValue
+		Subject            asn1.RawValue
+		PublicKey          asn1.RawValue
+		Extensions         []pkix.Extension `asn1:"optional,explicit,tag:3"`
+	}
+	if _, err := asn1.Unmarshal(cert.RawTBSCertificate, &tbs); err != nil {
+		t.Fatal(err)
+	}
+
+	tbs.Version, tbs.Extensions = 0, nil
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(tbsDER)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := asn1.Marshal(struct {
+		TBSCertificate     asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}{asn1.RawValue{FullBytes: tbsDER}, tbs.SignatureAlgorithm, asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Version != 1 {
+		t.Fatalf("got version %d", v1.Version)
+	}
+	return v1
+}
+
+func TestVerifyPath(t *testing.T) {
+	var keys []*rsa.PrivateKey
+	for _, bits := range []int{2048, 2048, 2048, 1024} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		templates func(p *testPath)
+		options   func(opts *VerifyOptions)
+
+		// want are the problems reported, each matched as a prefix.
+		want []string
+
+		// wantDetails are details of passed checks, as "[i] name: detail".
+		wantDetails []string
+
+		// policyOnly is set when the problems are of this package's policy,
+		// which x509.Certificate.Verify doesn't enforce.
+		policyOnly bool
+	}{
+		{
+			name:        "valid",
+			wantDetails: []string{"[2] Signature: trust anchor", "[1] CA: no path length limit", "[0] Extended Key Usage: serverAuth"},
+		},
+		{
+			name: "expired intermediate",
+			templates: func(p *testPath) {
+				p.intermediate.NotBefore, p.intermediate.NotAfter = now.AddDate(-1, 0, 0), now.Add(-time.Hour)
+			},
+			want: []string{"[1] Validity: expired on " + now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+		},
+		{
+			name:    "leaf not yet valid",
+			options: func(opts *VerifyOptions) { opts.Time = now.Add(-2 * time.Hour) },
+			want:    []string{"[0] Validity: not valid before", "[1] Validity: not valid before", "[2] Validity: not valid before"},
+		},
+		{
+			name:        "within the permitted DNS subtree",
+			templates:   func(p *testPath) { p.intermediate.PermittedDNSDomains = []string{"example.com"} },
+			wantDetails: []string{"[0] Name Constraints: within the constraints of CN=Test Issuing CA"},
+		},
+		{
+			name:      "outside the permitted DNS subtree",
+			templates: func(p *testPath) { p.intermediate.PermittedDNSDomains = []string{"example.org"} },
+			want:      []string{"[0] Name Constraints: DNS:api.example.com is outside the permitted subtrees example.org"},
+		},
+		{
+			name:      "DNS name excluded by the root",
+			templates: func(p *testPath) { p.root.ExcludedDNSDomains = []string{".example.com"} },
+			want:      []string{`[0] Name Constraints: DNS:api.example.com is in the excluded subtree ".example.com"`},
+		},
+		{
+			name: "IP address outside the permitted ranges",
+			templates: func(p *testPath) {
+				p.intermediate.PermittedIPRanges = []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}}
+				p.leaf.IPAddresses = []net.IP{net.ParseIP("192.168.1.1")}
+			},
+			want: []string{"[0] Name Constraints: IP:192.168.1.1 is outside the permitted ranges of CN=Test Issuing CA"},
+		},
+		{
+			name: "excluded email address",
+			templates: func(p *testPath) {
+				p.intermediate.ExcludedEmailAddresses = []string{"example.com"}
+				p.leaf.EmailAddresses = []string{"ops@example.com"}
+			},
+			want: []string{`[0] Name Constraints: email:ops@example.com is in the excluded subtree "example.com"`},
+		},
+		{
+			name:    "leaf for the wrong purpose",
+			options: func(opts *VerifyOptions) { opts.Purpose = x509.ExtKeyUsageClientAuth },
+			want:    []string{"[0] Extended Key Usage: serverAuth doesn't include clientAuth"},
+		},
+		{
+			name: "intermediate restricted to another purpose",
+			templates: func(p *testPath) {
+				p.intermediate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+			},
+			want: []string{"[1] Extended Key Usage: codeSigning doesn't include serverAuth"},
+		},
+		{
+			name:      "key usage not permitting the purpose",
+			templates: func(p *testPath) { p.leaf.KeyUsage = x509.KeyUsageCRLSign },
+			want:      []string{"[0] Key Usage: cRLSign doesn't permit serverAuth"},
+			// crypto/x509 ignores the key usage extension.
+			policyOnly: true,
+		},
+		{
+			name:       "undersized RSA key",
+			templates:  func(p *testPath) { p.leafKey = keys[3] },
+			want:       []string{"[0] Key: RSA 1024 bits, the policy requires at least 2048"},
+			policyOnly: true,
+		},
+		{
+			name:      "intermediate that isn't a CA",
+			templates: func(p *testPath) { p.intermediate.IsCA = false },
+			want:      []string{"[1] CA: not a CA certificate (basic constraints CA:FALSE or missing)"},
+		},
+		{
+			name: "path length exceeded",
+			templates: func(p *testPath) {
+				p.root.MaxPathLen, p.root.MaxPathLenZero = 0, true
+			},
+			want: []string{"[2] CA: path length 0 exceeded: 1 intermediate CAs below it"},
+		},
+		{
+			name:        "v1 root",
+			templates:   func(p *testPath) { p.v1Root = true },
+			wantDetails: []string{"[2] CA: not a CA certificate (basic constraints CA:FALSE or missing), not enforced for a v1 trust anchor"},
+		},
+		{
+			name: "v3 root without basic constraints",
+			templates: func(p *testPath) {
+				p.root.BasicConstraintsValid, p.root.IsCA = false, false
+			},
+			want: []string{"[2] CA: not a CA certificate (basic constraints CA:FALSE or missing)"},
+		},
+		{
+			name:      "v3 root without keyCertSign",
+			templates: func(p *testPath) { p.root.KeyUsage = x509.KeyUsageDigitalSignature },
+			want:      []string{"[2] CA: key usage digitalSignature lacks keyCertSign"},
+		},
+		{
+			name:    "wrong hostname",
+			options: func(opts *VerifyOptions) { opts.Hostname = "www.example.com" },
+			want:    []string{"[0] Hostname: x509: certificate is valid for api.example.com, not www.example.com"},
+		},
+		{
+			name:    "no path to a root",
+			options: func(opts *VerifyOptions) { opts.Intermediates = nil },
+			want:    []string{"no issuer of CN=api.example.com (issuer CN=Test Issuing CA) among the roots and intermediates"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPath(now)
+			if tt.templates != nil {
+				tt.templates(p)
+			}
+			leaf, opts := p.create(t, keys, now)
+			if tt.options != nil {
+				tt.options(&opts)
+			}
+
+			path := VerifyPath(leaf, opts)
+			problems := path.Problems()
+			if len(problems) != len(tt.want) {
+				t.Fatalf("got problems %q, want %q", problems, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("got problem %q, want %q", problems[i], want)
+				}
+			}
+
+			var details []string
+			for i, step := range path.Steps {
+				for _, check := range step.Checks {
+					if check.Err == nil {
+						details = append(details, fmt.Sprintf("[%d] %s: %s", i, check.Name, check.Detail))
+					}
+				}
+			}
+			for _, want := range tt.wantDetails {
+				if !slices.Contains(details, want) {
+					t.Errorf("missing detail %q in %q", want, details)
+				}
+			}
+
+			// VerifyPath agrees with crypto/x509, except for its own policy.
+			err := crossCheck(leaf, opts)
+			if tt.policyOnly {
+				if err != nil {
+					t.Fatalf("crypto/x509 rejects the path: %v", err)
+				}
+			} else if path.OK() != (err == nil) {
+				t.Fatalf("VerifyPath OK is %t, crypto/x509 returned %v", path.OK(), err)
+			}
+		})
+	}
+}
@@ -4,8 +4,43 @@ const (
 	KeyTypeRSA           = "RSA"
 	KeyTypeRSAPublicKey  = "RSA PUBLIC KEY"
 	KeyTypeRSAPrivateKey = "RSA PRIVATE KEY"
+	KeyTypePublicKey     = "PUBLIC KEY"
+	KeyTypePrivateKey    = "PRIVATE KEY"
 
-	KeyFormatPEM = "PEM"
-	KeyFormatDER = "DER"
-	KeyFormatJWK = "JWK"
+	KeyTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	KeyTypeOpenSSHPrivateKey   = "OPENSSH PRIVATE KEY"
+
+	PEMTypeCertificate           = "CERTIFICATE"
+	PEMTypeCertificateRequest    = "CERTIFICATE REQUEST"
+	PEMTypeNewCertificateRequest = "NEW CERTIFICATE REQUEST"
+	PEMTypePKCS7                 = "PKCS7"
+	PEMTypeCRL                   = "X509 CRL"
+
+	KeyFormatPEM      = "PEM"
+	KeyFormatDER      = "DER"
+	KeyFormatJWK      = "JWK"
+	KeyFormatPEMPKCS8 = "PEM-PKCS8"
+	KeyFormatDERPKCS8 = "DER-PKCS8"
+	KeyFormatPEMSPKI  = "PEM-SPKI"
+	KeyFormatDERSPKI  = "DER-SPKI"
+	KeyFormatOpenSSH  = "OPENSSH"
+	KeyFormatSSH      = "SSH"
+
+	ContainerPKCS1   = "PKCS#1"
+	ContainerPKCS8   = "PKCS#8"
+	ContainerSPKI    = "SPKI"
+	ContainerJWK     = "JWK"
+	ContainerOpenSSH = "OpenSSH"
+	ContainerSSH2    = "RFC 4716"
+	ContainerPPK     = "PuTTY PPK"
+	ContainerX509    = "X.509"
+	ContainerPKCS7   = "PKCS#7"
+
+	PaddingOAEP     = "oaep"
+	PaddingPKCS1v15 = "pkcs1v15"
+	PaddingPSS      = "pss"
+
+	EncodingRaw    = "raw"
+	EncodingBase64 = "base64"
+	EncodingHex    = "hex"
 )
@@ -0,0 +1,388 @@
+// Package envelope implements hybrid RSA encryption for payloads of any size.
+//
+// A fresh AES-256 data key is wrapped with RSA-OAEP for each recipient and the
+// payload is encrypted with AES-256-GCM as a sequence of authenticated segments.
+// All integers are big-endian.
+//
+//	header:
+//	  magic       4 bytes  "RSAE"
+//	  version     1 byte   1
+//	  chunk size  4 bytes  plaintext bytes per segment
+//	  count       2 bytes  number of recipient stanzas
+//	  stanzas     count times:
+//	    key ID length   1 byte
+//	    key ID          key ID length bytes, identifies the recipient key (e.g. a JWK thumbprint)
+//	    wrapped length  2 bytes
+//	    wrapped key     RSA-OAEP(SHA-256, label "rsa-tools envelope v1") of the data key
+//	body:
+//	  segments    AES-256-GCM(data key, nonce, plaintext, magic || version || chunk size)
+//
+// Every segment except the last one holds exactly chunk size bytes of plaintext.
+// The 12-byte nonce is the 11-byte segment counter followed by 0x01 for the last
+// segment and 0x00 otherwise, so reordered, dropped or truncated segments fail to
+// authenticate. The recipient stanzas are not authenticated, which allows them to
+// be added and removed without touching the body: a tampered stanza can only
+// yield a wrong data key, which the first segment then rejects.
+package envelope
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	Version          = 1
+	DefaultChunkSize = 64 * 1024
+	MaxChunkSize     = 16 * 1024 * 1024
+
+	dataKeySize = 32
+	tagSize     = 16
+	nonceSize   = 12
+)
+
+var (
+	magic     = []byte("RSAE")
+	wrapLabel = []byte("rsa-tools envelope v1")
+)
+
+var (
+	ErrNotEnvelope = errors.New("not an envelope (bad magic)")
+	ErrNoRecipient = errors.New("no recipient stanza can be opened with this key")
+	ErrTruncated   = errors.New("envelope is truncated")
+	ErrCorrupted   = errors.New("envelope segment failed authentication (corrupted, reordered or truncated)")
+)
+
+// Recipient is a public key the data key is wrapped for.
+type Recipient struct {
+	KeyID     string
+	PublicKey *rsa.PublicKey
+}
+
+// Stanza is the data key wrapped for a single recipient.
+type Stanza struct {
+	KeyID      string
+	WrappedKey []byte
+}
+
+type Header struct {
+	Version   byte
+	ChunkSize uint32
+	Stanzas   []Stanza
+}
+
+// Encrypt reads plaintext from src until EOF and writes the envelope to dst.
+func Encrypt(dst io.Writer, src io.Reader, recipients []Recipient) error {
+	if len(recipients) == 0 {
+		return errors.New("at least one recipient is required")
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+
+	header := &Header{Version: Version, ChunkSize: DefaultChunkSize}
+	for _, r := range recipients {
+		stanza, err := WrapKey(dataKey, r)
+		if err != nil {
+			return err
+		}
+		header.Stanzas = append(header.Stanzas, *stanza)
+	}
+
+	if _, err := header.WriteTo(dst); err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	aad := header.aad()
+	br := bufio.NewReader(src)
+	buf := make([]byte, header.ChunkSize)
+	out := make([]byte, 0, int(header.ChunkSize)+tagSize)
+
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+
+		last := n < len(buf)
+		if !last {
+			if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		out = aead.Seal(out[:0], segmentNonce(counter, last), buf[:n], aad)
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// Decrypt reads an envelope from src and writes the plaintext to dst.
+// keyID selects the recipient stanza of privateKey, see Header.UnwrapKey.
+//
+// The plaintext is written as segments are authenticated, so when an error is
+// returned the output written so far must be discarded.
+func Decrypt(dst io.Writer, src io.Reader, privateKey *rsa.PrivateKey, keyID string) error {
+	br := bufio.NewReader(src)
+
+	header, err := ReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	dataKey, err := header.UnwrapKey(privateKey, keyID)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	aad := header.aad()
+	buf := make([]byte, int(header.ChunkSize)+tagSize)
+	out := make([]byte, 0, header.ChunkSize)
+
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+
+		if n < tagSize {
+			return ErrTruncated
+		}
+
+		last := n < len(buf)
+		if !last {
+			if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		out, err = aead.Open(out[:0], segmentNonce(counter, last), buf[:n], aad)
+		if err != nil {
+			return ErrCorrupted
+		}
+
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// WrapKey encrypts the data key for a recipient.
+func WrapKey(dataKey []byte, r Recipient) (*Stanza, error) {
+	if len(r.KeyID) > 255 {
+		return nil, errors.New("key ID is longer than 255 bytes")
+	}
+
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r.PublicKey, dataKey, wrapLabel)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+
+	return &Stanza{KeyID: r.KeyID, WrappedKey: wrapped}, nil
+}
+
+// UnwrapKey recovers the data key with the private key. Only the stanzas whose
+// key ID is keyID are tried, plus the ones without a key ID.
+func (h *Header) UnwrapKey(privateKey *rsa.PrivateKey, keyID string) ([]byte, error) {
+	for _, s := range h.Stanzas {
+		if s.KeyID != "" && s.KeyID != keyID {
+			continue
+		}
+
+		if len(s.WrappedKey) != privateKey.Size() {
+			continue
+		}
+
+		dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, s.WrappedKey, wrapLabel)
+		if err == nil && len(dataKey) == dataKeySize {
+			return dataKey, nil
+		}
+	}
+
+	return nil, ErrNoRecipient
+}
+
+// AddRecipient wraps the data key for one more recipient, replacing any stanza with the same key ID.
+func (h *Header) AddRecipient(dataKey []byte, r Recipient) error {
+	stanza, err := WrapKey(dataKey, r)
+	if err != nil {
+		return err
+	}
+
+	if r.KeyID != "" {
+		h.RemoveRecipient(r.KeyID)
+	}
+
+	h.Stanzas = append(h.Stanzas, *stanza)
+	return nil
+}
+
+// RemoveRecipient drops the stanzas with the given key ID and reports whether any was found.
+func (h *Header) RemoveRecipient(keyID string) bool {
+	kept := h.Stanzas[:0]
+	for _, s := range h.Stanzas {
+		if s.KeyID != keyID {
+			kept = append(kept, s)
+		}
+	}
+
+	removed := len(kept) != len(h.Stanzas)
+	h.Stanzas = kept
+	return removed
+}
+
+// Rewrite copies an envelope from src to dst with its header changed by modify.
+// The body is copied as is, so the payload is never decrypted.
+func Rewrite(dst io.Writer, src io.Reader, modify func(h *Header) error) error {
+	br := bufio.NewReader(src)
+
+	header, err := ReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if err := modify(header); err != nil {
+		return err
+	}
+
+	if len(header.Stanzas) == 0 {
+		return errors.New("an envelope must keep at least one recipient")
+	}
+
+	if _, err := header.WriteTo(dst); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, br)
+	return err
+}
+
+// ReadHeader reads and validates the envelope header, leaving r at the first segment.
+func ReadHeader(r io.Reader) (*Header, error) {
+	var fixed [11]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	if string(fixed[:4]) != string(magic) {
+		return nil, ErrNotEnvelope
+	}
+
+	h := &Header{
+		Version:   fixed[4],
+		ChunkSize: binary.BigEndian.Uint32(fixed[5:9]),
+	}
+
+	if h.Version != Version {
+		return nil, fmt.Errorf("unsupported envelope version: %d", h.Version)
+	}
+
+	if h.ChunkSize == 0 || h.ChunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("invalid chunk size: %d", h.ChunkSize)
+	}
+
+	count := binary.BigEndian.Uint16(fixed[9:11])
+	for i := 0; i < int(count); i++ {
+		var kidLen [1]byte
+		if _, err := io.ReadFull(r, kidLen[:]); err != nil {
+			return nil, fmt.Errorf("read stanza %d: %w", i, err)
+		}
+
+		kid := make([]byte, kidLen[0])
+		if _, err := io.ReadFull(r, kid); err != nil {
+			return nil, fmt.Errorf("read stanza %d: %w", i, err)
+		}
+
+		var wrappedLen [2]byte
+		if _, err := io.ReadFull(r, wrappedLen[:]); err != nil {
+			return nil, fmt.Errorf("read stanza %d: %w", i, err)
+		}
+
+		wrapped := make([]byte, binary.BigEndian.Uint16(wrappedLen[:]))
+		if _, err := io.ReadFull(r, wrapped); err != nil {
+			return nil, fmt.Errorf("read stanza %d: %w", i, err)
+		}
+
+		h.Stanzas = append(h.Stanzas, Stanza{KeyID: string(kid), WrappedKey: wrapped})
+	}
+
+	return h, nil
+}
+
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	if len(h.Stanzas) > 0xffff {
+		return 0, errors.New("too many recipients")
+	}
+
+	buf := append([]byte(nil), h.aad()...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(h.Stanzas)))
+	for _, s := range h.Stanzas {
+		if len(s.KeyID) > 0xff || len(s.WrappedKey) > 0xffff {
+			return 0, errors.New("recipient stanza is too large")
+		}
+
+		buf = append(buf, byte(len(s.KeyID)))
+		buf = append(buf, s.KeyID...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(s.WrappedKey)))
+		buf = append(buf, s.WrappedKey...)
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// aad is the fixed part of the header that every segment is bound to.
+func (h *Header) aad() []byte {
+	aad := append([]byte(nil), magic...)
+	aad = append(aad, h.Version)
+	return binary.BigEndian.AppendUint32(aad, h.ChunkSize)
+}
+
+func segmentNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 0x01
+	}
+	return nonce
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
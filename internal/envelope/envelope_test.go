@@ -0,0 +1,207 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func privateKeys(t *testing.T) [2]*rsa.PrivateKey {
+	t.Helper()
+	var keys [2]*rsa.PrivateKey
+	for i := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	keys := privateKeys(t)
+	recipients := []Recipient{
+		{KeyID: "alice", PublicKey: &keys[0].PublicKey},
+		{KeyID: "bob", PublicKey: &keys[1].PublicKey},
+	}
+
+	sizes := []int{0, 1, DefaultChunkSize - 1, DefaultChunkSize, DefaultChunkSize + 1, 3 * DefaultChunkSize}
+	for _, size := range sizes {
+		plaintext := randomBytes(t, size)
+
+		var sealed bytes.Buffer
+		if err := Encrypt(&sealed, bytes.NewReader(plaintext), recipients); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		for i, r := range recipients {
+			var opened bytes.Buffer
+			if err := Decrypt(&opened, bytes.NewReader(sealed.Bytes()), keys[i], r.KeyID); err != nil {
+				t.Fatalf("size %d, recipient %s: %v", size, r.KeyID, err)
+			}
+			if !bytes.Equal(opened.Bytes(), plaintext) {
+				t.Fatalf("size %d, recipient %s: plaintext differs", size, r.KeyID)
+			}
+		}
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	keys := privateKeys(t)
+
+	var sealed bytes.Buffer
+	if err := Encrypt(&sealed, bytes.NewReader([]byte("data")), []Recipient{{KeyID: "alice", PublicKey: &keys[0].PublicKey}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Decrypt(new(bytes.Buffer), bytes.NewReader(sealed.Bytes()), keys[1], "alice"); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("got %v, want %v", err, ErrNoRecipient)
+	}
+}
+
+// sealed is an envelope built segment by segment, so that tests can frame the
+// segments wrongly on purpose.
+type sealed struct {
+	key      *rsa.PrivateKey
+	header   []byte
+	segments [][]byte
+}
+
+// seal encrypts chunks as consecutive segments with a chunk size of chunkSize;
+// last reports the last flag to use for segment i.
+func seal(t *testing.T, chunkSize uint32, chunks [][]byte, last func(i int) bool) *sealed {
+	t.Helper()
+	key := privateKeys(t)[0]
+
+	dataKey := randomBytes(t, dataKeySize)
+	stanza, err := WrapKey(dataKey, Recipient{PublicKey: &key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Header{Version: Version, ChunkSize: chunkSize, Stanzas: []Stanza{*stanza}}
+	var header bytes.Buffer
+	if _, err := h.WriteTo(&header); err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sealed{key: key, header: header.Bytes()}
+	for i, chunk := range chunks {
+		s.segments = append(s.segments, aead.Seal(nil, segmentNonce(uint64(i), last(i)), chunk, h.aad()))
+	}
+	return s
+}
+
+func (s *sealed) open(segments ...[]byte) error {
+	envelope := bytes.Join(append([][]byte{s.header}, segments...), nil)
+	return Decrypt(new(bytes.Buffer), bytes.NewReader(envelope), s.key, "")
+}
+
+func TestDecryptSegmentFraming(t *testing.T) {
+	const chunkSize = 16
+	chunks := [][]byte{
+		bytes.Repeat([]byte{'a'}, chunkSize),
+		bytes.Repeat([]byte{'b'}, chunkSize),
+		[]byte("tail"),
+	}
+	isLast := func(i int) bool { return i == len(chunks)-1 }
+
+	s := seal(t, chunkSize, chunks, isLast)
+	if err := s.open(s.segments...); err != nil {
+		t.Fatalf("well-formed envelope: %v", err)
+	}
+
+	seg := s.segments
+	tests := []struct {
+		name     string
+		segments [][]byte
+		want     error
+	}{
+		{"reordered", [][]byte{seg[1], seg[0], seg[2]}, ErrCorrupted},
+		{"dropped middle segment", [][]byte{seg[0], seg[2]}, ErrCorrupted},
+		{"dropped last segment", [][]byte{seg[0], seg[1]}, ErrCorrupted},
+		{"truncated within a segment", [][]byte{seg[0], seg[1], seg[2][:len(seg[2])-1]}, ErrCorrupted},
+		{"truncated to less than a tag", [][]byte{seg[0], seg[1], seg[2][:tagSize-1]}, ErrTruncated},
+		{"no segments", nil, ErrTruncated},
+		{"appended segment", [][]byte{seg[0], seg[1], seg[2], seg[2]}, ErrCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.open(tt.segments...); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptLastFlag(t *testing.T) {
+	const chunkSize = 16
+	full := bytes.Repeat([]byte{'x'}, chunkSize)
+
+	// The final segment without the last flag: a truncation at a segment boundary.
+	s := seal(t, chunkSize, [][]byte{full, []byte("tail")}, func(int) bool { return false })
+	if err := s.open(s.segments...); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("final segment without the last flag: got %v, want %v", err, ErrCorrupted)
+	}
+
+	// A segment with the last flag followed by another one.
+	s = seal(t, chunkSize, [][]byte{full, []byte("tail")}, func(int) bool { return true })
+	if err := s.open(s.segments...); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("last flag on the first of two segments: got %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestDecryptCorruptedSegment(t *testing.T) {
+	s := seal(t, 16, [][]byte{[]byte("data")}, func(int) bool { return true })
+	segment := bytes.Clone(s.segments[0])
+	segment[0] ^= 0x01
+	if err := s.open(segment); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("got %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestReadHeaderRejectsMalformed(t *testing.T) {
+	s := seal(t, 16, [][]byte{[]byte("data")}, func(int) bool { return true })
+
+	badVersion := bytes.Clone(s.header)
+	badVersion[4] = 2
+	badChunkSize := bytes.Clone(s.header)
+	copy(badChunkSize[5:9], []byte{0xff, 0xff, 0xff, 0xff})
+
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"bad magic", append([]byte("XXXX"), s.header[4:]...)},
+		{"unsupported version", badVersion},
+		{"chunk size too large", badChunkSize},
+		{"truncated stanza", s.header[:len(s.header)-1]},
+		{"truncated fixed header", s.header[:5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadHeader(bytes.NewReader(tt.header)); err == nil {
+				t.Fatal("accepted a malformed header")
+			}
+		})
+	}
+}
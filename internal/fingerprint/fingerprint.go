@@ -0,0 +1,56 @@
+// Package fingerprint computes the identifiers other ecosystems give an RSA
+// public key: certificate pins, subject key identifiers and OpenPGP fingerprints.
+package fingerprint
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"math/big"
+	"time"
+)
+
+// SPKIPin returns the SHA-256 of the DER SubjectPublicKeyInfo, the value of an
+// HPKP "pin-sha256" directive and of most certificate pinning schemes.
+func SPKIPin(key *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(der)
+	return sum[:], nil
+}
+
+// SubjectKeyID returns the SHA-1 of the subjectPublicKey BIT STRING contents,
+// the key identifier of RFC 5280, section 4.2.1.2, method (1).
+func SubjectKeyID(key *rsa.PublicKey) []byte {
+	sum := sha1.Sum(x509.MarshalPKCS1PublicKey(key))
+	return sum[:]
+}
+
+// OpenPGPv4 returns the version 4 fingerprint of RFC 4880, section 12.2: the
+// SHA-1 of the public key packet. The packet includes the key creation time,
+// which key files other than OpenPGP ones don't record.
+func OpenPGPv4(key *rsa.PublicKey, created time.Time) []byte {
+	body := []byte{4} // version
+	body = binary.BigEndian.AppendUint32(body, uint32(created.Unix()))
+	body = append(body, 1) // RSA (Encrypt or Sign)
+	body = appendMPI(body, key.N)
+	body = appendMPI(body, big.NewInt(int64(key.E)))
+
+	h := sha1.New()
+	h.Write([]byte{0x99})
+	h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(body))))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// appendMPI appends an OpenPGP multiprecision integer: its bit length, then
+// its big-endian bytes.
+func appendMPI(b []byte, n *big.Int) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(n.BitLen()))
+	return append(b, n.Bytes()...)
+}
@@ -0,0 +1,51 @@
+package fingerprint
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T, modulus string) *rsa.PublicKey {
+	t.Helper()
+	n, ok := new(big.Int).SetString(modulus, 16)
+	if !ok {
+		t.Fatal("malformed modulus")
+	}
+	return &rsa.PublicKey{N: n, E: 65537}
+}
+
+// sshModulus is the modulus of a key written by ssh-keygen.
+const sshModulus = "E2ECEA6476B36F80AE1F5E25DD2701FCD5C765BC5805ECF36363690E733566EAD630B797C74417B25384CA50C90C69B63C7633D6D543F257262F26A9B4E864ECA220FBD5F93626AFEA954A691EF5BF073DE98583B603B88FE14D558828D685D6A399D6085B48A7D8D40AD4530DB53B4AFD1DA9D26202EC9BF4BAA5297C57696D"
+
+func TestSPKIPin(t *testing.T) {
+	// openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+	pin, err := SPKIPin(testKey(t, sshModulus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := base64.StdEncoding.EncodeToString(pin), "xbZPv0PsXi2hobyKEaULXdu+QpAh3uGoV4jBWWg8wO4="; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSubjectKeyID(t *testing.T) {
+	// The subjectKeyIdentifier=hash extension OpenSSL writes into a certificate.
+	if got, want := hex.EncodeToString(SubjectKeyID(testKey(t, sshModulus))), "b82d36a4fd293d301de8cd192d57176c51bd9801"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestOpenPGPv4(t *testing.T) {
+	// A key generated by GnuPG 2 ("gpg --quick-gen-key ... rsa1024"), with the
+	// modulus and creation time of "gpg --with-colons --with-key-data".
+	key := testKey(t, "D52AB06FD5600A229C6CD7985996B5D1CB514FEF3885923B23B7805490F2B3F64005F8ED9F770031B6AD086CEFA5AAF371400DAA6181BBB9CE242462BF7869CA1F750099399013EB6E2C6CB3D72209707EBF5D0DC802322BF75B0D205E7BBF12F69AA9896C85AA5E53FD7669AA81BE172D7C5949C808336FEC7D90914BC4D8BD")
+
+	got := hex.EncodeToString(OpenPGPv4(key, time.Unix(1792295582, 0)))
+	if want := "cc3ad01d3505d17d530132b9557c99db7b819a36"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -9,13 +9,31 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/tuanta7/keys/internal/certificate"
 	"github.com/tuanta7/keys/internal/config"
 	"github.com/tuanta7/keys/internal/key"
+	"github.com/tuanta7/keys/internal/keycrypt"
+	"github.com/tuanta7/keys/internal/sshkey"
 )
 
 type RSAKeyGenerator struct {
 	OutputDir string
 	Format    string
+	Comment   string // stored in OpenSSH keys
+
+	// JWK holds the kid, alg, use and key_ops written with both JWK files; its
+	// Value is ignored. The public key only gets the key_ops it can perform.
+	JWK key.Key
+
+	// Encryption, when set, protects the private key with Passphrase.
+	// Only the PKCS#8 and OpenSSH formats can be encrypted; OpenSSH keys use
+	// bcrypt_pbkdf with Encryption.Iterations rounds.
+	Encryption *keycrypt.Options
+	Passphrase []byte
+
+	// Certificate, when set, adds a self-signed certificate for the key, in DER
+	// for the DER formats and in PEM otherwise.
+	Certificate *certificate.Options
 }
 
 func (g *RSAKeyGenerator) WriteKeyPair(privateKey *rsa.PrivateKey) error {
@@ -23,7 +41,15 @@ func (g *RSAKeyGenerator) WriteKeyPair(privateKey *rsa.PrivateKey) error {
 		return err
 	}
 
-	return g.writePublicKey(&privateKey.PublicKey)
+	if err := g.writePublicKey(&privateKey.PublicKey); err != nil {
+		return err
+	}
+
+	if g.Certificate != nil {
+		return g.writeCertificate(privateKey)
+	}
+
+	return nil
 }
 
 func (g *RSAKeyGenerator) writePrivateKey(privateKey *rsa.PrivateKey) error {
@@ -37,21 +63,78 @@ func (g *RSAKeyGenerator) writePrivateKey(privateKey *rsa.PrivateKey) error {
 }
 
 func (g *RSAKeyGenerator) marshalPrivateKey(privateKey *rsa.PrivateKey) ([]byte, error) {
+	if g.Encryption != nil {
+		return g.marshalEncryptedPrivateKey(privateKey)
+	}
+
 	switch g.Format {
 	case config.KeyFormatDER:
 		return x509.MarshalPKCS1PrivateKey(privateKey), nil
-	case config.KeyFormatPEM:
+	case config.KeyFormatPEM, config.KeyFormatSSH:
 		return pem.EncodeToMemory(&pem.Block{
 			Type:  config.KeyTypeRSAPrivateKey,
 			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
 		}), nil
 	case config.KeyFormatJWK:
-		return json.MarshalIndent(key.Key{Value: privateKey}, "", "\t")
+		k := g.JWK
+		k.Value = privateKey
+		return json.MarshalIndent(k, "", "\t")
+	case config.KeyFormatDERPKCS8, config.KeyFormatDERSPKI:
+		return x509.MarshalPKCS8PrivateKey(privateKey)
+	case config.KeyFormatPEMPKCS8, config.KeyFormatPEMSPKI:
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  config.KeyTypePrivateKey,
+			Bytes: der,
+		}), nil
+	case config.KeyFormatOpenSSH:
+		return g.marshalOpenSSHPrivateKey(privateKey, nil, 0)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", g.Format)
 	}
 }
 
+func (g *RSAKeyGenerator) marshalOpenSSHPrivateKey(privateKey *rsa.PrivateKey, passphrase []byte, rounds int) ([]byte, error) {
+	data, err := sshkey.MarshalPrivateKey(privateKey, g.Comment, passphrase, rounds)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  config.KeyTypeOpenSSHPrivateKey,
+		Bytes: data,
+	}), nil
+}
+
+func (g *RSAKeyGenerator) marshalEncryptedPrivateKey(privateKey *rsa.PrivateKey) ([]byte, error) {
+	if g.Format == config.KeyFormatOpenSSH {
+		return g.marshalOpenSSHPrivateKey(privateKey, g.Passphrase, g.Encryption.Iterations)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch g.Format {
+	case config.KeyFormatDERPKCS8, config.KeyFormatDERSPKI:
+		return keycrypt.EncryptPKCS8(der, g.Passphrase, *g.Encryption)
+	case config.KeyFormatPEMPKCS8, config.KeyFormatPEMSPKI:
+		encrypted, err := keycrypt.EncryptPKCS8(der, g.Passphrase, *g.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  config.KeyTypeEncryptedPrivateKey,
+			Bytes: encrypted,
+		}), nil
+	default:
+		return nil, fmt.Errorf("format %s can't be encrypted, use %s, %s or %s", g.Format, config.KeyFormatPEMPKCS8, config.KeyFormatDERPKCS8, config.KeyFormatOpenSSH)
+	}
+}
+
 func (g *RSAKeyGenerator) writePublicKey(publicKey *rsa.PublicKey) error {
 	filePath := filepath.Join(g.OutputDir, g.fileName("id_rsa.pub"))
 	data, err := g.marshalPublicKey(publicKey)
@@ -72,17 +155,49 @@ func (g *RSAKeyGenerator) marshalPublicKey(publicKey *rsa.PublicKey) ([]byte, er
 			Bytes: x509.MarshalPKCS1PublicKey(publicKey),
 		}), nil
 	case config.KeyFormatJWK:
-		return json.MarshalIndent(key.Key{Value: publicKey}, "", "\t")
+		k := g.JWK
+		k.Value = publicKey
+		return json.MarshalIndent(k.Public(), "", "\t")
+	case config.KeyFormatDERPKCS8, config.KeyFormatDERSPKI:
+		return x509.MarshalPKIXPublicKey(publicKey)
+	case config.KeyFormatPEMPKCS8, config.KeyFormatPEMSPKI:
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  config.KeyTypePublicKey,
+			Bytes: der,
+		}), nil
+	case config.KeyFormatOpenSSH, config.KeyFormatSSH:
+		return sshkey.MarshalAuthorizedKey(publicKey, g.Comment, nil), nil
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", g.Format)
 	}
 }
 
+func (g *RSAKeyGenerator) writeCertificate(privateKey *rsa.PrivateKey) error {
+	der, err := certificate.SelfSign(privateKey, *g.Certificate)
+	if err != nil {
+		return err
+	}
+
+	switch g.Format {
+	case config.KeyFormatDER, config.KeyFormatDERPKCS8, config.KeyFormatDERSPKI:
+		return os.WriteFile(filepath.Join(g.OutputDir, "id_rsa.crt.der"), der, 0644)
+	default:
+		return os.WriteFile(filepath.Join(g.OutputDir, "id_rsa.crt"), pem.EncodeToMemory(&pem.Block{
+			Type:  config.PEMTypeCertificate,
+			Bytes: der,
+		}), 0644)
+	}
+}
+
 func (g *RSAKeyGenerator) fileName(def string) string {
 	switch g.Format {
-	case config.KeyFormatPEM:
+	case config.KeyFormatPEM, config.KeyFormatPEMPKCS8, config.KeyFormatPEMSPKI, config.KeyFormatOpenSSH, config.KeyFormatSSH:
 		return def
-	case config.KeyFormatDER:
+	case config.KeyFormatDER, config.KeyFormatDERPKCS8, config.KeyFormatDERSPKI:
 		return def + ".der"
 	case config.KeyFormatJWK:
 		return def + ".json"
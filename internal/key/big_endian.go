@@ -2,8 +2,8 @@ package key
 
 import (
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"math/big"
 )
 
@@ -38,14 +38,33 @@ func (b *Bytes) UnmarshalJSON(data []byte) error {
 	return err
 }
 
-func (b *Bytes) Uint64() uint64 {
-	return binary.BigEndian.Uint64(b.bigEndianSequence)
+// Uint64 decodes sequences of any length, such as the 3-byte exponent AQAB,
+// as long as the value fits in 64 bits.
+func (b *Bytes) Uint64() (uint64, error) {
+	var v uint64
+	for _, octet := range b.bigEndianSequence {
+		if v>>56 != 0 {
+			return 0, errors.New("value doesn't fit in 64 bits")
+		}
+		v = v<<8 | uint64(octet)
+	}
+	return v, nil
 }
 
-func (b *Bytes) Int() int {
-	return int(binary.BigEndian.Uint64(b.bigEndianSequence))
+// Int decodes a value up to 2^31-1, the largest RSA public exponent that
+// crypto/rsa accepts.
+func (b *Bytes) Int() (int, error) {
+	v, err := b.Uint64()
+	if err != nil || v > maxPublicExponent {
+		return 0, errors.New("value over 2^31-1")
+	}
+	return int(v), nil
 }
 
 func (b *Bytes) BigInt() *big.Int {
 	return new(big.Int).SetBytes(b.bigEndianSequence)
 }
+
+func (b *Bytes) Bytes() []byte {
+	return b.bigEndianSequence
+}
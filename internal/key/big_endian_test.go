@@ -0,0 +1,39 @@
+package key
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBytesInt(t *testing.T) {
+	tests := []struct {
+		in      []byte
+		want    int
+		wantErr bool
+	}{
+		{[]byte{1, 0, 1}, 65537, false},
+		{[]byte{0x7f, 0xff, 0xff, 0xff}, 1<<31 - 1, false},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 3}, 3, false},
+		{[]byte{0x80, 0, 0, 1}, 0, true},
+		{[]byte{0x80, 0, 0, 0, 0, 0, 0, 1}, 0, true},
+		{[]byte{1, 0, 0, 0, 0, 0, 0, 0, 3}, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := NewBytes(tt.in).Int()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%x: got %d, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestUnmarshalLargePublicExponent(t *testing.T) {
+	// 9 bytes, which used to be read as its low 64 bits, 3.
+	for _, e := range []string{"AQAAAAAAAAAD", "gAAAAAAAAAE", "gAAAAQ"} {
+		var k Key
+		err := json.Unmarshal([]byte(`{"kty":"RSA","n":"Dw","e":"`+e+`"}`), &k)
+		if err == nil {
+			t.Errorf("e %s: read as %+v", e, k.Value)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package key
 
 import (
+	"bytes"
 	"crypto/rsa"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
+	"slices"
 )
 
 // JSONWebKey describes an JWK as defined by RFC 7517/7518.
@@ -11,18 +15,105 @@ import (
 // All numeric parameters are Base64URL-encoded, unsigned, big-endian byte sequences.
 // Base64URL encoding MUST use the URL-safe alphabet and omit padding (`=`).
 type JSONWebKey struct {
-	KeyType         string `json:"kty"`
-	Algorithm       string `json:"alg,omitempty"`
-	Use             string `json:"use,omitempty"`
-	KeyID           string `json:"kid,omitempty"`
-	Modulus         *Bytes `json:"n,omitempty"`
-	PublicExponent  *Bytes `json:"e,omitempty"`
-	PrivateExponent *Bytes `json:"d,omitempty"`
-	Prime0          *Bytes `json:"p,omitempty"`
-	Prime1          *Bytes `json:"q,omitempty"`
-	Dp              *Bytes `json:"dp,omitempty"`
-	Dq              *Bytes `json:"dq,omitempty"`
-	Qi              *Bytes `json:"qi,omitempty"`
+	KeyType         string           `json:"kty"`
+	Algorithm       string           `json:"alg,omitempty"`
+	Use             string           `json:"use,omitempty"`
+	KeyOps          []string         `json:"key_ops,omitzero"` // an empty list means no operation is allowed
+	KeyID           string           `json:"kid,omitempty"`
+	Extractable     *bool            `json:"ext,omitempty"`
+	X509URL         string           `json:"x5u,omitempty"`
+	X509Chain       []string         `json:"x5c,omitempty"` // standard base64 DER, not base64url
+	X509SHA1        *Bytes           `json:"x5t,omitempty"`
+	X509SHA256      *Bytes           `json:"x5t#S256,omitempty"`
+	Modulus         *Bytes           `json:"n,omitempty"`
+	PublicExponent  *Bytes           `json:"e,omitempty"`
+	PrivateExponent *Bytes           `json:"d,omitempty"`
+	Prime0          *Bytes           `json:"p,omitempty"`
+	Prime1          *Bytes           `json:"q,omitempty"`
+	Dp              *Bytes           `json:"dp,omitempty"`
+	Dq              *Bytes           `json:"dq,omitempty"`
+	Qi              *Bytes           `json:"qi,omitempty"`
+	OtherPrimes     []OtherPrimeInfo `json:"oth,omitempty"`
+
+	// Extra holds the members not modeled above, so that reading and writing
+	// a key doesn't lose them.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// OtherPrimeInfo describes the third and subsequent primes of a multi-prime
+// key (RFC 7518, section 6.3.2.7).
+type OtherPrimeInfo struct {
+	Prime       *Bytes `json:"r"`
+	Exponent    *Bytes `json:"d"`
+	Coefficient *Bytes `json:"t"`
+}
+
+// jsonWebKey has the fields of JSONWebKey without its JSON methods.
+type jsonWebKey JSONWebKey
+
+func (j JSONWebKey) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(jsonWebKey(j))
+	if err != nil || len(j.Extra) == 0 {
+		return b, err
+	}
+
+	names := make([]string, 0, len(j.Extra))
+	for name := range j.Extra {
+		if !isRegisteredMember(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var buf bytes.Buffer
+	buf.Write(b[:len(b)-1])
+	for _, name := range names {
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(encodedName)
+		buf.WriteByte(':')
+		buf.Write(j.Extra[name])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (j *JSONWebKey) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*jsonWebKey)(j)); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+
+	j.Extra = nil
+	for name, value := range members {
+		if isRegisteredMember(name) {
+			continue
+		}
+		if j.Extra == nil {
+			j.Extra = map[string]json.RawMessage{}
+		}
+		j.Extra[name] = value
+	}
+
+	return nil
+}
+
+// isRegisteredMember reports whether name is one of the members JSONWebKey models.
+func isRegisteredMember(name string) bool {
+	switch name {
+	case "kty", "alg", "use", "key_ops", "kid", "ext", "x5u", "x5c", "x5t", "x5t#S256",
+		"n", "e", "d", "p", "q", "dp", "dq", "qi", "oth":
+		return true
+	}
+	return false
 }
 
 func (j JSONWebKey) RSAPrivateKey() (*rsa.PrivateKey, error) {
@@ -41,7 +132,14 @@ func (j JSONWebKey) RSAPrivateKey() (*rsa.PrivateKey, error) {
 		Primes:    []*big.Int{j.Prime0.BigInt(), j.Prime1.BigInt()},
 	}
 
-	if j.Dp != nil && j.Dq != nil && j.Qi != nil {
+	for i, prime := range j.OtherPrimes {
+		if prime.Prime == nil {
+			return nil, fmt.Errorf("oth[%d]: missing prime", i)
+		}
+		privateKey.Primes = append(privateKey.Primes, prime.Prime.BigInt())
+	}
+
+	if j.Dp != nil && j.Dq != nil && j.Qi != nil && len(j.OtherPrimes) == 0 {
 		privateKey.Precomputed = rsa.PrecomputedValues{
 			Dp:   j.Dp.BigInt(),
 			Dq:   j.Dq.BigInt(),
@@ -53,6 +151,11 @@ func (j JSONWebKey) RSAPrivateKey() (*rsa.PrivateKey, error) {
 		return nil, err
 	}
 
+	// The CRT values of the other primes are recomputed rather than trusted.
+	if len(j.OtherPrimes) > 0 {
+		privateKey.Precompute()
+	}
+
 	return privateKey, nil
 }
 
@@ -61,8 +164,13 @@ func (j JSONWebKey) RSAPublicKey() (*rsa.PublicKey, error) {
 		return nil, errors.New("missing modulus or public exponent")
 	}
 
+	e, err := j.PublicExponent.Int()
+	if err != nil {
+		return nil, fmt.Errorf("public exponent: %w", err)
+	}
+
 	return &rsa.PublicKey{
 		N: j.Modulus.BigInt(),
-		E: j.PublicExponent.Int(),
+		E: e,
 	}, nil
 }
@@ -0,0 +1,199 @@
+package key
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256" // for sameKey
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrDuplicateKeyID = errors.New("duplicate kid")
+	ErrMixedKeys      = errors.New("set mixes private and public keys")
+)
+
+// JSONWebKeySet is a JWK Set as defined by RFC 7517, section 5.
+type JSONWebKeySet struct {
+	Keys []Key `json:"keys"`
+}
+
+func (s JSONWebKeySet) MarshalJSON() ([]byte, error) {
+	keys := s.Keys
+	if keys == nil {
+		keys = []Key{}
+	}
+	return json.Marshal(struct {
+		Keys []Key `json:"keys"`
+	}{keys})
+}
+
+// UnmarshalJSON decodes a set, naming the position of a key that can't be read.
+func (s *JSONWebKeySet) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Keys *[]json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if raw.Keys == nil {
+		return errors.New(`missing "keys" member`)
+	}
+
+	s.Keys = make([]Key, len(*raw.Keys))
+	for i, k := range *raw.Keys {
+		if err := json.Unmarshal(k, &s.Keys[i]); err != nil {
+			return fmt.Errorf("keys[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Key returns the key with the given kid.
+func (s *JSONWebKeySet) Key(kid string) (Key, bool) {
+	for _, k := range s.Keys {
+		if k.KeyID == kid {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Lookup returns the keys matching every non-empty argument. A key without
+// "alg" or "use" matches any algorithm or use, as RFC 7517 makes them optional.
+func (s *JSONWebKeySet) Lookup(kid, alg, use string) []Key {
+	var keys []Key
+	for _, k := range s.Keys {
+		if kid != "" && k.KeyID != kid {
+			continue
+		}
+		if alg != "" && k.Algorithm != "" && k.Algorithm != alg {
+			continue
+		}
+		if use != "" && k.Use != "" && k.Use != use {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Add appends k to the set. The kid must be unique and the key of the same
+// kind, private or public, as the keys already in the set.
+func (s *JSONWebKeySet) Add(k Key) error {
+	if k.KeyID != "" {
+		if _, ok := s.Key(k.KeyID); ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateKeyID, k.KeyID)
+		}
+	}
+
+	if len(s.Keys) > 0 && k.IsPrivate() != s.Keys[0].IsPrivate() {
+		return fmt.Errorf("%w: adding a %s key to a set of %s keys", ErrMixedKeys, kind(k), kind(s.Keys[0]))
+	}
+
+	s.Keys = append(s.Keys, k)
+	return nil
+}
+
+// Remove deletes the keys with the given kid and reports whether there were any.
+func (s *JSONWebKeySet) Remove(kid string) bool {
+	n := len(s.Keys)
+	keys := s.Keys[:0]
+	for _, k := range s.Keys {
+		if k.KeyID != kid {
+			keys = append(keys, k)
+		}
+	}
+	s.Keys = keys
+	return len(s.Keys) < n
+}
+
+// Merge adds the keys of other. A key already in the set under the same kid is
+// skipped; a different key under that kid is an error.
+func (s *JSONWebKeySet) Merge(other JSONWebKeySet) error {
+	for _, k := range other.Keys {
+		if existing, ok := s.Key(k.KeyID); ok && k.KeyID != "" {
+			if !sameKey(existing, k) {
+				return fmt.Errorf("%w: %s names different keys", ErrDuplicateKeyID, k.KeyID)
+			}
+			continue
+		}
+
+		if err := s.Add(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Public returns the set with the private members removed from every key.
+func (s JSONWebKeySet) Public() JSONWebKeySet {
+	public := JSONWebKeySet{Keys: make([]Key, len(s.Keys))}
+	for i, k := range s.Keys {
+		public.Keys[i] = k.Public()
+	}
+	return public
+}
+
+// Validate reports duplicate kids and sets mixing private and public keys.
+func (s JSONWebKeySet) Validate() error {
+	var errs []error
+
+	seen := map[string]int{}
+	for i, k := range s.Keys {
+		if k.KeyID == "" {
+			continue
+		}
+		if j, ok := seen[k.KeyID]; ok {
+			errs = append(errs, fmt.Errorf("%w: keys[%d] and keys[%d] are both %s", ErrDuplicateKeyID, j, i, k.KeyID))
+			continue
+		}
+		seen[k.KeyID] = i
+	}
+
+	var private, public []string
+	for i, k := range s.Keys {
+		if k.IsPrivate() {
+			private = append(private, fmt.Sprintf("keys[%d]", i))
+		} else {
+			public = append(public, fmt.Sprintf("keys[%d]", i))
+		}
+	}
+
+	if len(private) > 0 && len(public) > 0 {
+		errs = append(errs, fmt.Errorf("%w: private %s, public %s", ErrMixedKeys, strings.Join(private, ", "), strings.Join(public, ", ")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsPrivate reports whether the key holds private key material.
+func (k Key) IsPrivate() bool {
+	_, ok := k.Value.(*rsa.PrivateKey)
+	return ok
+}
+
+func kind(k Key) string {
+	if k.IsPrivate() {
+		return "private"
+	}
+	return "public"
+}
+
+func sameKey(a, b Key) bool {
+	ta, err := a.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return false
+	}
+
+	tb, err := b.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return false
+	}
+
+	return string(ta) == string(tb) && a.IsPrivate() == b.IsPrivate()
+}
@@ -0,0 +1,251 @@
+package key
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// rfc7638Key is the public key of RFC 7638, section 3.1.
+const rfc7638Key = `{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`
+
+func generateKeys(t *testing.T, n int) []*rsa.PrivateKey {
+	t.Helper()
+	keys := make([]*rsa.PrivateKey, n)
+	for i := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestJSONWebKeySetAdd(t *testing.T) {
+	keys := generateKeys(t, 2)
+
+	var set JSONWebKeySet
+	if err := set.Add(Key{Value: keys[0], KeyID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Add(Key{Value: keys[1], KeyID: "a"}); !errors.Is(err, ErrDuplicateKeyID) {
+		t.Fatalf("duplicate kid: got %v", err)
+	}
+	if err := set.Add(Key{Value: &keys[1].PublicKey, KeyID: "b"}); !errors.Is(err, ErrMixedKeys) {
+		t.Fatalf("public key in a private set: got %v", err)
+	}
+	if err := set.Add(Key{Value: keys[1]}); err != nil {
+		t.Fatalf("key without kid: %v", err)
+	}
+	if err := set.Add(Key{Value: keys[0]}); err != nil {
+		t.Fatalf("second key without kid: %v", err)
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("got %d keys", len(set.Keys))
+	}
+
+	if !set.Remove("a") || set.Remove("a") || len(set.Keys) != 2 {
+		t.Fatalf("remove: %d keys left", len(set.Keys))
+	}
+}
+
+func TestJSONWebKeySetMerge(t *testing.T) {
+	keys := generateKeys(t, 3)
+
+	set := JSONWebKeySet{Keys: []Key{{Value: &keys[0].PublicKey, KeyID: "a"}}}
+
+	// The same key under the same kid is skipped.
+	other := JSONWebKeySet{Keys: []Key{{Value: &keys[0].PublicKey, KeyID: "a"}, {Value: &keys[1].PublicKey, KeyID: "b"}}}
+	if err := set.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(set.Keys))
+	}
+
+	tests := []struct {
+		name  string
+		other Key
+		want  error
+	}{
+		{"different key under a kid", Key{Value: &keys[2].PublicKey, KeyID: "a"}, ErrDuplicateKeyID},
+		{"private half under a kid", Key{Value: keys[0], KeyID: "a"}, ErrDuplicateKeyID},
+		{"private key", Key{Value: keys[2], KeyID: "c"}, ErrMixedKeys},
+	}
+
+	for _, tt := range tests {
+		if err := set.Merge(JSONWebKeySet{Keys: []Key{tt.other}}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("failed merges changed the set: %d keys", len(set.Keys))
+	}
+}
+
+func TestJSONWebKeySetValidate(t *testing.T) {
+	keys := generateKeys(t, 2)
+
+	tests := []struct {
+		name string
+		set  JSONWebKeySet
+		want []error
+		msg  string
+	}{
+		{
+			name: "valid",
+			set:  JSONWebKeySet{Keys: []Key{{Value: &keys[0].PublicKey, KeyID: "a"}, {Value: &keys[1].PublicKey}, {Value: &keys[0].PublicKey}}},
+		},
+		{
+			name: "duplicate kid",
+			set:  JSONWebKeySet{Keys: []Key{{Value: &keys[0].PublicKey, KeyID: "a"}, {Value: &keys[1].PublicKey, KeyID: "b"}, {Value: &keys[1].PublicKey, KeyID: "a"}}},
+			want: []error{ErrDuplicateKeyID},
+			msg:  "duplicate kid: keys[0] and keys[2] are both a",
+		},
+		{
+			name: "mixed",
+			set:  JSONWebKeySet{Keys: []Key{{Value: keys[0], KeyID: "a"}, {Value: &keys[1].PublicKey, KeyID: "b"}, {Value: keys[1], KeyID: "c"}}},
+			want: []error{ErrMixedKeys},
+			msg:  "set mixes private and public keys: private keys[0], keys[2], public keys[1]",
+		},
+		{
+			name: "both",
+			set:  JSONWebKeySet{Keys: []Key{{Value: keys[0], KeyID: "a"}, {Value: &keys[1].PublicKey, KeyID: "a"}}},
+			want: []error{ErrDuplicateKeyID, ErrMixedKeys},
+			msg:  "duplicate kid: keys[0] and keys[1] are both a\nset mixes private and public keys: private keys[0], public keys[1]",
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.set.Validate()
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, want)
+			}
+		}
+		if err != nil && err.Error() != tt.msg {
+			t.Errorf("%s: got %q, want %q", tt.name, err, tt.msg)
+		}
+	}
+}
+
+func TestJSONWebKeySetLookup(t *testing.T) {
+	keys := generateKeys(t, 1)
+	pub := &keys[0].PublicKey
+
+	set := JSONWebKeySet{Keys: []Key{
+		{Value: pub, KeyID: "sig", Algorithm: "RS256", Use: "sig"},
+		{Value: pub, KeyID: "enc", Algorithm: "RSA-OAEP", Use: "enc"},
+		{Value: pub, KeyID: "any"},
+	}}
+
+	tests := []struct {
+		kid, alg, use string
+		want          []string
+	}{
+		{"", "", "", []string{"sig", "enc", "any"}},
+		{"enc", "", "", []string{"enc"}},
+		{"", "RS256", "", []string{"sig", "any"}},
+		{"", "", "enc", []string{"enc", "any"}},
+		{"sig", "RSA-OAEP", "", []string{}},
+		{"missing", "", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, k := range set.Lookup(tt.kid, tt.alg, tt.use) {
+			got = append(got, k.KeyID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Lookup(%q, %q, %q): got %q, want %q", tt.kid, tt.alg, tt.use, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Lookup(%q, %q, %q): got %q, want %q", tt.kid, tt.alg, tt.use, got, tt.want)
+			}
+		}
+	}
+
+	if _, ok := set.Key("enc"); !ok {
+		t.Error("Key: enc not found")
+	}
+	if _, ok := set.Key("missing"); ok {
+		t.Error("Key: found a missing kid")
+	}
+}
+
+func TestJSONWebKeySetUnmarshal(t *testing.T) {
+	var set JSONWebKeySet
+	if err := json.Unmarshal([]byte(`{"keys":[`+rfc7638Key+`]}`), &set); err != nil {
+		t.Fatal(err)
+	}
+	k, ok := set.Key("2011-04-29")
+	if !ok || k.Algorithm != "RS256" || k.IsPrivate() {
+		t.Fatalf("got %+v", set)
+	}
+
+	tests := []struct {
+		name, data, want string
+	}{
+		{"no keys member", `{"key":[]}`, `missing "keys" member`},
+		{"bad second key", `{"keys":[` + rfc7638Key + `,{"kty":"EC"}]}`, "keys[1]: unsupported key type"},
+	}
+	for _, tt := range tests {
+		var set JSONWebKeySet
+		if err := json.Unmarshal([]byte(tt.data), &set); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	b, err := json.Marshal(JSONWebKeySet{})
+	if err != nil || string(b) != `{"keys":[]}` {
+		t.Fatalf("empty set: got %s, %v", b, err)
+	}
+}
+
+func TestJSONWebKeySetPublic(t *testing.T) {
+	keys := generateKeys(t, 2)
+	set := JSONWebKeySet{Keys: []Key{{Value: keys[0], KeyID: "a", KeyOps: []string{KeyOpSign}}, {Value: keys[1], KeyID: "b"}}}
+
+	public := set.Public()
+	if err := public.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range public.Keys {
+		if k.IsPrivate() || k.KeyID != set.Keys[i].KeyID {
+			t.Fatalf("keys[%d]: got %+v", i, k)
+		}
+	}
+	if !set.Keys[0].IsPrivate() {
+		t.Fatal("Public changed the original set")
+	}
+
+	b, err := json.Marshal(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &members); err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range members.Keys {
+		for _, name := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+			if _, ok := k[name]; ok {
+				t.Errorf("keys[%d] has %q", i, name)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rsa"
 	"encoding/json"
 	"errors"
+	"slices"
 
 	"github.com/tuanta7/keys/internal/config"
 )
@@ -13,6 +14,16 @@ type Key struct {
 	Algorithm string
 	KeyID     string
 	Use       string
+	KeyOps    []string
+
+	Extractable *bool
+	X509URL     string
+	X509Chain   []string // standard base64 DER certificates, the first one holding Value
+	X509SHA1    []byte
+	X509SHA256  []byte
+
+	// Extra holds unregistered JWK members, written back unchanged.
+	Extra map[string]json.RawMessage
 }
 
 func (k Key) MarshalJSON() (b []byte, err error) {
@@ -34,10 +45,40 @@ func (k Key) MarshalJSON() (b []byte, err error) {
 	jwk.Use = k.Use
 	jwk.Algorithm = k.Algorithm
 	jwk.KeyID = k.KeyID
+	jwk.KeyOps = k.KeyOps
+	jwk.Extractable = k.Extractable
+	jwk.X509URL = k.X509URL
+	jwk.X509Chain = k.X509Chain
+	jwk.X509SHA1 = NewBytes(k.X509SHA1)
+	jwk.X509SHA256 = NewBytes(k.X509SHA256)
+	jwk.Extra = k.Extra
 
 	return json.Marshal(jwk)
 }
 
+// Public returns the public half of the key with the same metadata. key_ops
+// are mapped to what the public key does with them: sign becomes verify,
+// decrypt encrypt and unwrapKey wrapKey. When none has a public counterpart,
+// key_ops is kept as an empty list, since leaving it out would allow every
+// operation.
+func (k Key) Public() Key {
+	public := k
+	if prv, ok := k.Value.(*rsa.PrivateKey); ok {
+		public.Value = &prv.PublicKey
+	}
+
+	if k.KeyOps != nil {
+		public.KeyOps = []string{}
+		for _, op := range k.KeyOps {
+			if pub, ok := publicKeyOps[op]; ok && !slices.Contains(public.KeyOps, pub) {
+				public.KeyOps = append(public.KeyOps, pub)
+			}
+		}
+	}
+
+	return public
+}
+
 func (k *Key) UnmarshalJSON(b []byte) error {
 	var jwk JSONWebKey
 	err := json.Unmarshal(b, &jwk)
@@ -58,10 +99,29 @@ func (k *Key) UnmarshalJSON(b []byte) error {
 		return errors.New("unsupported key type")
 	}
 
+	if err != nil {
+		return err
+	}
+
+	if err := jwk.checkX509(key); err != nil {
+		return err
+	}
+
 	k.Value = key
 	k.Algorithm = jwk.Algorithm
 	k.KeyID = jwk.KeyID
 	k.Use = jwk.Use
+	k.KeyOps = jwk.KeyOps
+	k.Extractable = jwk.Extractable
+	k.X509URL = jwk.X509URL
+	k.X509Chain = jwk.X509Chain
+	if jwk.X509SHA1 != nil {
+		k.X509SHA1 = jwk.X509SHA1.Bytes()
+	}
+	if jwk.X509SHA256 != nil {
+		k.X509SHA256 = jwk.X509SHA256.Bytes()
+	}
+	k.Extra = jwk.Extra
 
 	return nil
 }
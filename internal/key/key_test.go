@@ -0,0 +1,64 @@
+package key
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPublicKeyOps(t *testing.T) {
+	prv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ops  []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{KeyOpSign}, []string{KeyOpVerify}},
+		{[]string{KeyOpDecrypt}, []string{KeyOpEncrypt}},
+		{[]string{KeyOpUnwrapKey}, []string{KeyOpWrapKey}},
+		{[]string{KeyOpSign, KeyOpVerify}, []string{KeyOpVerify}},
+		{[]string{KeyOpDecrypt, KeyOpUnwrapKey, KeyOpEncrypt}, []string{KeyOpEncrypt, KeyOpWrapKey}},
+		{[]string{KeyOpDeriveBits}, []string{}},
+		{[]string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		public := Key{Value: prv, KeyOps: tt.ops}.Public()
+		if _, ok := public.Value.(*rsa.PublicKey); !ok {
+			t.Fatalf("%v: got a %T", tt.ops, public.Value)
+		}
+		if !slices.Equal(public.KeyOps, tt.want) || (public.KeyOps == nil) != (tt.want == nil) {
+			t.Errorf("%v: got %#v, want %#v", tt.ops, public.KeyOps, tt.want)
+		}
+	}
+}
+
+func TestPublicKeyOpsNeverOmitted(t *testing.T) {
+	prv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(Key{Value: prv, KeyOps: []string{KeyOpDeriveKey}}.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"key_ops":[]`) {
+		t.Fatalf("key_ops left out: %s", b)
+	}
+
+	b, err = json.Marshal(Key{Value: prv}.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "key_ops") {
+		t.Fatalf("key_ops added: %s", b)
+	}
+}
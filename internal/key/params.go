@@ -0,0 +1,42 @@
+package key
+
+// Values of the "use" parameter (RFC 7517, section 4.2).
+const (
+	UseSignature  = "sig"
+	UseEncryption = "enc"
+)
+
+// Values of the "key_ops" parameter (RFC 7517, section 4.3).
+const (
+	KeyOpSign       = "sign"
+	KeyOpVerify     = "verify"
+	KeyOpEncrypt    = "encrypt"
+	KeyOpDecrypt    = "decrypt"
+	KeyOpWrapKey    = "wrapKey"
+	KeyOpUnwrapKey  = "unwrapKey"
+	KeyOpDeriveKey  = "deriveKey"
+	KeyOpDeriveBits = "deriveBits"
+)
+
+var KeyOps = []string{
+	KeyOpSign, KeyOpVerify, KeyOpEncrypt, KeyOpDecrypt,
+	KeyOpWrapKey, KeyOpUnwrapKey, KeyOpDeriveKey, KeyOpDeriveBits,
+}
+
+// publicKeyOps maps each operation to the one the public key performs in its
+// place; deriveKey and deriveBits don't apply to RSA.
+var publicKeyOps = map[string]string{
+	KeyOpSign:      KeyOpVerify,
+	KeyOpVerify:    KeyOpVerify,
+	KeyOpDecrypt:   KeyOpEncrypt,
+	KeyOpEncrypt:   KeyOpEncrypt,
+	KeyOpUnwrapKey: KeyOpWrapKey,
+	KeyOpWrapKey:   KeyOpWrapKey,
+}
+
+// RSAAlgorithms are the "alg" values RFC 7518 defines for RSA keys.
+var RSAAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"RSA1_5", "RSA-OAEP", "RSA-OAEP-256",
+}
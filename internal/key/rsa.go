@@ -15,7 +15,13 @@ func rsaPublicKeyToJWK(publicKey *rsa.PublicKey) (*JSONWebKey, error) {
 }
 
 func rsaPrivateKeyToJWK(publicKey *rsa.PrivateKey) (*JSONWebKey, error) {
-	return &JSONWebKey{
+	if publicKey.Precomputed.Dp == nil || len(publicKey.Precomputed.CRTValues) != len(publicKey.Primes)-2 {
+		precomputed := *publicKey
+		precomputed.Precompute()
+		publicKey = &precomputed
+	}
+
+	jwk := &JSONWebKey{
 		KeyType:         config.KeyTypeRSA,
 		Modulus:         NewBytes(publicKey.N.Bytes()),
 		PublicExponent:  NewBytes(IntToBigEndian(publicKey.E)),
@@ -25,5 +31,16 @@ func rsaPrivateKeyToJWK(publicKey *rsa.PrivateKey) (*JSONWebKey, error) {
 		Dp:              NewBytes(publicKey.Precomputed.Dp.Bytes()),
 		Dq:              NewBytes(publicKey.Precomputed.Dq.Bytes()),
 		Qi:              NewBytes(publicKey.Precomputed.Qinv.Bytes()),
-	}, nil
+	}
+
+	for i, prime := range publicKey.Primes[2:] {
+		crt := publicKey.Precomputed.CRTValues[i]
+		jwk.OtherPrimes = append(jwk.OtherPrimes, OtherPrimeInfo{
+			Prime:       NewBytes(prime.Bytes()),
+			Exponent:    NewBytes(crt.Exp.Bytes()),
+			Coefficient: NewBytes(crt.Coeff.Bytes()),
+		})
+	}
+
+	return jwk, nil
 }
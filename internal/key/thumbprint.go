@@ -0,0 +1,46 @@
+package key
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of the key's public part.
+func (k Key) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	var publicKey *rsa.PublicKey
+	switch t := k.Value.(type) {
+	case *rsa.PublicKey:
+		publicKey = t
+	case *rsa.PrivateKey:
+		publicKey = &t.PublicKey
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function unavailable: %s", hash)
+	}
+
+	// The required members in lexicographic order, without whitespace.
+	members, err := json.Marshal(struct {
+		E   *Bytes `json:"e"`
+		Kty string `json:"kty"`
+		N   *Bytes `json:"n"`
+	}{
+		E:   NewBytes(IntToBigEndian(publicKey.E)),
+		Kty: config.KeyTypeRSA,
+		N:   NewBytes(publicKey.N.Bytes()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(members)
+	return h.Sum(nil), nil
+}
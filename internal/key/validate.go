@@ -0,0 +1,575 @@
+package key
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tuanta7/keys/internal/config"
+)
+
+// ValidationError is a problem found by Validate, located by a JSONPath such as
+// "$.keys[2].qi".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// rsaIntegerMembers are the Base64urlUInt members of an RSA JWK (RFC 7518, section 6.3).
+var rsaIntegerMembers = []string{"n", "e", "d", "p", "q", "dp", "dq", "qi"}
+
+// maxPublicExponent is the largest exponent crypto/rsa and most other libraries accept.
+const maxPublicExponent = 1<<31 - 1
+
+// Validate checks a JWK or a JWK Set ({"keys":[...]}) more strictly than
+// UnmarshalJSON does and returns every problem found, or nil. Besides malformed
+// keys it reports non-canonical base64url (padding, the standard alphabet,
+// leading zero octets), CRT values inconsistent with the primes, exponents over
+// 2^31-1, "use", "key_ops" and "alg" that contradict each other, duplicate
+// members and, in a set, duplicate kids and mixed private and public keys.
+func Validate(data []byte) []*ValidationError {
+	errs, _ := validate(data)
+	return errs
+}
+
+// ParseStrict decodes a JWK, rejecting anything Validate reports.
+func ParseStrict(data []byte) (Key, error) {
+	var k Key
+	errs, isSet := validate(data)
+	if len(errs) > 0 {
+		return k, joinValidationErrors(errs)
+	}
+	if isSet {
+		return k, errors.New("$: expected a JWK, found a JWK Set")
+	}
+	err := json.Unmarshal(data, &k)
+	return k, err
+}
+
+// ParseSetStrict decodes a JWK Set, rejecting anything Validate reports.
+func ParseSetStrict(data []byte) (JSONWebKeySet, error) {
+	var s JSONWebKeySet
+	errs, isSet := validate(data)
+	if len(errs) > 0 {
+		return s, joinValidationErrors(errs)
+	}
+	if !isSet {
+		return s, errors.New(`$: expected a JWK Set, missing "keys"`)
+	}
+	err := json.Unmarshal(data, &s)
+	return s, err
+}
+
+func validate(data []byte) (errs []*ValidationError, isSet bool) {
+	v := &validator{}
+
+	members, ok := v.object("$", data)
+	if !ok {
+		return v.errs, false
+	}
+
+	if keys, isSet := members["keys"]; isSet {
+		v.set("$", keys)
+		return v.errs, true
+	}
+
+	v.key("$", members)
+	return v.errs, false
+}
+
+func joinValidationErrors(errs []*ValidationError) error {
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+type validator struct {
+	errs []*ValidationError
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// object decodes a JSON object, reporting duplicate member names, which
+// encoding/json silently resolves to the last value.
+func (v *validator) object(path string, data []byte) (map[string]json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		v.fail(path, "not a JSON object")
+		return nil, false
+	}
+
+	members := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			v.fail(path, "malformed JSON: %v", err)
+			return nil, false
+		}
+		name := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			v.fail(memberPath(path, name), "malformed JSON: %v", err)
+			return nil, false
+		}
+
+		if _, dup := members[name]; dup {
+			v.fail(memberPath(path, name), "duplicate member")
+		}
+		members[name] = value
+	}
+
+	if _, err := dec.Token(); err != nil {
+		v.fail(path, "malformed JSON: %v", err)
+		return nil, false
+	}
+
+	if dec.More() {
+		v.fail(path, "trailing data after the JSON object")
+	}
+
+	return members, true
+}
+
+func (v *validator) set(path string, data json.RawMessage) {
+	keysPath := path + ".keys"
+
+	var keys []json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		v.fail(keysPath, "not an array")
+		return
+	}
+
+	kids := map[string]int{}
+	kinds := map[bool][]int{}
+	for i, raw := range keys {
+		keyPath := fmt.Sprintf("%s[%d]", keysPath, i)
+		members, ok := v.object(keyPath, raw)
+		if !ok {
+			continue
+		}
+
+		v.key(keyPath, members)
+
+		var kid string
+		if json.Unmarshal(members["kid"], &kid) == nil && kid != "" {
+			if j, dup := kids[kid]; dup {
+				v.fail(keyPath+".kid", "duplicate kid, also used by %s[%d]", keysPath, j)
+			} else {
+				kids[kid] = i
+			}
+		}
+
+		_, private := members["d"]
+		kinds[private] = append(kinds[private], i)
+	}
+
+	if len(kinds[true]) > 0 && len(kinds[false]) > 0 {
+		minority, kind, other := kinds[false], "public", "private"
+		if len(kinds[true]) < len(kinds[false]) {
+			minority, kind, other = kinds[true], "private", "public"
+		}
+		for _, i := range minority {
+			v.fail(fmt.Sprintf("%s[%d]", keysPath, i), "%s key in a set of %s keys", kind, other)
+		}
+	}
+}
+
+func (v *validator) key(path string, members map[string]json.RawMessage) {
+	var kty string
+	if !v.stringMember(path, members, "kty", &kty) {
+		if _, ok := members["kty"]; !ok {
+			v.fail(path, `missing "kty"`)
+		}
+		return
+	}
+
+	if kty != config.KeyTypeRSA {
+		v.fail(memberPath(path, "kty"), "unsupported key type %q", kty)
+		return
+	}
+
+	ints := map[string]*big.Int{}
+	for _, name := range rsaIntegerMembers {
+		if raw, ok := members[name]; ok {
+			if n := v.uint(memberPath(path, name), raw); n != nil {
+				ints[name] = n
+			}
+		}
+	}
+
+	for _, name := range []string{"n", "e"} {
+		if _, ok := members[name]; !ok {
+			v.fail(path, "missing %q", name)
+		}
+	}
+
+	if e := ints["e"]; e != nil {
+		switch {
+		case e.Cmp(big.NewInt(maxPublicExponent)) > 0:
+			v.fail(memberPath(path, "e"), "exponent over 2^31-1")
+		case e.Cmp(big.NewInt(3)) < 0 || e.Bit(0) == 0:
+			v.fail(memberPath(path, "e"), "exponent must be odd and at least 3")
+		}
+	}
+
+	v.private(path, members, ints)
+	v.metadata(path, members)
+	v.certificates(path, members, ints)
+}
+
+// private checks the private members: "d" alone, or with all of p, q, dp, dq
+// and qi, whose values have to agree with each other.
+func (v *validator) private(path string, members map[string]json.RawMessage, ints map[string]*big.Int) {
+	optional := []string{"p", "q", "dp", "dq", "qi"}
+
+	present := slices.ContainsFunc(optional, func(name string) bool { _, ok := members[name]; return ok })
+	_, hasOth := members["oth"]
+	_, hasD := members["d"]
+
+	if !hasD {
+		if present || hasOth {
+			v.fail(path, `private key members without "d"`)
+		}
+		return
+	}
+
+	if !present {
+		if hasOth {
+			v.fail(memberPath(path, "oth"), `"oth" requires p, q, dp, dq and qi`)
+		}
+		return
+	}
+
+	for _, name := range optional {
+		if _, ok := members[name]; !ok {
+			v.fail(path, "missing %q, required along with the other CRT members", name)
+		}
+	}
+
+	n, e, d, p, q := ints["n"], ints["e"], ints["d"], ints["p"], ints["q"]
+	if n == nil || e == nil || d == nil || p == nil || q == nil {
+		return
+	}
+
+	primes := []*big.Int{p, q}
+	primePaths := []string{memberPath(path, "p"), memberPath(path, "q")}
+	var others []OtherPrimeInfo
+	if raw, ok := members["oth"]; ok {
+		others = v.otherPrimes(memberPath(path, "oth"), raw)
+		if others == nil {
+			return
+		}
+		for i, o := range others {
+			primes = append(primes, o.Prime.BigInt())
+			primePaths = append(primePaths, fmt.Sprintf("%s.oth[%d].r", path, i))
+		}
+	}
+
+	// The CRT checks below divide by p-1, q-1 and r_i-1.
+	one := big.NewInt(1)
+	valid := true
+	for i, prime := range primes {
+		if prime.Cmp(one) <= 0 {
+			v.fail(primePaths[i], "not a prime, must be greater than 1")
+			valid = false
+		}
+	}
+	if !valid {
+		return
+	}
+
+	product := big.NewInt(1)
+	for _, prime := range primes {
+		product.Mul(product, prime)
+	}
+	if product.Cmp(n) != 0 {
+		v.fail(path, "the primes don't multiply to n")
+		return
+	}
+
+	if e.Cmp(big.NewInt(maxPublicExponent)) <= 0 {
+		key := &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())}, D: d, Primes: primes}
+		if err := key.Validate(); err != nil {
+			v.fail(memberPath(path, "d"), "inconsistent with n, e and the primes: %v", err)
+			return
+		}
+	}
+
+	// want is nil when the inverse doesn't exist, because the primes share a factor.
+	crt := func(name string, want *big.Int) {
+		if got := ints[name]; got != nil && (want == nil || got.Cmp(want) != 0) {
+			v.fail(memberPath(path, name), "inconsistent with d, p and q")
+		}
+	}
+	crt("dp", new(big.Int).Mod(d, new(big.Int).Sub(p, one)))
+	crt("dq", new(big.Int).Mod(d, new(big.Int).Sub(q, one)))
+	crt("qi", new(big.Int).ModInverse(q, p))
+
+	// RFC 7518, section 6.3.2.7: d_i = d mod (r_i - 1), t_i = (r_1 * ... * r_(i-1))^-1 mod r_i.
+	r := new(big.Int).Mul(p, q)
+	for i, o := range others {
+		prime := o.Prime.BigInt()
+		othPath := fmt.Sprintf("%s.oth[%d]", path, i)
+		if o.Exponent.BigInt().Cmp(new(big.Int).Mod(d, new(big.Int).Sub(prime, one))) != 0 {
+			v.fail(othPath+".d", "inconsistent with d and r")
+		}
+		if t := new(big.Int).ModInverse(r, prime); t == nil || o.Coefficient.BigInt().Cmp(t) != 0 {
+			v.fail(othPath+".t", "inconsistent with the preceding primes")
+		}
+		r.Mul(r, prime)
+	}
+}
+
+// otherPrimes decodes "oth", returning nil unless every entry is valid.
+func (v *validator) otherPrimes(path string, raw json.RawMessage) []OtherPrimeInfo {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil || len(entries) == 0 {
+		v.fail(path, "not a non-empty array")
+		return nil
+	}
+
+	var others []OtherPrimeInfo
+	allValid := true
+	for i, entry := range entries {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		members, ok := v.object(entryPath, entry)
+		if !ok {
+			allValid = false
+			continue
+		}
+
+		var info OtherPrimeInfo
+		valid := true
+		for _, m := range []struct {
+			name string
+			dst  **Bytes
+		}{{"r", &info.Prime}, {"d", &info.Exponent}, {"t", &info.Coefficient}} {
+			raw, ok := members[m.name]
+			if !ok {
+				v.fail(entryPath, "missing %q", m.name)
+				valid = false
+				continue
+			}
+			n := v.uint(memberPath(entryPath, m.name), raw)
+			if n == nil {
+				valid = false
+				continue
+			}
+			*m.dst = NewBytes(n.Bytes())
+		}
+
+		allValid = allValid && valid
+		others = append(others, info)
+	}
+
+	if !allValid {
+		return nil
+	}
+	return others
+}
+
+// metadata checks "use", "key_ops", "alg", "kid", "ext" and "x5u".
+func (v *validator) metadata(path string, members map[string]json.RawMessage) {
+	var use, alg, kid, x5u string
+	hasUse := v.stringMember(path, members, "use", &use)
+	hasAlg := v.stringMember(path, members, "alg", &alg)
+	v.stringMember(path, members, "kid", &kid)
+
+	if hasUse && use != UseSignature && use != UseEncryption {
+		v.fail(memberPath(path, "use"), "unknown use %q", use)
+	}
+
+	if hasAlg && !slices.Contains(RSAAlgorithms, alg) {
+		v.fail(memberPath(path, "alg"), "%q is not an RSA algorithm", alg)
+	}
+
+	if hasUse && hasAlg {
+		if algUse := algorithmUse(alg); algUse != "" && algUse != use {
+			v.fail(memberPath(path, "use"), "%q conflicts with alg %q", use, alg)
+		}
+	}
+
+	if raw, ok := members["key_ops"]; ok {
+		var ops []string
+		if err := json.Unmarshal(raw, &ops); err != nil {
+			v.fail(memberPath(path, "key_ops"), "not an array of strings")
+		} else {
+			opsPath := memberPath(path, "key_ops")
+			for i, op := range ops {
+				opPath := fmt.Sprintf("%s[%d]", opsPath, i)
+				switch {
+				case !slices.Contains(KeyOps, op):
+					v.fail(opPath, "unknown key operation %q", op)
+				case slices.Index(ops, op) < i:
+					v.fail(opPath, "duplicate key operation %q", op)
+				case hasUse && !slices.Contains(useKeyOps[use], op):
+					v.fail(opPath, "%q conflicts with use %q", op, use)
+				}
+			}
+		}
+	}
+
+	if raw, ok := members["ext"]; ok {
+		var ext bool
+		if err := json.Unmarshal(raw, &ext); err != nil {
+			v.fail(memberPath(path, "ext"), "not a boolean")
+		}
+	}
+
+	if v.stringMember(path, members, "x5u", &x5u) {
+		if u, err := url.Parse(x5u); err != nil || !u.IsAbs() {
+			v.fail(memberPath(path, "x5u"), "not an absolute URL")
+		}
+	}
+}
+
+// certificates checks x5t and x5t#S256, then lets checkX509 compare the x5c
+// chain with the key.
+func (v *validator) certificates(path string, members map[string]json.RawMessage, ints map[string]*big.Int) {
+	for _, name := range []string{"x5t", "x5t#S256"} {
+		if raw, ok := members[name]; ok {
+			v.base64url(memberPath(path, name), raw)
+		}
+	}
+
+	raw, ok := members["x5c"]
+	if !ok {
+		return
+	}
+
+	var chain []string
+	if err := json.Unmarshal(raw, &chain); err != nil || len(chain) == 0 {
+		v.fail(memberPath(path, "x5c"), "not a non-empty array of strings")
+		return
+	}
+
+	for i, cert := range chain {
+		if _, err := base64.StdEncoding.Strict().DecodeString(cert); err != nil {
+			v.fail(fmt.Sprintf("%s[%d]", memberPath(path, "x5c"), i), "not standard base64 (x5c doesn't use base64url)")
+			return
+		}
+	}
+
+	if ints["n"] == nil || ints["e"] == nil || !ints["e"].IsInt64() {
+		return
+	}
+
+	jwk := JSONWebKey{X509Chain: chain}
+	for name, dst := range map[string]**Bytes{"x5t": &jwk.X509SHA1, "x5t#S256": &jwk.X509SHA256} {
+		var s string
+		if json.Unmarshal(members[name], &s) == nil {
+			if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+				*dst = NewBytes(b)
+			}
+		}
+	}
+
+	pub := &rsa.PublicKey{N: ints["n"], E: int(ints["e"].Int64())}
+	if err := jwk.checkX509(pub); err != nil {
+		v.fail(path, "%v", err)
+	}
+}
+
+// uint decodes a Base64urlUInt: canonical base64url of the minimal big-endian
+// octets, a single zero octet for zero.
+func (v *validator) uint(path string, raw json.RawMessage) *big.Int {
+	b, ok := v.base64url(path, raw)
+	if !ok {
+		return nil
+	}
+
+	if len(b) == 0 {
+		v.fail(path, "empty value")
+		return nil
+	}
+
+	if len(b) > 1 && b[0] == 0 {
+		v.fail(path, "leading zero octets")
+		return nil
+	}
+
+	return new(big.Int).SetBytes(b)
+}
+
+// base64url decodes a string member, which must be unpadded base64url with
+// zero trailing bits.
+func (v *validator) base64url(path string, raw json.RawMessage) ([]byte, bool) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		v.fail(path, "not a string")
+		return nil, false
+	}
+
+	switch {
+	case strings.Contains(s, "="):
+		v.fail(path, "not canonical base64url (padded)")
+		return nil, false
+	case strings.ContainsAny(s, "+/"):
+		v.fail(path, "not canonical base64url (standard base64 alphabet)")
+		return nil, false
+	}
+
+	b, err := base64.RawURLEncoding.Strict().DecodeString(s)
+	if err != nil {
+		v.fail(path, "not canonical base64url")
+		return nil, false
+	}
+
+	return b, true
+}
+
+// stringMember decodes an optional string member and reports whether it is present and valid.
+func (v *validator) stringMember(path string, members map[string]json.RawMessage, name string, dst *string) bool {
+	raw, ok := members[name]
+	if !ok {
+		return false
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		v.fail(memberPath(path, name), "not a string")
+		return false
+	}
+
+	return true
+}
+
+// useKeyOps are the key operations consistent with each "use" (RFC 7517, section 4.3).
+var useKeyOps = map[string][]string{
+	UseSignature:  {KeyOpSign, KeyOpVerify},
+	UseEncryption: {KeyOpEncrypt, KeyOpDecrypt, KeyOpWrapKey, KeyOpUnwrapKey, KeyOpDeriveKey, KeyOpDeriveBits},
+}
+
+func algorithmUse(alg string) string {
+	switch {
+	case strings.HasPrefix(alg, "RSA"):
+		return UseEncryption
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return UseSignature
+	}
+	return ""
+}
+
+func memberPath(path, name string) string {
+	for _, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return fmt.Sprintf("%s[%q]", path, name)
+		}
+	}
+	return path + "." + name
+}
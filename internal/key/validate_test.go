@@ -0,0 +1,255 @@
+package key
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// jwkMembers returns the members of the private JWK of key.
+func jwkMembers(t *testing.T, key *rsa.PrivateKey) map[string]any {
+	t.Helper()
+	b, err := json.Marshal(Key{Value: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var members map[string]any
+	if err := json.Unmarshal(b, &members); err != nil {
+		t.Fatal(err)
+	}
+	return members
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func b64Int(n *big.Int) string {
+	return b64(n.Bytes())
+}
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func validationMessages(errs []*ValidationError) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
+}
+
+func TestValidateKey(t *testing.T) {
+	key := generateKeys(t, 1)[0]
+	key.Precompute()
+
+	tests := []struct {
+		name   string
+		modify func(m map[string]any)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(m map[string]any) {},
+		},
+		{
+			name:   "padded base64url",
+			modify: func(m map[string]any) { m["qi"] = m["qi"].(string) + "=" },
+			want:   []string{"$.qi: not canonical base64url (padded)"},
+		},
+		{
+			name:   "standard base64 alphabet",
+			modify: func(m map[string]any) { m["dp"] = "ab+/" },
+			want:   []string{"$.dp: not canonical base64url (standard base64 alphabet)"},
+		},
+		{
+			name:   "non-zero trailing bits",
+			modify: func(m map[string]any) { m["e"] = "AQAB" + "B" },
+			want:   []string{"$.e: not canonical base64url"},
+		},
+		{
+			name:   "leading zero octet in n",
+			modify: func(m map[string]any) { m["n"] = b64(append([]byte{0}, key.N.Bytes()...)) },
+			want:   []string{"$.n: leading zero octets"},
+		},
+		{
+			name:   "inconsistent dp",
+			modify: func(m map[string]any) { m["dp"] = b64Int(new(big.Int).Add(key.Precomputed.Dp, big.NewInt(2))) },
+			want:   []string{"$.dp: inconsistent with d, p and q"},
+		},
+		{
+			name:   "inconsistent qi",
+			modify: func(m map[string]any) { m["qi"] = b64Int(new(big.Int).Add(key.Precomputed.Qinv, big.NewInt(1))) },
+			want:   []string{"$.qi: inconsistent with d, p and q"},
+		},
+		{
+			name:   "primes not multiplying to n",
+			modify: func(m map[string]any) { m["q"] = b64Int(new(big.Int).Add(key.Primes[1], big.NewInt(2))) },
+			want:   []string{"$: the primes don't multiply to n"},
+		},
+		{
+			name:   "exponent over 2^31-1",
+			modify: func(m map[string]any) { m["e"] = b64([]byte{0x80, 0, 0, 1}) },
+			want:   []string{"$.e: exponent over 2^31-1"},
+		},
+		{
+			name:   "missing CRT member",
+			modify: func(m map[string]any) { delete(m, "dq") },
+			want:   []string{`$: missing "dq", required along with the other CRT members`},
+		},
+		{
+			name: "use conflicting with key_ops",
+			modify: func(m map[string]any) {
+				m["use"] = "sig"
+				m["key_ops"] = []string{"sign", "encrypt"}
+			},
+			want: []string{`$.key_ops[1]: "encrypt" conflicts with use "sig"`},
+		},
+		{
+			name: "alg conflicting with use",
+			modify: func(m map[string]any) {
+				m["use"] = "sig"
+				m["alg"] = "RSA-OAEP"
+			},
+			want: []string{`$.use: "sig" conflicts with alg "RSA-OAEP"`},
+		},
+		{
+			name:   "unknown and duplicate key_ops",
+			modify: func(m map[string]any) { m["key_ops"] = []string{"sign", "sign", "launch"} },
+			want:   []string{`$.key_ops[1]: duplicate key operation "sign"`, `$.key_ops[2]: unknown key operation "launch"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := jwkMembers(t, key)
+			tt.modify(m)
+
+			got := validationMessages(Validate(marshal(t, m)))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateHostileKey checks keys crafted to break the CRT arithmetic; they
+// have to be reported, not panic.
+func TestValidateHostileKey(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  string
+		want []string
+	}{
+		{
+			name: "p of 1 with an exponent over 2^31-1",
+			jwk:  `{"kty":"RSA","n":"Dw","e":"gAAAAQ","d":"AQ","p":"AQ","q":"Dw","dp":"AQ","dq":"AQ","qi":"AQ"}`,
+			want: []string{"$.e: exponent over 2^31-1", "$.p: not a prime, must be greater than 1"},
+		},
+		{
+			name: "q of 1",
+			jwk:  `{"kty":"RSA","n":"Dw","e":"AQAB","d":"AQ","p":"Dw","q":"AQ","dp":"AQ","dq":"AQ","qi":"AQ"}`,
+			want: []string{"$.q: not a prime, must be greater than 1"},
+		},
+		{
+			name: "oth r of 1",
+			jwk:  `{"kty":"RSA","n":"Dw","e":"gAAAAQ","d":"AQ","p":"Aw","q":"BQ","dp":"AQ","dq":"AQ","qi":"Ag","oth":[{"r":"AQ","d":"AQ","t":"AQ"}]}`,
+			want: []string{"$.e: exponent over 2^31-1", "$.oth[0].r: not a prime, must be greater than 1"},
+		},
+		{
+			name: "p equal to q",
+			jwk:  `{"kty":"RSA","n":"CQ","e":"gAAAAQ","d":"AQ","p":"Aw","q":"Aw","dp":"AQ","dq":"AQ","qi":"AQ"}`,
+			want: []string{"$.e: exponent over 2^31-1", "$.qi: inconsistent with d, p and q"},
+		},
+		{
+			name: "oth sharing a factor",
+			jwk:  `{"kty":"RSA","n":"LQ","e":"gAAAAQ","d":"AQ","p":"Aw","q":"BQ","dp":"AQ","dq":"AQ","qi":"Ag","oth":[{"r":"Aw","d":"AQ","t":"AQ"}]}`,
+			want: []string{"$.e: exponent over 2^31-1", "$.oth[0].t: inconsistent with the preceding primes"},
+		},
+		{
+			name: "malformed oth entry",
+			jwk:  `{"kty":"RSA","n":"Dw","e":"AQAB","d":"AQ","p":"Aw","q":"BQ","dp":"AQ","dq":"AQ","qi":"Ag","oth":[{"r":"AQ="},{"r":"Bw","d":"AQ","t":"AQ"}]}`,
+			want: []string{"$.oth[0].r: not canonical base64url (padded)", `$.oth[0]: missing "d"`, `$.oth[0]: missing "t"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validationMessages(Validate([]byte(tt.jwk)))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			if _, err := ParseStrict([]byte(tt.jwk)); err == nil {
+				t.Fatal("ParseStrict accepted the key")
+			}
+		})
+	}
+}
+
+func TestValidateSet(t *testing.T) {
+	prv := generateKeys(t, 3)
+
+	keys := []map[string]any{jwkMembers(t, prv[0]), jwkMembers(t, prv[1]), jwkMembers(t, prv[2])}
+	for i, k := range keys {
+		k["kid"] = string(rune('a' + i))
+	}
+
+	keys[2]["qi"] = keys[2]["qi"].(string) + "="
+	got := validationMessages(Validate(marshal(t, map[string]any{"keys": keys})))
+	want := []string{"$.keys[2].qi: not canonical base64url (padded)"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	keys = []map[string]any{jwkMembers(t, prv[0]), jwkMembers(t, prv[1]), jwkMembers(t, prv[2])}
+	keys[0]["kid"], keys[1]["kid"] = "same", "same"
+	for _, name := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+		delete(keys[2], name)
+	}
+	got = validationMessages(Validate(marshal(t, map[string]any{"keys": keys})))
+	want = []string{
+		"$.keys[1].kid: duplicate kid, also used by $.keys[0]",
+		"$.keys[2]: public key in a set of private keys",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateDuplicateMember(t *testing.T) {
+	got := validationMessages(Validate([]byte(`{"kty":"RSA","n":"Dw","e":"AQAB","e":"Aw"}`)))
+	want := []string{"$.e: duplicate member"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	key := generateKeys(t, 1)[0]
+	m := jwkMembers(t, key)
+	if _, err := ParseStrict(marshal(t, m)); err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+
+	m["n"] = strings.ReplaceAll(strings.ReplaceAll(m["n"].(string), "-", "+"), "_", "/") + "+"
+	_, err := ParseStrict(marshal(t, m))
+	if err == nil || err.Error() != "$.n: not canonical base64url (standard base64 alphabet)" {
+		t.Fatalf("got %v", err)
+	}
+
+	if _, err := ParseStrict(marshal(t, map[string]any{"keys": []any{jwkMembers(t, key)}})); err == nil {
+		t.Fatal("ParseStrict accepted a JWK Set")
+	}
+}
@@ -0,0 +1,65 @@
+package key
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// checkX509 verifies that the first x5c certificate holds the key, that every
+// x5c entry is a certificate, and that x5t and x5t#S256 are hashes of the first.
+func (j JSONWebKey) checkX509(value any) error {
+	if len(j.X509Chain) == 0 {
+		return nil
+	}
+
+	var publicKey *rsa.PublicKey
+	switch v := value.(type) {
+	case *rsa.PublicKey:
+		publicKey = v
+	case *rsa.PrivateKey:
+		publicKey = &v.PublicKey
+	default:
+		return errors.New("unsupported key type")
+	}
+
+	var first []byte
+	for i, encoded := range j.X509Chain {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("x5c[%d]: %w", i, err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("x5c[%d]: %w", i, err)
+		}
+
+		if i == 0 {
+			certKey, ok := cert.PublicKey.(*rsa.PublicKey)
+			if !ok || !certKey.Equal(publicKey) {
+				return errors.New("x5c[0]: the certificate's public key doesn't match the key's modulus and exponent")
+			}
+			first = der
+		}
+	}
+
+	if j.X509SHA1 != nil {
+		if sum := sha1.Sum(first); !bytes.Equal(sum[:], j.X509SHA1.Bytes()) {
+			return errors.New("x5t doesn't match the x5c[0] certificate")
+		}
+	}
+
+	if j.X509SHA256 != nil {
+		if sum := sha256.Sum256(first); !bytes.Equal(sum[:], j.X509SHA256.Bytes()) {
+			return errors.New("x5t#S256 doesn't match the x5c[0] certificate")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package keycrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// legacyCipher is a cipher of the traditional OpenSSL PEM encryption ("DEK-Info" header).
+type legacyCipher struct {
+	name      string
+	keySize   int
+	blockSize int
+	newBlock  func(key []byte) (cipher.Block, error)
+}
+
+var legacyCiphers = []legacyCipher{
+	{"DES-CBC", des.BlockSize, des.BlockSize, des.NewCipher},
+	{"DES-EDE3-CBC", 24, des.BlockSize, des.NewTripleDESCipher},
+	{"AES-128-CBC", 16, aes.BlockSize, aes.NewCipher},
+	{"AES-192-CBC", 24, aes.BlockSize, aes.NewCipher},
+	{"AES-256-CBC", 32, aes.BlockSize, aes.NewCipher},
+}
+
+// IsLegacyEncrypted reports whether the block carries "Proc-Type: 4,ENCRYPTED".
+func IsLegacyEncrypted(block *pem.Block) bool {
+	return strings.EqualFold(block.Headers["Proc-Type"], "4,ENCRYPTED")
+}
+
+// LegacyCipherName returns the cipher named in the "DEK-Info" header.
+func LegacyCipherName(block *pem.Block) string {
+	name, _, _ := strings.Cut(block.Headers["DEK-Info"], ",")
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// DecryptLegacyPEM decrypts the contents of a traditional OpenSSL encrypted PEM
+// block, whose key is derived with EVP_BytesToKey (MD5, one iteration).
+func DecryptLegacyPEM(block *pem.Block, passphrase []byte) ([]byte, error) {
+	dekInfo, ok := block.Headers["DEK-Info"]
+	if !ok {
+		return nil, errors.New("missing DEK-Info header")
+	}
+
+	name, ivHex, ok := strings.Cut(dekInfo, ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed DEK-Info header: %s", dekInfo)
+	}
+
+	var c *legacyCipher
+	for i := range legacyCiphers {
+		if strings.EqualFold(legacyCiphers[i].name, strings.TrimSpace(name)) {
+			c = &legacyCiphers[i]
+		}
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("unsupported legacy PEM cipher: %s", name)
+	}
+
+	iv, err := hex.DecodeString(strings.TrimSpace(ivHex))
+	if err != nil || len(iv) != c.blockSize {
+		return nil, fmt.Errorf("malformed DEK-Info IV: %s", ivHex)
+	}
+
+	if len(block.Bytes) == 0 || len(block.Bytes)%c.blockSize != 0 {
+		return nil, errors.New("encrypted PEM data is not a multiple of the block size")
+	}
+
+	// The first 8 bytes of the IV double as the salt.
+	b, err := c.newBlock(bytesToKey(passphrase, iv[:8], c.keySize))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(block.Bytes))
+	cipher.NewCBCDecrypter(b, iv).CryptBlocks(plaintext, block.Bytes)
+
+	n := int(plaintext[len(plaintext)-1])
+	if n == 0 || n > c.blockSize {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	for _, p := range plaintext[len(plaintext)-n:] {
+		if int(p) != n {
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+	plaintext = plaintext[:len(plaintext)-n]
+
+	var raw asn1.RawValue
+	if rest, err := asn1.Unmarshal(plaintext, &raw); err != nil || len(rest) > 0 {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	return plaintext, nil
+}
+
+// bytesToKey is OpenSSL's EVP_BytesToKey with MD5 and a single iteration.
+func bytesToKey(passphrase, salt []byte, keySize int) []byte {
+	var key, prev []byte
+	for len(key) < keySize {
+		h := md5.New()
+		h.Write(prev)
+		h.Write(passphrase)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		key = append(key, prev...)
+	}
+	return key[:keySize]
+}
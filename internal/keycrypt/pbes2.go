@@ -0,0 +1,476 @@
+// Package keycrypt encrypts and decrypts private keys with a passphrase.
+package keycrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KDFPBKDF2 = "pbkdf2"
+	KDFScrypt = "scrypt"
+
+	CipherAES128CBC = "aes-128-cbc"
+	CipherAES256CBC = "aes-256-cbc"
+	CipherAES128GCM = "aes-128-gcm"
+	CipherAES256GCM = "aes-256-gcm"
+
+	DefaultPBKDF2Iterations = 600000
+	DefaultScryptCost       = 1 << 15
+
+	// The KDF parameters are read from the key file, so they are bounded to keep a
+	// crafted file from pinning the CPU or allocating gigabytes. The limits are
+	// far above what OpenSSL or this tool write.
+	MaxPBKDF2Iterations = 10000000
+	MaxScryptMemory     = 1 << 30 // 128 * N * r bytes
+	MaxScryptWork       = 1 << 24 // N * r * p
+
+	scryptBlockSize       = 8
+	scryptParallelization = 1
+	saltSize              = 16
+	gcmNonceSize          = 12
+	gcmTagSize            = 16
+)
+
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase or corrupted key")
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidAES128GCM      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}
+	oidAES192GCM      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26}
+	oidAES256GCM      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
+)
+
+// Options selects how EncryptPKCS8 protects a key.
+type Options struct {
+	KDF    string
+	Cipher string
+	// Iterations is the PBKDF2 iteration count or the scrypt cost parameter N.
+	// Zero selects the default of the KDF.
+	Iterations int
+}
+
+// EncryptedKey is a parsed PKCS#8 EncryptedPrivateKeyInfo protected with PBES2 (RFC 8018).
+type EncryptedKey struct {
+	kdf    pkix.AlgorithmIdentifier
+	cipher pkix.AlgorithmIdentifier
+	data   []byte
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+type gcmParams struct {
+	Nonce  []byte
+	ICVLen int `asn1:"default:12"`
+}
+
+// ParseEncryptedPKCS8 parses a DER "ENCRYPTED PRIVATE KEY" without decrypting it.
+func ParseEncryptedPKCS8(der []byte) (*EncryptedKey, error) {
+	var info encryptedPrivateKeyInfo
+	rest, err := asn1.Unmarshal(der, &info)
+	if err != nil {
+		return nil, fmt.Errorf("parse encrypted private key: %w", err)
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after encrypted private key")
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption scheme %s (only PBES2 is supported)", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("parse PBES2 parameters: %w", err)
+	}
+
+	return &EncryptedKey{
+		kdf:    params.KeyDerivationFunc,
+		cipher: params.EncryptionScheme,
+		data:   info.EncryptedData,
+	}, nil
+}
+
+// Decrypt returns the DER PKCS#8 PrivateKeyInfo.
+func (k *EncryptedKey) Decrypt(passphrase []byte) ([]byte, error) {
+	keyLen, err := cipherKeyLength(k.cipher.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(k.kdf, passphrase, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	if isGCM(k.cipher.Algorithm) {
+		var params gcmParams
+		if _, err := asn1.Unmarshal(k.cipher.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("parse AES-GCM parameters: %w", err)
+		}
+
+		aead, err := cipher.NewGCMWithNonceSize(block, len(params.Nonce))
+		if err != nil {
+			return nil, err
+		}
+
+		if params.ICVLen != aead.Overhead() {
+			return nil, fmt.Errorf("unsupported AES-GCM tag length: %d", params.ICVLen)
+		}
+
+		plaintext, err = aead.Open(nil, params.Nonce, k.data, nil)
+		if err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+	} else {
+		var iv []byte
+		if _, err := asn1.Unmarshal(k.cipher.Parameters.FullBytes, &iv); err != nil {
+			return nil, fmt.Errorf("parse AES-CBC parameters: %w", err)
+		}
+
+		if len(iv) != aes.BlockSize || len(k.data) == 0 || len(k.data)%aes.BlockSize != 0 {
+			return nil, errors.New("malformed AES-CBC encrypted data")
+		}
+
+		plaintext = make([]byte, len(k.data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, k.data)
+
+		plaintext, err = unpad(plaintext)
+		if err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+
+	// A wrong passphrase with CBC passes the padding check about once in 256
+	// tries, so the result must also look like a DER SEQUENCE.
+	var raw asn1.RawValue
+	if rest, err := asn1.Unmarshal(plaintext, &raw); err != nil || len(rest) > 0 {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	return plaintext, nil
+}
+
+// String describes the protection, e.g. "PBES2 (PBKDF2-HMAC-SHA256, 600000 iterations, AES-256-CBC)".
+func (k *EncryptedKey) String() string {
+	kdf := k.kdf.Algorithm.String()
+	switch {
+	case k.kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(k.kdf.Parameters.FullBytes, &params); err == nil {
+			_, prf, _ := prfHash(params.PRF)
+			kdf = fmt.Sprintf("PBKDF2-HMAC-%s, %d iterations", prf, params.IterationCount)
+		}
+	case k.kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(k.kdf.Parameters.FullBytes, &params); err == nil {
+			kdf = fmt.Sprintf("scrypt N=%d r=%d p=%d", params.CostParameter, params.BlockSize, params.ParallelizationParameter)
+		}
+	}
+
+	return fmt.Sprintf("PBES2 (%s, %s)", kdf, cipherName(k.cipher.Algorithm))
+}
+
+// EncryptPKCS8 protects a DER PKCS#8 PrivateKeyInfo and returns a DER EncryptedPrivateKeyInfo.
+func EncryptPKCS8(der, passphrase []byte, opts Options) ([]byte, error) {
+	cipherOID, keyLen, err := cipherByName(opts.Cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	var kdf pkix.AlgorithmIdentifier
+	switch strings.ToLower(opts.KDF) {
+	case "", KDFPBKDF2:
+		iterations := opts.Iterations
+		if iterations == 0 {
+			iterations = DefaultPBKDF2Iterations
+		}
+
+		params, err := asn1.Marshal(pbkdf2Params{
+			Salt:           salt,
+			IterationCount: iterations,
+			PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+		})
+		if err != nil {
+			return nil, err
+		}
+		kdf = pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: params}}
+	case KDFScrypt:
+		cost := opts.Iterations
+		if cost == 0 {
+			cost = DefaultScryptCost
+		}
+
+		if cost < 2 || cost&(cost-1) != 0 {
+			return nil, fmt.Errorf("scrypt cost must be a power of two greater than 1, got %d", cost)
+		}
+
+		params, err := asn1.Marshal(scryptParams{
+			Salt:                     salt,
+			CostParameter:            cost,
+			BlockSize:                scryptBlockSize,
+			ParallelizationParameter: scryptParallelization,
+		})
+		if err != nil {
+			return nil, err
+		}
+		kdf = pkix.AlgorithmIdentifier{Algorithm: oidScrypt, Parameters: asn1.RawValue{FullBytes: params}}
+	default:
+		return nil, fmt.Errorf("unsupported KDF: %s (use pbkdf2 or scrypt)", opts.KDF)
+	}
+
+	key, err := deriveKey(kdf, passphrase, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var cipherParams, encrypted []byte
+	if isGCM(cipherOID) {
+		nonce := make([]byte, gcmNonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted = aead.Seal(nil, nonce, der, nil)
+		cipherParams, err = asn1.Marshal(gcmParams{Nonce: nonce, ICVLen: gcmTagSize})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		iv := make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			return nil, err
+		}
+
+		encrypted = pad(der)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+		cipherParams, err = asn1.Marshal(iv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: kdf,
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: cipherOID, Parameters: asn1.RawValue{FullBytes: cipherParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+}
+
+func deriveKey(kdf pkix.AlgorithmIdentifier, passphrase []byte, keyLen int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("parse PBKDF2 parameters: %w", err)
+		}
+
+		if params.KeyLength != 0 && params.KeyLength != keyLen {
+			return nil, fmt.Errorf("PBKDF2 key length %d does not match the cipher", params.KeyLength)
+		}
+
+		if params.IterationCount < 1 || params.IterationCount > MaxPBKDF2Iterations {
+			return nil, fmt.Errorf("PBKDF2 iteration count %d is outside 1 to %d", params.IterationCount, MaxPBKDF2Iterations)
+		}
+
+		h, _, err := prfHash(params.PRF)
+		if err != nil {
+			return nil, err
+		}
+
+		return pbkdf2.Key(h, string(passphrase), params.Salt, params.IterationCount, keyLen)
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("parse scrypt parameters: %w", err)
+		}
+
+		if params.KeyLength != 0 && params.KeyLength != keyLen {
+			return nil, fmt.Errorf("scrypt key length %d does not match the cipher", params.KeyLength)
+		}
+
+		if err := checkScryptParams(params); err != nil {
+			return nil, err
+		}
+
+		return scrypt.Key(passphrase, params.Salt, params.CostParameter, params.BlockSize, params.ParallelizationParameter, keyLen)
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function: %s", kdf.Algorithm)
+	}
+}
+
+func checkScryptParams(params scryptParams) error {
+	n, r, p := params.CostParameter, params.BlockSize, params.ParallelizationParameter
+	if n < 2 || n&(n-1) != 0 {
+		return fmt.Errorf("scrypt cost N=%d is not a power of two greater than 1", n)
+	}
+
+	if r < 1 || p < 1 {
+		return fmt.Errorf("scrypt parameters r=%d p=%d must be positive", r, p)
+	}
+
+	// Each factor is checked on its own first, so that the products can't overflow.
+	if n > MaxScryptMemory/128 || r > MaxScryptMemory/128 || 128*int64(n)*int64(r) > MaxScryptMemory {
+		return fmt.Errorf("scrypt N=%d r=%d needs more than %d MiB of memory", n, r, MaxScryptMemory>>20)
+	}
+
+	if p > MaxScryptWork || int64(n)*int64(r)*int64(p) > MaxScryptWork {
+		return fmt.Errorf("scrypt N=%d r=%d p=%d exceeds the work limit N*r*p <= %d", n, r, p, MaxScryptWork)
+	}
+
+	return nil
+}
+
+// prfHash maps the PBKDF2 PRF, which defaults to HMAC-SHA1 when absent.
+func prfHash(prf pkix.AlgorithmIdentifier) (func() hash.Hash, string, error) {
+	switch {
+	case len(prf.Algorithm) == 0, prf.Algorithm.Equal(oidHMACWithSHA1):
+		return sha1.New, "SHA1", nil
+	case prf.Algorithm.Equal(oidHMACWithSHA256):
+		return sha256.New, "SHA256", nil
+	case prf.Algorithm.Equal(oidHMACWithSHA384):
+		return sha512.New384, "SHA384", nil
+	case prf.Algorithm.Equal(oidHMACWithSHA512):
+		return sha512.New, "SHA512", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported PBKDF2 PRF: %s", prf.Algorithm)
+	}
+}
+
+func cipherByName(name string) (asn1.ObjectIdentifier, int, error) {
+	switch strings.ToLower(name) {
+	case CipherAES128CBC:
+		return oidAES128CBC, 16, nil
+	case "", CipherAES256CBC:
+		return oidAES256CBC, 32, nil
+	case CipherAES128GCM:
+		return oidAES128GCM, 16, nil
+	case CipherAES256GCM:
+		return oidAES256GCM, 32, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported cipher: %s (use aes-128-cbc, aes-256-cbc, aes-128-gcm or aes-256-gcm)", name)
+	}
+}
+
+func cipherKeyLength(oid asn1.ObjectIdentifier) (int, error) {
+	switch {
+	case oid.Equal(oidAES128CBC), oid.Equal(oidAES128GCM):
+		return 16, nil
+	case oid.Equal(oidAES192CBC), oid.Equal(oidAES192GCM):
+		return 24, nil
+	case oid.Equal(oidAES256CBC), oid.Equal(oidAES256GCM):
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("unsupported encryption scheme: %s", oid)
+	}
+}
+
+func cipherName(oid asn1.ObjectIdentifier) string {
+	keyLen, err := cipherKeyLength(oid)
+	if err != nil {
+		return oid.String()
+	}
+
+	if isGCM(oid) {
+		return fmt.Sprintf("AES-%d-GCM", keyLen*8)
+	}
+	return fmt.Sprintf("AES-%d-CBC", keyLen*8)
+}
+
+func isGCM(oid asn1.ObjectIdentifier) bool {
+	return oid.Equal(oidAES128GCM) || oid.Equal(oidAES192GCM) || oid.Equal(oidAES256GCM)
+}
+
+// pad applies PKCS#7 padding.
+func pad(data []byte) []byte {
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	return append(bytes.Clone(data), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(data []byte) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > aes.BlockSize || n > len(data) {
+		return nil, errors.New("invalid padding")
+	}
+
+	if !bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("invalid padding")
+	}
+
+	return data[:len(data)-n], nil
+}
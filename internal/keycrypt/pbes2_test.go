@@ -0,0 +1,211 @@
+package keycrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pbkdf2Identifier(t *testing.T, salt string, iterations int, prf asn1.ObjectIdentifier) pkix.AlgorithmIdentifier {
+	t.Helper()
+	params := pbkdf2Params{Salt: []byte(salt), IterationCount: iterations}
+	if prf != nil {
+		params.PRF = pkix.AlgorithmIdentifier{Algorithm: prf, Parameters: asn1.NullRawValue}
+	}
+
+	der, err := asn1.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: der}}
+}
+
+func scryptIdentifier(t *testing.T, salt string, n, r, p int) pkix.AlgorithmIdentifier {
+	t.Helper()
+	der, err := asn1.Marshal(scryptParams{Salt: []byte(salt), CostParameter: n, BlockSize: r, ParallelizationParameter: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidScrypt, Parameters: asn1.RawValue{FullBytes: der}}
+}
+
+// TestPBKDF2Vectors uses the PBKDF2-HMAC-SHA1 test vectors of RFC 6070, with
+// the PRF left out as in files that rely on the default.
+func TestPBKDF2Vectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"pass\x00word", "sa\x00lt", 4096, "56fa6aa75548099dcc37d7f03425e0c3"},
+	}
+
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got, err := deriveKey(pbkdf2Identifier(t, tt.salt, tt.iterations, nil), []byte(tt.password), len(want))
+		if err != nil {
+			t.Fatalf("%q/%q/%d: %v", tt.password, tt.salt, tt.iterations, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%q/%q/%d: got %x, want %x", tt.password, tt.salt, tt.iterations, got, want)
+		}
+	}
+}
+
+// TestScryptVectors uses the scrypt test vectors of RFC 7914, section 12,
+// except the last one, which needs 1 GiB of memory.
+func TestScryptVectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		n, r, p        int
+		want           string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+		{"pleaseletmein", "SodiumChloride", 16384, 8, 1, "7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887"},
+	}
+
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got, err := deriveKey(scryptIdentifier(t, tt.salt, tt.n, tt.r, tt.p), []byte(tt.password), len(want))
+		if err != nil {
+			t.Fatalf("N=%d r=%d p=%d: %v", tt.n, tt.r, tt.p, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("N=%d r=%d p=%d: got %x, want %x", tt.n, tt.r, tt.p, got, want)
+		}
+	}
+}
+
+func TestDeriveKeyRejectsExcessiveParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		kdf  pkix.AlgorithmIdentifier
+		want string
+	}{
+		{"PBKDF2 without iterations", pbkdf2Identifier(t, "salt", 0, oidHMACWithSHA256), "iteration count 0"},
+		{"PBKDF2 with negative iterations", pbkdf2Identifier(t, "salt", -1, oidHMACWithSHA256), "iteration count -1"},
+		{"PBKDF2 iterations above the limit", pbkdf2Identifier(t, "salt", MaxPBKDF2Iterations+1, nil), "iteration count 10000001"},
+		{"scrypt N not a power of two", scryptIdentifier(t, "salt", 1000, 8, 1), "not a power of two"},
+		{"scrypt N of 1", scryptIdentifier(t, "salt", 1, 8, 1), "not a power of two"},
+		{"scrypt r of 0", scryptIdentifier(t, "salt", 1024, 0, 1), "must be positive"},
+		{"scrypt p of 0", scryptIdentifier(t, "salt", 1024, 8, 0), "must be positive"},
+		{"scrypt memory", scryptIdentifier(t, "salt", 1<<21, 8, 1), "MiB of memory"},
+		{"scrypt huge N", scryptIdentifier(t, "salt", 1<<62, 8, 1), "MiB of memory"},
+		{"scrypt huge r", scryptIdentifier(t, "salt", 2, 1<<40, 1), "MiB of memory"},
+		{"scrypt work", scryptIdentifier(t, "salt", 1<<15, 8, 1<<10), "work limit"},
+		{"scrypt huge p", scryptIdentifier(t, "salt", 2, 1, 1<<40), "work limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := deriveKey(tt.kdf, []byte("passphrase"), 32)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func testPKCS8(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestEncryptPKCS8RoundTrip(t *testing.T) {
+	der := testPKCS8(t)
+	passphrase := []byte("correct horse battery staple")
+
+	for _, kdf := range []string{KDFPBKDF2, KDFScrypt} {
+		for _, c := range []string{CipherAES128CBC, CipherAES256CBC, CipherAES128GCM, CipherAES256GCM} {
+			opts := Options{KDF: kdf, Cipher: c, Iterations: 1024}
+			encrypted, err := EncryptPKCS8(der, passphrase, opts)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", kdf, c, err)
+			}
+
+			k, err := ParseEncryptedPKCS8(encrypted)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", kdf, c, err)
+			}
+
+			decrypted, err := k.Decrypt(passphrase)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", kdf, c, err)
+			}
+			if !bytes.Equal(decrypted, der) {
+				t.Fatalf("%s/%s: decrypted key differs", kdf, c)
+			}
+
+			if _, err := k.Decrypt([]byte("wrong")); !errors.Is(err, ErrIncorrectPassphrase) {
+				t.Fatalf("%s/%s with a wrong passphrase: got %v, want %v", kdf, c, err, ErrIncorrectPassphrase)
+			}
+		}
+	}
+}
+
+// TestDecryptOpenSSL decrypts keys written by openssl pkcs8 -topk8.
+func TestDecryptOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	der := testPKCS8(t)
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [][]string{
+		{"-v2", "aes-256-cbc", "-v2prf", "hmacWithSHA256", "-iter", "2048"},
+		{"-v2", "aes-128-cbc", "-v2prf", "hmacWithSHA1", "-iter", "1000"},
+		{"-v2", "aes-256-cbc", "-scrypt", "-scrypt_N", "1024", "-scrypt_r", "8", "-scrypt_p", "1"},
+	}
+
+	for _, args := range tests {
+		args = append([]string{"pkcs8", "-topk8", "-in", keyPath, "-outform", "DER", "-passout", "pass:secret"}, args...)
+		encrypted, err := exec.Command(openssl, args...).Output()
+		if err != nil {
+			t.Fatalf("openssl %s: %v", strings.Join(args, " "), err)
+		}
+
+		k, err := ParseEncryptedPKCS8(encrypted)
+		if err != nil {
+			t.Fatalf("openssl %s: %v", strings.Join(args, " "), err)
+		}
+
+		decrypted, err := k.Decrypt([]byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		if !bytes.Equal(decrypted, der) {
+			t.Fatalf("%s: decrypted key differs", k)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package ocsp implements the Online Certificate Status Protocol of RFC 6960:
+// requests and responses with nonces (RFC 8954), and a responder that answers
+// them over HTTP from a revocation list.
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Status is the status of a certificate in a response.
+type Status int
+
+const (
+	Good Status = iota
+	Revoked
+	Unknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case Good:
+		return "good"
+	case Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// ResponseStatus is the OCSPResponseStatus of RFC 6960, section 4.2.1.
+type ResponseStatus int
+
+const (
+	Successful       ResponseStatus = 0
+	MalformedRequest ResponseStatus = 1
+	InternalError    ResponseStatus = 2
+	TryLater         ResponseStatus = 3
+	SigRequired      ResponseStatus = 5
+	Unauthorized     ResponseStatus = 6
+)
+
+func (s ResponseStatus) String() string {
+	switch s {
+	case Successful:
+		return "successful"
+	case MalformedRequest:
+		return "malformedRequest"
+	case InternalError:
+		return "internalError"
+	case TryLater:
+		return "tryLater"
+	case SigRequired:
+		return "sigRequired"
+	case Unauthorized:
+		return "unauthorized"
+	default:
+		return fmt.Sprintf("status %d", int(s))
+	}
+}
+
+// maxNonceSize is the largest nonce RFC 8954 allows.
+const maxNonceSize = 32
+
+var (
+	oidNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+	oidBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+)
+
+// hashes are the hash algorithms a CertID may use.
+var hashes = []struct {
+	hash crypto.Hash
+	oid  asn1.ObjectIdentifier
+}{
+	{crypto.SHA1, asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+	{crypto.SHA256, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+	{crypto.SHA384, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}},
+	{crypto.SHA512, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}},
+}
+
+// certID is the CertID of RFC 6960, section 4.1.1.
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// ocspRequest is the OCSPRequest of RFC 6960, section 4.1.1. Request
+// signatures are accepted but not checked.
+type ocspRequest struct {
+	TBSRequest        tbsRequest
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type tbsRequest struct {
+	Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList   []singleRequest
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type singleRequest struct {
+	CertID     certID
+	Extensions []pkix.Extension `asn1:"explicit,tag:0,optional"`
+}
+
+// CertID identifies a certificate by its issuer and serial number. Hash is
+// zero when the hash algorithm isn't one of those supported.
+type CertID struct {
+	Hash           crypto.Hash
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+
+	// raw is the CertID as parsed, answered unchanged (RFC 6960, section
+	// 4.2.2.3), whatever its hash algorithm.
+	raw *certID
+}
+
+// NewCertID identifies the certificate with the given serial number issued by issuer.
+func NewCertID(issuer *x509.Certificate, serial *big.Int, hash crypto.Hash) (CertID, error) {
+	nameHash, keyHash, err := issuerHashes(issuer, hash)
+	if err != nil {
+		return CertID{}, err
+	}
+
+	return CertID{Hash: hash, IssuerNameHash: nameHash, IssuerKeyHash: keyHash, SerialNumber: serial}, nil
+}
+
+// IssuedBy reports whether id names a certificate of issuer.
+func (id CertID) IssuedBy(issuer *x509.Certificate) bool {
+	nameHash, keyHash, err := issuerHashes(issuer, id.Hash)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(nameHash, id.IssuerNameHash) && bytes.Equal(keyHash, id.IssuerKeyHash)
+}
+
+func (id CertID) marshal() (certID, error) {
+	if id.raw != nil {
+		return *id.raw, nil
+	}
+
+	oid := hashOID(id.Hash)
+	if oid == nil {
+		return certID{}, fmt.Errorf("unsupported CertID hash: %s", id.Hash)
+	}
+
+	return certID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+		IssuerNameHash: id.IssuerNameHash,
+		IssuerKeyHash:  id.IssuerKeyHash,
+		SerialNumber:   id.SerialNumber,
+	}, nil
+}
+
+func (id certID) unmarshal() CertID {
+	var hash crypto.Hash
+	for _, h := range hashes {
+		if h.oid.Equal(id.HashAlgorithm.Algorithm) {
+			hash = h.hash
+		}
+	}
+
+	return CertID{Hash: hash, IssuerNameHash: id.IssuerNameHash, IssuerKeyHash: id.IssuerKeyHash, SerialNumber: id.SerialNumber, raw: &id}
+}
+
+func hashOID(hash crypto.Hash) asn1.ObjectIdentifier {
+	for _, h := range hashes {
+		if h.hash == hash {
+			return h.oid
+		}
+	}
+	return nil
+}
+
+// issuerHashes returns the hashes of the issuer's subject and of its public
+// key, the BIT STRING of its SubjectPublicKeyInfo.
+func issuerHashes(issuer *x509.Certificate, hash crypto.Hash) (nameHash, keyHash []byte, err error) {
+	if hashOID(hash) == nil || !hash.Available() {
+		return nil, nil, fmt.Errorf("unsupported CertID hash: %s", hash)
+	}
+
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash = h.Sum(nil)
+
+	key, err := subjectPublicKey(issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.Reset()
+	h.Write(key)
+	return nameHash, h.Sum(nil), nil
+}
+
+func subjectPublicKey(cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, fmt.Errorf("parse public key of %s: %w", cert.Subject, err)
+	}
+	return spki.PublicKey.RightAlign(), nil
+}
+
+// Request is an OCSP request for the status of one or more certificates.
+type Request struct {
+	CertIDs []CertID
+
+	// Nonce is the content of the nonce extension, or nil.
+	Nonce []byte
+}
+
+// CreateRequest returns a DER OCSP request, with a nonce extension unless
+// nonce is empty.
+func CreateRequest(ids []CertID, nonce []byte) ([]byte, error) {
+	var tbs tbsRequest
+	for _, id := range ids {
+		marshaled, err := id.marshal()
+		if err != nil {
+			return nil, err
+		}
+		tbs.RequestList = append(tbs.RequestList, singleRequest{CertID: marshaled})
+	}
+
+	if len(nonce) > 0 {
+		ext, err := nonceExtension(nonce)
+		if err != nil {
+			return nil, err
+		}
+		tbs.Extensions = []pkix.Extension{ext}
+	}
+
+	return asn1.Marshal(ocspRequest{TBSRequest: tbs})
+}
+
+// ParseRequest parses a DER OCSP request.
+func ParseRequest(der []byte) (*Request, error) {
+	var req ocspRequest
+	if rest, err := asn1.Unmarshal(der, &req); err != nil {
+		return nil, fmt.Errorf("parse OCSP request: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("parse OCSP request: trailing data")
+	}
+
+	if len(req.TBSRequest.RequestList) == 0 {
+		return nil, errors.New("parse OCSP request: no certificates requested")
+	}
+
+	parsed := &Request{Nonce: findNonce(req.TBSRequest.Extensions)}
+	for _, r := range req.TBSRequest.RequestList {
+		parsed.CertIDs = append(parsed.CertIDs, r.CertID.unmarshal())
+	}
+
+	return parsed, nil
+}
+
+// findNonce returns the content of the nonce extension, an OCTET STRING of at
+// most 32 bytes (RFC 8954), or nil when there is no valid nonce.
+func findNonce(extensions []pkix.Extension) []byte {
+	for _, ext := range extensions {
+		if !ext.Id.Equal(oidNonce) {
+			continue
+		}
+
+		var nonce []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &nonce); err != nil || len(rest) > 0 || len(nonce) == 0 || len(nonce) > maxNonceSize {
+			return nil
+		}
+		return nonce
+	}
+	return nil
+}
+
+func nonceExtension(nonce []byte) (pkix.Extension, error) {
+	value, err := asn1.Marshal(nonce)
+	return pkix.Extension{Id: oidNonce, Value: value}, err
+}
@@ -0,0 +1,508 @@
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and its key, issued by parent or
+// self-signed when parent is nil.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) testCertificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(24 * time.Hour)
+	}
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCertificate{cert, key}
+}
+
+func newTestIssuer(t *testing.T) testCertificate {
+	t.Helper()
+	return newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+}
+
+func newTestResponder(t *testing.T, issuer testCertificate, usage ...x509.ExtKeyUsage) testCertificate {
+	t.Helper()
+	return newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test OCSP Responder"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usage,
+	}, &issuer)
+}
+
+// statusSource answers from a map of serial numbers, and Good otherwise.
+type statusSource map[int64]Entry
+
+func (s statusSource) Lookup(serial *big.Int) (Entry, error) {
+	if entry, ok := s[serial.Int64()]; ok {
+		return entry, nil
+	}
+	return Entry{Status: Good}, nil
+}
+
+func newTestResponderFor(issuer testCertificate, signer testCertificate, source Source) *Responder {
+	return &Responder{
+		Issuer:      issuer.cert,
+		Source:      source,
+		Certificate: signer.cert,
+		Key:         signer.key,
+		Hash:        crypto.SHA256,
+		Times: func(now time.Time) (time.Time, time.Time) {
+			return now, now.Add(time.Hour)
+		},
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		name  string
+		hash  crypto.Hash
+		nonce []byte
+	}{
+		{"sha1", crypto.SHA1, nil},
+		{"sha256 with nonce", crypto.SHA256, bytes.Repeat([]byte{7}, 16)},
+		{"sha384", crypto.SHA384, nil},
+		{"sha512 with largest nonce", crypto.SHA512, bytes.Repeat([]byte{9}, maxNonceSize)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := NewCertID(issuer.cert, big.NewInt(0x5A0F3C), tt.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			der, err := CreateRequest([]CertID{id}, tt.nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := ParseRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(req.CertIDs) != 1 {
+				t.Fatalf("got %d CertIDs, want 1", len(req.CertIDs))
+			}
+			got := req.CertIDs[0]
+			if got.Hash != tt.hash || got.SerialNumber.Cmp(id.SerialNumber) != 0 ||
+				!bytes.Equal(got.IssuerNameHash, id.IssuerNameHash) || !bytes.Equal(got.IssuerKeyHash, id.IssuerKeyHash) {
+				t.Fatalf("got %+v, want %+v", got, id)
+			}
+			if !got.IssuedBy(issuer.cert) {
+				t.Fatal("the parsed CertID is not issued by the issuer")
+			}
+			if !bytes.Equal(req.Nonce, tt.nonce) {
+				t.Fatalf("got nonce %x, want %x", req.Nonce, tt.nonce)
+			}
+		})
+	}
+}
+
+func TestParseRequestErrors(t *testing.T) {
+	issuer := newTestIssuer(t)
+	id, err := NewCertID(issuer.cert, big.NewInt(1), crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateRequest([]CertID{id}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext, err := nonceExtension([]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// encoding/asn1 can't parse a request without certificates unless a
+	// field follows the empty list.
+	empty, err := asn1.Marshal(struct{ TBSRequest tbsRequest }{tbsRequest{Extensions: []pkix.Extension{ext}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		der  []byte
+		want string
+	}{
+		{"garbage", []byte("not DER"), "parse OCSP request: asn1:"},
+		{"trailing data", append(der, 0), "parse OCSP request: trailing data"},
+		{"no certificates", empty, "parse OCSP request: no certificates requested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRequest(tt.der); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	issuer := newTestIssuer(t)
+	revokedAt := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	rs := newTestResponderFor(issuer, issuer, statusSource{
+		2: {Status: Revoked, RevokedAt: revokedAt, Reason: 1},
+		3: {Status: Revoked, RevokedAt: revokedAt},
+	})
+
+	other := newTestIssuer(t)
+	var ids []CertID
+	for _, c := range []struct {
+		issuer *x509.Certificate
+		serial int64
+	}{{issuer.cert, 1}, {issuer.cert, 2}, {issuer.cert, 3}, {other.cert, 1}} {
+		id, err := NewCertID(c.issuer, big.NewInt(c.serial), crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		name      string
+		nonce     []byte
+		wantNonce []byte
+	}{
+		{"without nonce", nil, nil},
+		{"nonce echoed", []byte("0123456789abcdef"), []byte("0123456789abcdef")},
+		{"oversized nonce dropped", bytes.Repeat([]byte{1}, maxNonceSize+1), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := CreateRequest(ids, tt.nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			resp, err := ParseResponse(rs.Respond(req, now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != Successful {
+				t.Fatalf("got status %s", resp.Status)
+			}
+			if !bytes.Equal(resp.Nonce, tt.wantNonce) {
+				t.Fatalf("got nonce %x, want %x", resp.Nonce, tt.wantNonce)
+			}
+			if _, err := resp.Verify(issuer.cert, now); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []SingleResponse{
+				{Status: Good},
+				{Status: Revoked, RevokedAt: revokedAt, Reason: 1},
+				{Status: Revoked, RevokedAt: revokedAt},
+				{Status: Unknown},
+			}
+			if len(resp.Responses) != len(want) {
+				t.Fatalf("got %d responses, want %d", len(resp.Responses), len(want))
+			}
+			for i, got := range resp.Responses {
+				if got.CertID.SerialNumber.Cmp(ids[i].SerialNumber) != 0 || !bytes.Equal(got.CertID.IssuerKeyHash, ids[i].IssuerKeyHash) {
+					t.Errorf("response %d: got CertID %+v, want %+v", i, got.CertID, ids[i])
+				}
+				if got.Status != want[i].Status || !got.RevokedAt.Equal(want[i].RevokedAt) || got.Reason != want[i].Reason {
+					t.Errorf("response %d: got %s at %s reason %d, want %s at %s reason %d", i,
+						got.Status, got.RevokedAt, got.Reason, want[i].Status, want[i].RevokedAt, want[i].Reason)
+				}
+				if !got.NextUpdate.Equal(now.UTC().Truncate(time.Second).Add(time.Hour)) {
+					t.Errorf("response %d: got nextUpdate %s", i, got.NextUpdate)
+				}
+			}
+		})
+	}
+
+	if resp, err := ParseResponse(rs.Respond([]byte("not DER"), time.Now())); err != nil || resp.Status != MalformedRequest {
+		t.Fatalf("malformed request: got %v, %v", resp, err)
+	}
+}
+
+// TestRespondUnsupportedHash checks that a CertID with a hash outside hashes,
+// here SHA-224 as sent by openssl ocsp -sha224, is answered unknown with the
+// CertID echoed unchanged.
+func TestRespondUnsupportedHash(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rs := newTestResponderFor(issuer, issuer, statusSource{})
+
+	id := certID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 4}, Parameters: asn1.NullRawValue},
+		IssuerNameHash: bytes.Repeat([]byte{1}, 28),
+		IssuerKeyHash:  bytes.Repeat([]byte{2}, 28),
+		SerialNumber:   big.NewInt(1),
+	}
+	req, err := asn1.Marshal(ocspRequest{TBSRequest: tbsRequest{RequestList: []singleRequest{{CertID: id}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ParseResponse(rs.Respond(req, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != Successful || len(resp.Responses) != 1 || resp.Responses[0].Status != Unknown {
+		t.Fatalf("got %+v", resp)
+	}
+
+	want, err := asn1.Marshal(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := resp.Responses[0].CertID.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotDER, err := asn1.Marshal(got); err != nil || !bytes.Equal(gotDER, want) {
+		t.Fatalf("got CertID %x, want %x (%v)", gotDER, want, err)
+	}
+}
+
+func TestMarshalRevokedInfo(t *testing.T) {
+	at := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		reason     int
+		wantReason []byte
+	}{
+		{"unspecified reason left out", 0, nil},
+		{"keyCompromise", 1, []byte{0xa0, 0x03, 0x0a, 0x01, 0x01}},
+		{"privilegeWithdrawn", 9, []byte{0xa0, 0x03, 0x0a, 0x01, 0x09}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := marshalStatus(SingleResponse{Status: Revoked, RevokedAt: at, Reason: tt.reason})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Class != asn1.ClassContextSpecific || status.Tag != tagRevoked {
+				t.Fatalf("got class %d tag %d", status.Class, status.Tag)
+			}
+
+			var revokedAt time.Time
+			rest, err := asn1.UnmarshalWithParams(status.Bytes, &revokedAt, "generalized")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !revokedAt.Equal(at) {
+				t.Fatalf("got revocation time %s, want %s", revokedAt, at)
+			}
+			if !bytes.Equal(rest, tt.wantReason) {
+				t.Fatalf("got reason %x, want %x", rest, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	other := newTestIssuer(t)
+	delegated := newTestResponder(t, issuer, x509.ExtKeyUsageOCSPSigning)
+	noEKU := newTestResponder(t, issuer, x509.ExtKeyUsageServerAuth)
+	foreign := newTestResponder(t, other, x509.ExtKeyUsageOCSPSigning)
+
+	tests := []struct {
+		name    string
+		signer  testCertificate
+		wantErr string
+	}{
+		{"issuer", issuer, ""},
+		{"delegated responder", delegated, ""},
+		{"responder without OCSPSigning", noEKU, "lacks the OCSPSigning extended key usage"},
+		{"responder of another issuer", foreign, "is not issued by"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := NewCertID(issuer.cert, big.NewInt(1), crypto.SHA1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := CreateRequest([]CertID{id}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			resp, err := ParseResponse(newTestResponderFor(issuer, tt.signer, statusSource{}).Respond(req, now))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signer, err := resp.Verify(issuer.cert, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !signer.Equal(tt.signer.cert) {
+				t.Fatalf("signed by %s, want %s", signer.Subject, tt.signer.cert.Subject)
+			}
+		})
+	}
+
+	// A response signed by the issuer key but claiming another responder.
+	id, err := NewCertID(issuer.cert, big.NewInt(1), crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := CreateRequest([]CertID{id}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := newTestResponderFor(issuer, issuer, statusSource{})
+	rs.Key = other.key
+	resp, err := ParseResponse(rs.Respond(req, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resp.Verify(issuer.cert, time.Now()); err == nil || !strings.Contains(err.Error(), "invalid response signature") {
+		t.Fatalf("wrong key: got %v", err)
+	}
+
+	if _, err := (&Response{Status: TryLater}).Verify(issuer.cert, time.Now()); err == nil {
+		t.Fatal("an unsuccessful response verified")
+	}
+}
+
+func TestReadRevocationFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]Entry
+		wantErr string
+	}{
+		{
+			name:    "entries",
+			content: "# serial revoked at reason\n\n5a:0f:3c  2025-01-31T12:00:00Z  keyCompromise\n0x10 2025-02-01T00:00:00+07:00\n",
+			want: map[string]Entry{
+				"5A0F3C": {Status: Revoked, RevokedAt: time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC), Reason: 1},
+				"10":     {Status: Revoked, RevokedAt: time.Date(2025, 1, 31, 17, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:    "missing time",
+			content: "# header\n5A0F3C\n",
+			wantErr: ":2: expected serial, revocation time and optional reason",
+		},
+		{
+			name:    "too many fields",
+			content: "5A0F3C 2025-01-31T12:00:00Z keyCompromise extra\n",
+			wantErr: ":1: expected serial, revocation time and optional reason",
+		},
+		{
+			name:    "bad serial",
+			content: "XYZ 2025-01-31T12:00:00Z\n",
+			wantErr: `:1: invalid serial number "XYZ": expected positive hex`,
+		},
+		{
+			name:    "bad time",
+			content: "5A0F3C 2025-01-31\n",
+			wantErr: `:1: invalid revocation time "2025-01-31": use RFC 3339`,
+		},
+		{
+			name:    "bad reason",
+			content: "5A0F3C 2025-01-31T12:00:00Z compromised\n",
+			wantErr: ":1: unknown revocation reason: compromised",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "revoked.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ReadRevocationFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), path+tt.wantErr) {
+					t.Fatalf("got %v, want %q", err, path+tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %v, want %v", entries, tt.want)
+			}
+			for serial, want := range tt.want {
+				got, ok := entries[serial]
+				if !ok || got.Status != want.Status || !got.RevokedAt.Equal(want.RevokedAt) || got.Reason != want.Reason {
+					t.Errorf("%s: got %+v, want %+v", serial, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := ReadRevocationFile(filepath.Join(t.TempDir(), "missing.txt")); !os.IsNotExist(err) {
+		t.Fatalf("missing file: got %v", err)
+	}
+
+	f := RevocationFile{Path: filepath.Join(t.TempDir(), "revoked.txt")}
+	if err := os.WriteFile(f.Path, []byte("5A0F3C 2025-01-31T12:00:00Z\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for serial, want := range map[int64]Status{0x5A0F3C: Revoked, 0x5A0F3D: Good} {
+		if entry, err := f.Lookup(big.NewInt(serial)); err != nil || entry.Status != want {
+			t.Errorf("%X: got %+v, %v, want %s", serial, entry, err, want)
+		}
+	}
+}
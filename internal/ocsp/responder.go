@@ -0,0 +1,113 @@
+package ocsp
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tuanta7/keys/internal/ca"
+)
+
+// maxRequestSize bounds the body of a POST request.
+const maxRequestSize = 64 << 10
+
+// Responder answers OCSP requests over HTTP (RFC 6960, appendix A), as POST
+// bodies or base64 GET paths, for the certificates of Issuer.
+type Responder struct {
+	Issuer *x509.Certificate
+	Source Source
+
+	// Certificate and Key sign the responses: the issuer itself, or a
+	// delegated responder certificate issued by it, which is sent along.
+	Certificate *x509.Certificate
+	Key         *rsa.PrivateKey
+	Hash        crypto.Hash
+
+	// Times returns the thisUpdate and nextUpdate of a response produced at
+	// now. A zero nextUpdate is left out.
+	Times func(now time.Time) (thisUpdate, nextUpdate time.Time)
+
+	// Logf, when set, is called for every answered certificate and failed request.
+	Logf func(format string, args ...any)
+}
+
+// ServeHTTP implements http.Handler.
+func (rs *Responder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var der []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		if der, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/")); err != nil {
+			rs.write(w, errorResponse(MalformedRequest))
+			rs.logf("%s %s: invalid base64 request", r.Method, r.RemoteAddr)
+			return
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+		if err != nil || len(body) > maxRequestSize {
+			rs.write(w, errorResponse(MalformedRequest))
+			rs.logf("%s %s: unreadable or oversized request", r.Method, r.RemoteAddr)
+			return
+		}
+		der = body
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "OCSP requests are sent with GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rs.write(w, rs.Respond(der, time.Now()))
+}
+
+// Respond returns the DER response to a DER request.
+func (rs *Responder) Respond(der []byte, now time.Time) []byte {
+	req, err := ParseRequest(der)
+	if err != nil {
+		rs.logf("%s", err)
+		return errorResponse(MalformedRequest)
+	}
+
+	thisUpdate, nextUpdate := rs.Times(now)
+
+	responses := make([]SingleResponse, len(req.CertIDs))
+	for i, id := range req.CertIDs {
+		responses[i] = SingleResponse{CertID: id, Status: Unknown, ThisUpdate: thisUpdate, NextUpdate: nextUpdate}
+		if id.IssuedBy(rs.Issuer) {
+			entry, err := rs.Source.Lookup(id.SerialNumber)
+			if err != nil {
+				rs.logf("lookup %s: %s", ca.FormatSerial(id.SerialNumber), err)
+				return errorResponse(InternalError)
+			}
+
+			responses[i].Status = entry.Status
+			responses[i].RevokedAt = entry.RevokedAt
+			responses[i].Reason = entry.Reason
+		}
+
+		rs.logf("%s: %s", ca.FormatSerial(id.SerialNumber), responses[i].Status)
+	}
+
+	resp, err := createResponse(responses, req.Nonce, rs.Certificate, rs.Key, rs.Hash, rs.Certificate != rs.Issuer)
+	if err != nil {
+		rs.logf("%s", err)
+		return errorResponse(InternalError)
+	}
+
+	return resp
+}
+
+func (rs *Responder) write(w http.ResponseWriter, der []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(der)
+}
+
+func (rs *Responder) logf(format string, args ...any) {
+	if rs.Logf != nil {
+		rs.Logf(format, args...)
+	}
+}
@@ -0,0 +1,369 @@
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ocspResponse is the OCSPResponse of RFC 6960, section 4.2.1.
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []singleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponse struct {
+	CertID     certID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// revokedInfo is the RevokedInfo of a revoked certificate. The reason is left
+// out when it is unspecified, as RFC 5280 recommends.
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Tags of the ResponderID and CertStatus choices.
+const (
+	tagResponderName    = 1
+	tagResponderKeyHash = 2
+
+	tagGood    = 0
+	tagRevoked = 1
+	tagUnknown = 2
+)
+
+// signatureAlgorithms are the response signature algorithms: RSASSA-PKCS1-v1_5,
+// which every OCSP client supports.
+var signatureAlgorithms = []struct {
+	algorithm x509.SignatureAlgorithm
+	oid       asn1.ObjectIdentifier
+	hash      crypto.Hash
+}{
+	{x509.SHA256WithRSA, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, crypto.SHA256},
+	{x509.SHA384WithRSA, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, crypto.SHA384},
+	{x509.SHA512WithRSA, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, crypto.SHA512},
+}
+
+func signatureOID(hash crypto.Hash) asn1.ObjectIdentifier {
+	for _, a := range signatureAlgorithms {
+		if a.hash == hash {
+			return a.oid
+		}
+	}
+	return nil
+}
+
+// SingleResponse is the status of one certificate.
+type SingleResponse struct {
+	CertID CertID
+	Status Status
+
+	// RevokedAt and Reason, a CRLReason code, are set for revoked certificates.
+	RevokedAt time.Time
+	Reason    int
+
+	ThisUpdate time.Time
+	NextUpdate time.Time // zero when newer information is always available
+}
+
+// Response is a parsed OCSP response.
+type Response struct {
+	Status ResponseStatus
+
+	// The fields below are only set for successful responses.
+	ProducedAt time.Time
+	Responses  []SingleResponse
+	Nonce      []byte
+
+	// Exactly one of ResponderName and ResponderKeyHash is set.
+	ResponderName    []byte // DER
+	ResponderKeyHash []byte
+
+	// Certificates are the certificates sent with the response, such as a
+	// delegated responder certificate.
+	Certificates       []*x509.Certificate
+	SignatureAlgorithm x509.SignatureAlgorithm
+
+	tbsResponseData []byte
+	signature       []byte
+}
+
+// createResponse returns a DER OCSP response with the given certificate
+// statuses, signed by key. The responder is identified by the subject of cert,
+// which is sent along unless it is the issuer.
+func createResponse(responses []SingleResponse, nonce []byte, cert *x509.Certificate, key *rsa.PrivateKey, hash crypto.Hash, embed bool) ([]byte, error) {
+	oid := signatureOID(hash)
+	if oid == nil {
+		return nil, fmt.Errorf("unsupported response signature hash: %s (use sha256, sha384 or sha512)", hash)
+	}
+
+	data := responseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tagResponderName, IsCompound: true, Bytes: cert.RawSubject},
+		ProducedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+
+	for _, r := range responses {
+		id, err := r.CertID.marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		status, err := marshalStatus(r)
+		if err != nil {
+			return nil, err
+		}
+
+		data.Responses = append(data.Responses, singleResponse{
+			CertID:     id,
+			CertStatus: status,
+			ThisUpdate: r.ThisUpdate.UTC().Truncate(time.Second),
+			NextUpdate: r.NextUpdate.UTC().Truncate(time.Second),
+		})
+	}
+
+	if len(nonce) > 0 {
+		ext, err := nonceExtension(nonce)
+		if err != nil {
+			return nil, err
+		}
+		data.Extensions = []pkix.Extension{ext}
+	}
+
+	tbs, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(tbs)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("sign OCSP response: %w", err)
+	}
+
+	basic := basicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	}
+	if embed {
+		basic.Certificates = []asn1.RawValue{{FullBytes: cert.Raw}}
+	}
+
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status:        asn1.Enumerated(Successful),
+		ResponseBytes: responseBytes{ResponseType: oidBasicResponse, Response: basicDER},
+	})
+}
+
+func marshalStatus(r SingleResponse) (asn1.RawValue, error) {
+	switch r.Status {
+	case Good:
+		return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tagGood}, nil
+	case Unknown:
+		return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tagUnknown}, nil
+	}
+
+	der, err := asn1.Marshal(revokedInfo{RevocationTime: r.RevokedAt.UTC().Truncate(time.Second), Reason: asn1.Enumerated(r.Reason)})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	var info asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	// RevokedInfo is implicitly tagged: keep the SEQUENCE contents under [1].
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tagRevoked, IsCompound: true, Bytes: info.Bytes}, nil
+}
+
+// errorResponse returns an unsuccessful OCSP response, which is not signed.
+func errorResponse(status ResponseStatus) []byte {
+	der, _ := asn1.Marshal(ocspResponse{Status: asn1.Enumerated(status)})
+	return der
+}
+
+// ParseResponse parses a DER OCSP response. The signature is not checked;
+// see Verify.
+func ParseResponse(der []byte) (*Response, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, fmt.Errorf("parse OCSP response: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("parse OCSP response: trailing data")
+	}
+
+	parsed := &Response{Status: ResponseStatus(resp.Status)}
+	if parsed.Status != Successful {
+		return parsed, nil
+	}
+
+	if !resp.ResponseBytes.ResponseType.Equal(oidBasicResponse) {
+		return nil, fmt.Errorf("parse OCSP response: unsupported response type %s", resp.ResponseBytes.ResponseType)
+	}
+
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.ResponseBytes.Response, &basic); err != nil {
+		return nil, fmt.Errorf("parse basic OCSP response: %w", err)
+	}
+
+	var data responseData
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data); err != nil {
+		return nil, fmt.Errorf("parse OCSP response data: %w", err)
+	}
+
+	parsed.ProducedAt = data.ProducedAt
+	parsed.Nonce = findNonce(data.Extensions)
+	parsed.tbsResponseData = basic.TBSResponseData.FullBytes
+	parsed.signature = basic.Signature.RightAlign()
+	parsed.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	for _, a := range signatureAlgorithms {
+		if a.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			parsed.SignatureAlgorithm = a.algorithm
+		}
+	}
+
+	switch data.ResponderID.Tag {
+	case tagResponderName:
+		parsed.ResponderName = data.ResponderID.Bytes
+	case tagResponderKeyHash:
+		if _, err := asn1.Unmarshal(data.ResponderID.Bytes, &parsed.ResponderKeyHash); err != nil {
+			return nil, fmt.Errorf("parse OCSP responder key hash: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("parse OCSP response: invalid responder ID tag %d", data.ResponderID.Tag)
+	}
+
+	for _, raw := range basic.Certificates {
+		cert, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse OCSP response certificate: %w", err)
+		}
+		parsed.Certificates = append(parsed.Certificates, cert)
+	}
+
+	for _, r := range data.Responses {
+		single := SingleResponse{CertID: r.CertID.unmarshal(), ThisUpdate: r.ThisUpdate, NextUpdate: r.NextUpdate}
+
+		switch r.CertStatus.Tag {
+		case tagGood:
+			single.Status = Good
+		case tagRevoked:
+			var info revokedInfo
+			if _, err := asn1.UnmarshalWithParams(r.CertStatus.FullBytes, &info, "tag:1"); err != nil {
+				return nil, fmt.Errorf("parse OCSP revocation info: %w", err)
+			}
+			single.Status = Revoked
+			single.RevokedAt = info.RevocationTime
+			single.Reason = int(info.Reason)
+		default:
+			single.Status = Unknown
+		}
+
+		parsed.Responses = append(parsed.Responses, single)
+	}
+
+	return parsed, nil
+}
+
+// Verify checks the signature of a successful response, made either by the
+// issuer itself or by a delegated responder certificate the issuer issued for
+// OCSP signing (RFC 6960, section 4.2.2.2). It returns the signing certificate.
+func (r *Response) Verify(issuer *x509.Certificate, now time.Time) (*x509.Certificate, error) {
+	if r.Status != Successful {
+		return nil, fmt.Errorf("the response is %s, not signed", r.Status)
+	}
+
+	signer := issuer
+	if !r.identifies(issuer) {
+		i := slices.IndexFunc(r.Certificates, r.identifies)
+		if i < 0 {
+			return nil, errors.New("the response is signed neither by the issuer nor by a responder certificate it includes")
+		}
+
+		signer = r.Certificates[i]
+		if err := CheckResponderCertificate(signer, issuer, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := signer.CheckSignature(r.SignatureAlgorithm, r.tbsResponseData, r.signature); err != nil {
+		return nil, fmt.Errorf("invalid response signature: %w", err)
+	}
+
+	return signer, nil
+}
+
+// identifies reports whether the responder ID of the response names cert.
+func (r *Response) identifies(cert *x509.Certificate) bool {
+	if r.ResponderName != nil {
+		return bytes.Equal(r.ResponderName, cert.RawSubject)
+	}
+
+	key, err := subjectPublicKey(cert)
+	if err != nil {
+		return false
+	}
+
+	hash := sha1.Sum(key)
+	return bytes.Equal(r.ResponderKeyHash, hash[:])
+}
+
+// CheckResponderCertificate reports an error unless cert may sign responses
+// for the certificates of issuer: it must be issued by issuer for OCSP signing
+// and be valid at now.
+func CheckResponderCertificate(cert, issuer *x509.Certificate, now time.Time) error {
+	if err := cert.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("the responder certificate %s is not issued by %s: %w", cert.Subject, issuer.Subject, err)
+	}
+
+	if !slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+		return fmt.Errorf("the responder certificate %s lacks the OCSPSigning extended key usage", cert.Subject)
+	}
+
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("the responder certificate %s is not valid at %s", cert.Subject, now.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
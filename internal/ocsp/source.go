@@ -0,0 +1,121 @@
+package ocsp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tuanta7/keys/internal/ca"
+)
+
+// Entry is what a Source knows about a certificate.
+type Entry struct {
+	Status    Status
+	RevokedAt time.Time
+	Reason    int
+}
+
+// Source looks up the status of the certificates a responder answers for.
+type Source interface {
+	Lookup(serial *big.Int) (Entry, error)
+}
+
+// RevocationFile is a text file with one revoked certificate per line: its
+// serial number in hex, the revocation time in RFC 3339 and optionally the
+// revocation reason, separated by whitespace. Blank lines and lines starting
+// with # are ignored. Certificates that aren't listed are good.
+//
+//	# serial          revoked at            reason
+//	5A0F3C1B229E7104  2025-01-31T12:00:00Z  keyCompromise
+//
+// The file is read on every lookup, so edits apply to the next request.
+type RevocationFile struct {
+	Path string
+}
+
+// Lookup implements Source.
+func (f RevocationFile) Lookup(serial *big.Int) (Entry, error) {
+	entries, err := ReadRevocationFile(f.Path)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if entry, ok := entries[ca.FormatSerial(serial)]; ok {
+		return entry, nil
+	}
+	return Entry{Status: Good}, nil
+}
+
+// ReadRevocationFile reads a RevocationFile into entries keyed by serial
+// number, as formatted by ca.FormatSerial.
+func ReadRevocationFile(path string) (map[string]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]Entry)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected serial, revocation time and optional reason", path, line)
+		}
+
+		serial, err := ca.ParseSerial(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		at, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid revocation time %q: use RFC 3339", path, line, fields[1])
+		}
+
+		entry := Entry{Status: Revoked, RevokedAt: at}
+		if len(fields) == 3 {
+			if entry.Reason, err = ca.ParseReason(fields[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+		}
+
+		entries[ca.FormatSerial(serial)] = entry
+	}
+
+	return entries, scanner.Err()
+}
+
+// Database answers from the database of a CA directory: certificates the CA
+// didn't issue are unknown.
+type Database struct {
+	CA *ca.CA
+}
+
+// Lookup implements Source.
+func (d Database) Lookup(serial *big.Int) (Entry, error) {
+	records, err := d.CA.Records()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for _, record := range records {
+		if record.Serial != ca.FormatSerial(serial) {
+			continue
+		}
+
+		if !record.Revoked() {
+			return Entry{Status: Good}, nil
+		}
+		return Entry{Status: Revoked, RevokedAt: *record.RevokedAt, Reason: ca.ReasonCode(record.Reason)}, nil
+	}
+
+	return Entry{Status: Unknown}, nil
+}
@@ -0,0 +1,85 @@
+package sshkey
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+const bcryptBlockSize = 32
+
+var bcryptMagic = []byte("OxychromaticBlowfishSwatDynamite")
+
+// bcryptPBKDF derives a key as OpenBSD's bcrypt_pbkdf(3), used by OpenSSH to
+// protect private keys.
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: rounds must be at least 1")
+	}
+
+	if len(password) == 0 || len(salt) == 0 || keyLen <= 0 || keyLen > 1024 {
+		return nil, errors.New("bcrypt_pbkdf: invalid parameters")
+	}
+
+	numBlocks := (keyLen + bcryptBlockSize - 1) / bcryptBlockSize
+	key := make([]byte, numBlocks*bcryptBlockSize)
+
+	shapass := sha512.Sum512(password)
+	tmp := make([]byte, bcryptBlockSize)
+	out := make([]byte, bcryptBlockSize)
+
+	for block := 1; block <= numBlocks; block++ {
+		h := sha512.New()
+		h.Write(salt)
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		if err := bcryptHash(tmp, shapass[:], h.Sum(nil)); err != nil {
+			return nil, err
+		}
+		copy(out, tmp)
+
+		for i := 1; i < rounds; i++ {
+			shasalt := sha512.Sum512(tmp)
+			if err := bcryptHash(tmp, shapass[:], shasalt[:]); err != nil {
+				return nil, err
+			}
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// The output bytes are interleaved across blocks.
+		for i, v := range out {
+			key[i*numBlocks+block-1] = v
+		}
+	}
+
+	return key[:keyLen], nil
+}
+
+func bcryptHash(out, shapass, shasalt []byte) error {
+	c, err := blowfish.NewSaltedCipher(shapass, shasalt)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(shasalt, c)
+		blowfish.ExpandKey(shapass, c)
+	}
+
+	copy(out, bcryptMagic)
+	for i := 0; i < bcryptBlockSize; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+
+	// bcrypt works on little-endian 32-bit words.
+	for i := 0; i < bcryptBlockSize; i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = out[i+3], out[i+2], out[i+1], out[i]
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package sshkey
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestBcryptPBKDF uses test vectors generated with OpenBSD's bcrypt_pbkdf(3).
+func TestBcryptPBKDF(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		rounds         int
+		want           string
+	}{
+		{"password", "salt", 12, "1ae42c05d487bc02f64921a4ebe4ea93bcacfe135fda99974c06b7b01fae149a"},
+		{"passwordy\x00PASSWORD\x00", "salty\x00SALT\x00", 3, "7f310bd3e78c3280c59ce4595211a2928e8d4ec744c1ed2efc9f764e3388e0ad"},
+		{"секретное слово", "посолить немножко", 8, "8df43fc6fe131fc47f0c9e39224bd94c70b6fcc8ee8135faddf61156e6cb2733ea765f315a3e1e4afc35bf8687d189254c1e05a6fe80c0617f9183d67260d6a115c6c94e3603e2303fbb43a76a64523ffda686b1d4518543"},
+	}
+
+	for i, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got, err := bcryptPBKDF([]byte(tt.password), []byte(tt.salt), tt.rounds, len(want))
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d: got %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestBcryptHash(t *testing.T) {
+	var pass, salt [64]byte
+	for i := range pass {
+		pass[i] = byte(i)
+		salt[i] = byte(i + 64)
+	}
+
+	out := make([]byte, bcryptBlockSize)
+	if err := bcryptHash(out, pass[:], salt[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := hex.DecodeString("87904870eef9deddf8e7611a140106e6aaf1a363d9a2c504db356443721eb555")
+	if !bytes.Equal(out, want) {
+		t.Errorf("got %x, want %x", out, want)
+	}
+}
+
+func TestBcryptPBKDFRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name           string
+		password, salt string
+		rounds, keyLen int
+	}{
+		{"no rounds", "password", "salt", 0, 32},
+		{"empty password", "", "salt", 16, 32},
+		{"empty salt", "password", "", 16, 32},
+		{"no key", "password", "salt", 16, 0},
+		{"key too long", "password", "salt", 16, 1025},
+	}
+
+	for _, tt := range tests {
+		if _, err := bcryptPBKDF([]byte(tt.password), []byte(tt.salt), tt.rounds, tt.keyLen); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sshkey
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	randomartWidth   = 17
+	randomartHeight  = 9
+	randomartSymbols = " .o+=*BOX@%&#/^SE"
+)
+
+// FingerprintSHA256 returns the fingerprint ssh-keygen prints by default,
+// "SHA256:" followed by the unpadded base64 SHA-256 of the public key blob.
+func FingerprintSHA256(key *rsa.PublicKey) string {
+	sum := sha256.Sum256(MarshalPublicKey(key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// FingerprintMD5 returns the legacy colon-separated MD5 fingerprint.
+func FingerprintMD5(key *rsa.PublicKey) string {
+	sum := md5.Sum(MarshalPublicKey(key))
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return "MD5:" + strings.Join(parts, ":")
+}
+
+// Randomart draws the SHA-256 fingerprint of the key the way "ssh-keygen -lv"
+// does, using the drunken bishop walk.
+func Randomart(key *rsa.PublicKey) string {
+	sum := sha256.Sum256(MarshalPublicKey(key))
+
+	var field [randomartWidth][randomartHeight]int
+	x, y := randomartWidth/2, randomartHeight/2
+	last := len(randomartSymbols) - 1
+
+	for _, b := range sum {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+
+			x = min(max(x, 0), randomartWidth-1)
+			y = min(max(y, 0), randomartHeight-1)
+
+			if field[x][y] < last-2 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+
+	field[randomartWidth/2][randomartHeight/2] = last - 1
+	field[x][y] = last
+
+	var out strings.Builder
+	out.WriteString(randomartBorder(fmt.Sprintf("[RSA %d]", key.N.BitLen())))
+	for y := 0; y < randomartHeight; y++ {
+		out.WriteByte('|')
+		for x := 0; x < randomartWidth; x++ {
+			out.WriteByte(randomartSymbols[min(field[x][y], last)])
+		}
+		out.WriteString("|\n")
+	}
+	out.WriteString(randomartBorder("[SHA256]"))
+
+	return out.String()
+}
+
+func randomartBorder(title string) string {
+	left := (randomartWidth - len(title)) / 2
+	right := randomartWidth - left - len(title)
+	return "+" + strings.Repeat("-", left) + title + strings.Repeat("-", right) + "+\n"
+}
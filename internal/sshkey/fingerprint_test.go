@@ -0,0 +1,36 @@
+package sshkey
+
+import "testing"
+
+// TestFingerprints compares with "ssh-keygen -l", "ssh-keygen -l -E md5" and
+// "ssh-keygen -lv" of testAuthorizedKey.
+func TestFingerprints(t *testing.T) {
+	parsed, err := ParseAuthorizedKey([]byte(testAuthorizedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := FingerprintSHA256(parsed.Key), "SHA256:5PnT8wX1nJnXNJeHzpRs1mGmNt4U+Uq1GDDuQeBdp38"; got != want {
+		t.Errorf("SHA-256: got %s, want %s", got, want)
+	}
+
+	if got, want := FingerprintMD5(parsed.Key), "MD5:bc:68:6a:43:bd:78:ac:cc:21:21:69:5b:56:db:5b:77"; got != want {
+		t.Errorf("MD5: got %s, want %s", got, want)
+	}
+
+	const randomart = `+---[RSA 1024]----+
+|         ..+.. *.|
+|        . + oo*==|
+|        .. + =X=O|
+|       o .. +B*=@|
+|        S  . o+BE|
+|         . .  ..o|
+|          o o   .|
+|           . o . |
+|              .  |
++----[SHA256]-----+
+`
+	if got := Randomart(parsed.Key); got != randomart {
+		t.Errorf("randomart: got\n%s\nwant\n%s", got, randomart)
+	}
+}
@@ -0,0 +1,381 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	PPKCipherNone      = "none"
+	PPKCipherAES256CBC = "aes256-cbc"
+
+	// Defaults of puttygen for version 3 files.
+	DefaultArgon2Memory      = 8192 // KiB
+	DefaultArgon2Passes      = 13
+	DefaultArgon2Parallelism = 1
+
+	// Limits on the Argon2 parameters read from a file, far above what puttygen
+	// writes, so that a crafted file can't exhaust memory or pin the CPU.
+	MaxArgon2Memory = 1 << 20 // KiB
+	MaxArgon2Passes = 1000
+
+	ppkPrefix     = "PuTTY-User-Key-File-"
+	ppkMACKeyV2   = "putty-private-key-file-mac-key"
+	ppkLineLength = 64
+)
+
+// ErrPPKMAC is returned when the MAC of an unencrypted PPK file doesn't match.
+var ErrPPKMAC = errors.New("ssh: PPK MAC verification failed, the file is corrupted")
+
+// PPKKey is an RSA key read from a PuTTY private key file (version 2 or 3).
+type PPKKey struct {
+	Key     *rsa.PrivateKey
+	Comment string
+	Version int
+	Cipher  string
+
+	// Argon2 parameters, set for encrypted version 3 files.
+	KDF         string // Argon2id, Argon2i or Argon2d
+	Memory      uint32 // KiB
+	Passes      uint32
+	Parallelism uint8
+}
+
+// Encrypted reports whether the key was protected with a passphrase.
+func (k *PPKKey) Encrypted() bool {
+	return k.Cipher != PPKCipherNone
+}
+
+// PPKOptions controls how MarshalPPK writes a key.
+type PPKOptions struct {
+	Version    int // 2 or 3, default 3
+	Comment    string
+	Passphrase []byte // encrypts with aes256-cbc when not empty
+	Passes     uint32 // Argon2id passes, default DefaultArgon2Passes
+}
+
+// IsPPK reports whether data is a PuTTY private key file.
+func IsPPK(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(ppkPrefix))
+}
+
+// ppkFile holds the fields of a PPK file in the order they appear.
+type ppkFile struct {
+	fields  map[string]string
+	public  []byte
+	private []byte
+}
+
+// ParsePPK decodes a PuTTY private key file. passphrase is only called when
+// the key is encrypted.
+func ParsePPK(data []byte, passphrase func() ([]byte, error)) (*PPKKey, error) {
+	f, err := readPPKFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &PPKKey{Comment: f.fields["Comment"], Cipher: f.fields["Encryption"]}
+
+	var algo string
+	for _, v := range []int{2, 3} {
+		if a, ok := f.fields[ppkPrefix+strconv.Itoa(v)]; ok {
+			key.Version, algo = v, a
+		}
+	}
+
+	if key.Version == 0 {
+		return nil, errors.New("ssh: unsupported PPK version, only versions 2 and 3 are supported")
+	}
+
+	if algo != KeyAlgoRSA {
+		return nil, fmt.Errorf("ssh: unsupported key type: %s", algo)
+	}
+
+	if key.Cipher != PPKCipherNone && key.Cipher != PPKCipherAES256CBC {
+		return nil, fmt.Errorf("ssh: unsupported PPK cipher: %s", key.Cipher)
+	}
+
+	mac, err := hex.DecodeString(f.fields["Private-MAC"])
+	if err != nil || len(mac) == 0 {
+		return nil, errors.New("ssh: missing or malformed Private-MAC")
+	}
+
+	var pass []byte
+	if key.Encrypted() {
+		if pass, err = passphrase(); err != nil {
+			return nil, err
+		}
+	}
+
+	var salt []byte
+	if key.Version == 3 && key.Encrypted() {
+		key.KDF = f.fields["Key-Derivation"]
+		memory, err1 := strconv.ParseUint(f.fields["Argon2-Memory"], 10, 32)
+		passes, err2 := strconv.ParseUint(f.fields["Argon2-Passes"], 10, 32)
+		parallelism, err3 := strconv.ParseUint(f.fields["Argon2-Parallelism"], 10, 8)
+		salt, err = hex.DecodeString(f.fields["Argon2-Salt"])
+		if err := errors.Join(err1, err2, err3, err); err != nil {
+			return nil, fmt.Errorf("ssh: malformed Argon2 parameters: %w", err)
+		}
+		key.Memory, key.Passes, key.Parallelism = uint32(memory), uint32(passes), uint8(parallelism)
+	}
+
+	cipherKey, iv, macKey, newMAC, err := key.deriveKeys(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	private := f.private
+	if key.Encrypted() {
+		if len(private)%aes.BlockSize != 0 {
+			return nil, errors.New("ssh: encrypted PPK section is not a multiple of the block size")
+		}
+
+		block, err := aes.NewCipher(cipherKey)
+		if err != nil {
+			return nil, err
+		}
+		private = make([]byte, len(f.private))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(private, f.private)
+	}
+
+	expected := ppkMAC(newMAC, macKey, algo, key.Cipher, key.Comment, f.public, private)
+	if !hmac.Equal(mac, expected) {
+		if key.Encrypted() {
+			return nil, ErrIncorrectPassphrase
+		}
+		return nil, ErrPPKMAC
+	}
+
+	pub, err := ParsePublicKey(f.public)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{b: private}
+	d := r.mpint()
+	p := r.mpint()
+	q := r.mpint()
+	r.mpint() // iqmp, recomputed by Precompute
+	if r.err != nil {
+		return nil, fmt.Errorf("ssh: malformed PPK private key: %w", r.err)
+	}
+
+	key.Key = &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+	if err := key.Key.Validate(); err != nil {
+		return nil, fmt.Errorf("ssh: invalid RSA key: %w", err)
+	}
+	key.Key.Precompute()
+
+	return key, nil
+}
+
+// MarshalPPK encodes a key as a PuTTY private key file.
+func MarshalPPK(key *rsa.PrivateKey, opts PPKOptions) ([]byte, error) {
+	if len(key.Primes) != 2 {
+		return nil, errors.New("ssh: multi-prime RSA keys are not supported")
+	}
+
+	k := &PPKKey{Comment: opts.Comment, Version: opts.Version, Cipher: PPKCipherNone}
+	if k.Version == 0 {
+		k.Version = 3
+	}
+	if k.Version != 2 && k.Version != 3 {
+		return nil, fmt.Errorf("ssh: unsupported PPK version: %d", k.Version)
+	}
+
+	var salt []byte
+	if len(opts.Passphrase) > 0 {
+		k.Cipher = PPKCipherAES256CBC
+		if k.Version == 3 {
+			k.KDF, k.Memory, k.Passes, k.Parallelism = "Argon2id", DefaultArgon2Memory, opts.Passes, DefaultArgon2Parallelism
+			if k.Passes == 0 {
+				k.Passes = DefaultArgon2Passes
+			}
+
+			salt = make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	precomputed := key.Precomputed
+	if precomputed.Qinv == nil {
+		key.Precompute()
+		precomputed = key.Precomputed
+	}
+
+	private := appendMPInt(nil, key.D)
+	private = appendMPInt(private, key.Primes[0])
+	private = appendMPInt(private, key.Primes[1])
+	private = appendMPInt(private, precomputed.Qinv)
+
+	// As puttygen does, pad with the SHA-1 hash of the unpadded section.
+	if k.Encrypted() {
+		digest := sha1.Sum(private)
+		private = append(private, digest[:(aes.BlockSize-len(private)%aes.BlockSize)%aes.BlockSize]...)
+	}
+
+	public := MarshalPublicKey(&key.PublicKey)
+
+	cipherKey, iv, macKey, newMAC, err := k.deriveKeys(opts.Passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	mac := ppkMAC(newMAC, macKey, KeyAlgoRSA, k.Cipher, k.Comment, public, private)
+
+	if k.Encrypted() {
+		block, err := aes.NewCipher(cipherKey)
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(private, private)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%d: %s\n", ppkPrefix, k.Version, KeyAlgoRSA)
+	fmt.Fprintf(&b, "Encryption: %s\n", k.Cipher)
+	fmt.Fprintf(&b, "Comment: %s\n", k.Comment)
+	writePPKLines(&b, "Public-Lines", public)
+	if k.KDF != "" {
+		fmt.Fprintf(&b, "Key-Derivation: %s\n", k.KDF)
+		fmt.Fprintf(&b, "Argon2-Memory: %d\n", k.Memory)
+		fmt.Fprintf(&b, "Argon2-Passes: %d\n", k.Passes)
+		fmt.Fprintf(&b, "Argon2-Parallelism: %d\n", k.Parallelism)
+		fmt.Fprintf(&b, "Argon2-Salt: %x\n", salt)
+	}
+	writePPKLines(&b, "Private-Lines", private)
+	fmt.Fprintf(&b, "Private-MAC: %x\n", mac)
+
+	return []byte(b.String()), nil
+}
+
+// deriveKeys returns the AES-256-CBC key and IV protecting the private section
+// and the MAC key and hash covering the file.
+func (k *PPKKey) deriveKeys(passphrase, salt []byte) (cipherKey, iv, macKey []byte, newMAC func() hash.Hash, err error) {
+	if k.Version == 2 {
+		// SHA-1(uint32 counter || passphrase) for counters 0 and 1, IV of zeros.
+		for counter := uint32(0); counter < 2; counter++ {
+			h := sha1.New()
+			binary.Write(h, binary.BigEndian, counter)
+			h.Write(passphrase)
+			cipherKey = h.Sum(cipherKey)
+		}
+
+		mk := sha1.Sum(append([]byte(ppkMACKeyV2), passphrase...))
+		return cipherKey[:32], make([]byte, aes.BlockSize), mk[:], sha1.New, nil
+	}
+
+	if !k.Encrypted() {
+		return nil, nil, nil, sha256.New, nil
+	}
+
+	if k.Passes < 1 || k.Passes > MaxArgon2Passes {
+		return nil, nil, nil, nil, fmt.Errorf("ssh: Argon2 passes %d is outside 1 to %d", k.Passes, MaxArgon2Passes)
+	}
+
+	if k.Parallelism < 1 {
+		return nil, nil, nil, nil, errors.New("ssh: Argon2 parallelism must be at least 1")
+	}
+
+	if k.Memory > MaxArgon2Memory {
+		return nil, nil, nil, nil, fmt.Errorf("ssh: Argon2 memory %d KiB is above the limit of %d KiB", k.Memory, MaxArgon2Memory)
+	}
+
+	var out []byte
+	switch k.KDF {
+	case "Argon2id":
+		out = argon2.IDKey(passphrase, salt, k.Passes, k.Memory, k.Parallelism, 80)
+	case "Argon2i":
+		out = argon2.Key(passphrase, salt, k.Passes, k.Memory, k.Parallelism, 80)
+	default:
+		return nil, nil, nil, nil, fmt.Errorf("ssh: unsupported PPK key derivation: %s", k.KDF)
+	}
+
+	return out[:32], out[32:48], out[48:], sha256.New, nil
+}
+
+// ppkMAC is the HMAC over the algorithm, cipher, comment, public section and
+// the decrypted, padded private section, each as an SSH string.
+func ppkMAC(newMAC func() hash.Hash, key []byte, algo, cipherName, comment string, public, private []byte) []byte {
+	var data []byte
+	data = appendString(data, []byte(algo))
+	data = appendString(data, []byte(cipherName))
+	data = appendString(data, []byte(comment))
+	data = appendString(data, public)
+	data = appendString(data, private)
+
+	m := hmac.New(newMAC, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func readPPKFile(data []byte) (*ppkFile, error) {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(data)), "\r\n", "\n"), "\n")
+	f := &ppkFile{fields: map[string]string{}}
+
+	for i := 0; i < len(lines); i++ {
+		name, value, ok := strings.Cut(lines[i], ":")
+		value = strings.TrimPrefix(value, " ")
+		if !ok {
+			return nil, fmt.Errorf("ssh: malformed PPK line %d", i+1)
+		}
+		f.fields[name] = value
+
+		if name != "Public-Lines" && name != "Private-Lines" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || i+n >= len(lines) {
+			return nil, fmt.Errorf("ssh: malformed %s", name)
+		}
+
+		section, err := base64.StdEncoding.DecodeString(strings.Join(lines[i+1:i+1+n], ""))
+		if err != nil {
+			return nil, fmt.Errorf("ssh: malformed PPK %s: %w", strings.ToLower(strings.TrimSuffix(name, "-Lines")), err)
+		}
+		i += n
+
+		if name == "Public-Lines" {
+			f.public = section
+		} else {
+			f.private = section
+		}
+	}
+
+	if f.public == nil || f.private == nil {
+		return nil, errors.New("ssh: PPK file lacks a public or private section")
+	}
+
+	return f, nil
+}
+
+func writePPKLines(b *strings.Builder, name string, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	n := (len(encoded) + ppkLineLength - 1) / ppkLineLength
+	fmt.Fprintf(b, "%s: %d\n", name, n)
+	for len(encoded) > ppkLineLength {
+		b.WriteString(encoded[:ppkLineLength] + "\n")
+		encoded = encoded[ppkLineLength:]
+	}
+	b.WriteString(encoded + "\n")
+}
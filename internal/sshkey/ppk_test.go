@@ -0,0 +1,116 @@
+package sshkey
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMarshalPPKRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version    int
+		passphrase string
+	}{
+		{2, ""}, {2, "secret"}, {3, ""}, {3, "secret"},
+	}
+
+	for _, tt := range tests {
+		data, err := MarshalPPK(key, PPKOptions{Version: tt.version, Comment: "alice@laptop", Passphrase: []byte(tt.passphrase), Passes: 1})
+		if err != nil {
+			t.Fatalf("version %d: %v", tt.version, err)
+		}
+
+		parsed, err := ParsePPK(data, passphrase(tt.passphrase))
+		if err != nil {
+			t.Fatalf("version %d, passphrase %q: %v", tt.version, tt.passphrase, err)
+		}
+		if !parsed.Key.Equal(key) || parsed.Comment != "alice@laptop" || parsed.Version != tt.version {
+			t.Fatalf("version %d, passphrase %q: got %+v", tt.version, tt.passphrase, parsed)
+		}
+	}
+}
+
+func TestParsePPKWrongPassphrase(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalPPK(key, PPKOptions{Passphrase: []byte("secret"), Passes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParsePPK(data, passphrase("wrong")); err == nil {
+		t.Fatal("accepted a wrong passphrase")
+	}
+}
+
+// TestParsePPKMalformedArgon2 changes the Argon2 header lines of a valid
+// encrypted file; every change must be rejected before Argon2 runs.
+func TestParsePPKMalformedArgon2(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalPPK(key, PPKOptions{Passphrase: []byte("secret"), Passes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header, value string
+		want          string
+	}{
+		{"Argon2-Passes", "0", "passes 0"},
+		{"Argon2-Passes", "4294967295", "passes 4294967295"},
+		{"Argon2-Parallelism", "0", "parallelism"},
+		{"Argon2-Memory", "4294967295", "memory 4294967295 KiB"},
+		{"Argon2-Memory", "4294967296", "malformed Argon2 parameters"},
+		{"Argon2-Passes", "-1", "malformed Argon2 parameters"},
+		{"Argon2-Parallelism", "256", "malformed Argon2 parameters"},
+		{"Argon2-Salt", "xyz", "malformed Argon2 parameters"},
+		{"Key-Derivation", "Argon2x", "unsupported PPK key derivation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header+"="+tt.value, func(t *testing.T) {
+			line := regexp.MustCompile(`(?m)^` + tt.header + `: .*$`)
+			if !line.Match(data) {
+				t.Fatalf("no %s line", tt.header)
+			}
+			malformed := line.ReplaceAll(data, []byte(tt.header+": "+tt.value))
+
+			_, err := ParsePPK(malformed, passphrase("secret"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePPKCorruptedMAC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalPPK(key, PPKOptions{Comment: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := []byte(strings.Replace(string(data), "Comment: alice", "Comment: mallory", 1))
+	if _, err := ParsePPK(tampered, passphrase("")); !errors.Is(err, ErrPPKMAC) {
+		t.Fatalf("got %v, want %v", err, ErrPPKMAC)
+	}
+}
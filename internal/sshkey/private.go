@@ -0,0 +1,316 @@
+// Package sshkey reads and writes RSA keys in the formats used by SSH tooling.
+package sshkey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	KeyAlgoRSA = "ssh-rsa"
+
+	CipherNone      = "none"
+	CipherAES256CTR = "aes256-ctr"
+	KDFNone         = "none"
+	KDFBcrypt       = "bcrypt"
+
+	DefaultRounds = 16
+	// MaxRounds limits the bcrypt rounds read from a file, far above what
+	// ssh-keygen writes, so that a crafted key can't pin the CPU.
+	MaxRounds = 1000
+
+	privateKeyMagic = "openssh-key-v1\x00"
+	saltSize        = 16
+)
+
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase or corrupted key")
+
+// PrivateKey is an RSA key read from the openssh-key-v1 format ("OPENSSH PRIVATE KEY").
+type PrivateKey struct {
+	Key     *rsa.PrivateKey
+	Comment string
+	Cipher  string
+	KDF     string
+	Rounds  int
+}
+
+// Encrypted reports whether the key was protected with a passphrase.
+func (k *PrivateKey) Encrypted() bool {
+	return k.Cipher != CipherNone
+}
+
+// sshCipher is an AES cipher of the private key section, in CTR or CBC mode.
+type sshCipher struct {
+	keySize int
+	cbc     bool
+}
+
+var sshCiphers = map[string]sshCipher{
+	"aes128-ctr": {16, false},
+	"aes192-ctr": {24, false},
+	"aes256-ctr": {32, false},
+	"aes128-cbc": {16, true},
+	"aes256-cbc": {32, true},
+}
+
+// ParsePrivateKey decodes the contents of an "OPENSSH PRIVATE KEY" PEM block.
+// passphrase is only called when the key is encrypted.
+func ParsePrivateKey(data []byte, passphrase func() ([]byte, error)) (*PrivateKey, error) {
+	if !bytes.HasPrefix(data, []byte(privateKeyMagic)) {
+		return nil, errors.New("ssh: not an openssh-key-v1 private key")
+	}
+
+	r := &reader{b: data[len(privateKeyMagic):]}
+	cipherName := string(r.string())
+	kdfName := string(r.string())
+	kdfOptions := r.string()
+	count := r.uint32()
+	r.string() // public key, repeated in the private section
+	private := r.string()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if count != 1 {
+		return nil, fmt.Errorf("ssh: files with %d keys are not supported", count)
+	}
+
+	key := &PrivateKey{Cipher: cipherName, KDF: kdfName}
+
+	blockSize := 8
+	if cipherName != CipherNone {
+		c, ok := sshCiphers[cipherName]
+		if !ok {
+			return nil, fmt.Errorf("ssh: unsupported cipher: %s", cipherName)
+		}
+
+		if kdfName != KDFBcrypt {
+			return nil, fmt.Errorf("ssh: unsupported KDF: %s", kdfName)
+		}
+
+		opts := &reader{b: kdfOptions}
+		salt := opts.string()
+		rounds := opts.uint32()
+		if opts.err != nil {
+			return nil, fmt.Errorf("ssh: malformed bcrypt options: %w", opts.err)
+		}
+
+		if rounds < 1 || rounds > MaxRounds {
+			return nil, fmt.Errorf("ssh: bcrypt rounds %d is outside 1 to %d", rounds, MaxRounds)
+		}
+		key.Rounds = int(rounds)
+
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pass) == 0 {
+			return nil, ErrIncorrectPassphrase
+		}
+
+		derived, err := bcryptPBKDF(pass, salt, key.Rounds, c.keySize+aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(private)%aes.BlockSize != 0 {
+			return nil, errors.New("ssh: encrypted section is not a multiple of the block size")
+		}
+
+		private = bytes.Clone(private)
+		if err := crypt(c, derived[:c.keySize], derived[c.keySize:], private, false); err != nil {
+			return nil, err
+		}
+		blockSize = aes.BlockSize
+	} else if kdfName != KDFNone {
+		return nil, fmt.Errorf("ssh: unencrypted key with KDF %s", kdfName)
+	}
+
+	p := &reader{b: private}
+	check1 := p.uint32()
+	check2 := p.uint32()
+	if p.err != nil || check1 != check2 {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	keyType := string(p.string())
+	if p.err == nil && keyType != KeyAlgoRSA {
+		return nil, fmt.Errorf("ssh: unsupported key type: %s", keyType)
+	}
+
+	n := p.mpint()
+	e := p.mpint()
+	d := p.mpint()
+	p.mpint() // iqmp, recomputed by Precompute
+	prime0 := p.mpint()
+	prime1 := p.mpint()
+	key.Comment = string(p.string())
+	if p.err != nil {
+		return nil, fmt.Errorf("ssh: malformed private key: %w", p.err)
+	}
+
+	if len(private)%blockSize != 0 {
+		return nil, errors.New("ssh: private section is not padded to the block size")
+	}
+
+	for i, b := range p.b {
+		if int(b) != i+1 {
+			return nil, errors.New("ssh: invalid private key padding")
+		}
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("ssh: public exponent too large")
+	}
+
+	key.Key = &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+		D:         d,
+		Primes:    []*big.Int{prime0, prime1},
+	}
+
+	if err := key.Key.Validate(); err != nil {
+		return nil, fmt.Errorf("ssh: invalid RSA key: %w", err)
+	}
+	key.Key.Precompute()
+
+	return key, nil
+}
+
+// MarshalPrivateKey encodes the contents of an "OPENSSH PRIVATE KEY" PEM block.
+// A non-empty passphrase encrypts the key with bcrypt_pbkdf and aes256-ctr.
+func MarshalPrivateKey(key *rsa.PrivateKey, comment string, passphrase []byte, rounds int) ([]byte, error) {
+	if len(key.Primes) != 2 {
+		return nil, errors.New("ssh: multi-prime RSA keys are not supported")
+	}
+
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+
+	precomputed := key.Precomputed
+	if precomputed.Qinv == nil {
+		key.Precompute()
+		precomputed = key.Precomputed
+	}
+
+	private := append([]byte(nil), check[:]...)
+	private = append(private, check[:]...)
+	private = appendString(private, []byte(KeyAlgoRSA))
+	private = appendMPInt(private, key.N)
+	private = appendMPInt(private, big.NewInt(int64(key.E)))
+	private = appendMPInt(private, key.D)
+	private = appendMPInt(private, precomputed.Qinv)
+	private = appendMPInt(private, key.Primes[0])
+	private = appendMPInt(private, key.Primes[1])
+	private = appendString(private, []byte(comment))
+
+	cipherName, kdfName := CipherNone, KDFNone
+	var kdfOptions []byte
+	blockSize := 8
+
+	var c sshCipher
+	var derived []byte
+	if len(passphrase) > 0 {
+		if rounds <= 0 {
+			rounds = DefaultRounds
+		}
+		if rounds > MaxRounds {
+			return nil, fmt.Errorf("ssh: bcrypt rounds %d over %d", rounds, MaxRounds)
+		}
+
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		c = sshCiphers[CipherAES256CTR]
+		var err error
+		derived, err = bcryptPBKDF(passphrase, salt, rounds, c.keySize+aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+
+		cipherName, kdfName = CipherAES256CTR, KDFBcrypt
+		kdfOptions = appendUint32(appendString(nil, salt), uint32(rounds))
+		blockSize = aes.BlockSize
+	}
+
+	for i := 1; len(private)%blockSize != 0; i++ {
+		private = append(private, byte(i))
+	}
+
+	if derived != nil {
+		if err := crypt(c, derived[:c.keySize], derived[c.keySize:], private, true); err != nil {
+			return nil, err
+		}
+	}
+
+	out := []byte(privateKeyMagic)
+	out = appendString(out, []byte(cipherName))
+	out = appendString(out, []byte(kdfName))
+	out = appendString(out, kdfOptions)
+	out = appendUint32(out, 1)
+	out = appendString(out, MarshalPublicKey(&key.PublicKey))
+	out = appendString(out, private)
+
+	return out, nil
+}
+
+// MarshalPublicKey returns the SSH wire encoding of an "ssh-rsa" public key.
+func MarshalPublicKey(key *rsa.PublicKey) []byte {
+	b := appendString(nil, []byte(KeyAlgoRSA))
+	b = appendMPInt(b, big.NewInt(int64(key.E)))
+	return appendMPInt(b, key.N)
+}
+
+// ParsePublicKey decodes the SSH wire encoding of an "ssh-rsa" public key.
+func ParsePublicKey(blob []byte) (*rsa.PublicKey, error) {
+	r := &reader{b: blob}
+	algo := string(r.string())
+	e := r.mpint()
+	n := r.mpint()
+	if r.err != nil {
+		return nil, fmt.Errorf("ssh: malformed public key: %w", r.err)
+	}
+
+	if algo != KeyAlgoRSA {
+		return nil, fmt.Errorf("ssh: unsupported key type: %s", algo)
+	}
+
+	if len(r.b) > 0 {
+		return nil, errors.New("ssh: trailing data after public key")
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("ssh: public exponent too large")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func crypt(c sshCipher, key, iv, data []byte, encrypt bool) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !c.cbc:
+		cipher.NewCTR(block, iv).XORKeyStream(data, data)
+	case encrypt:
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	default:
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package sshkey
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func passphrase(s string) func() ([]byte, error) {
+	return func() ([]byte, error) { return []byte(s), nil }
+}
+
+func TestMarshalPrivateKeyRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pass := range []string{"", "secret"} {
+		data, err := MarshalPrivateKey(key, "alice@laptop", []byte(pass), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := ParsePrivateKey(data, passphrase(pass))
+		if err != nil {
+			t.Fatalf("passphrase %q: %v", pass, err)
+		}
+		if !parsed.Key.Equal(key) || parsed.Comment != "alice@laptop" || parsed.Encrypted() != (pass != "") {
+			t.Fatalf("passphrase %q: got %+v", pass, parsed)
+		}
+	}
+}
+
+func TestParsePrivateKeyWrongPassphrase(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalPrivateKey(key, "", []byte("secret"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pass := range []string{"wrong", ""} {
+		if _, err := ParsePrivateKey(data, passphrase(pass)); !errors.Is(err, ErrIncorrectPassphrase) {
+			t.Errorf("passphrase %q: got %v, want %v", pass, err, ErrIncorrectPassphrase)
+		}
+	}
+}
+
+// TestParsePrivateKeyRounds checks that the bcrypt rounds of a crafted file
+// are rejected before the passphrase is asked for and the key derived.
+func TestParsePrivateKeyRounds(t *testing.T) {
+	for _, rounds := range []uint32{0, MaxRounds + 1, 1<<32 - 1} {
+		kdfOptions := appendUint32(appendString(nil, make([]byte, saltSize)), rounds)
+
+		data := []byte(privateKeyMagic)
+		data = appendString(data, []byte(CipherAES256CTR))
+		data = appendString(data, []byte(KDFBcrypt))
+		data = appendString(data, kdfOptions)
+		data = appendUint32(data, 1)
+		data = appendString(data, nil)
+		data = appendString(data, make([]byte, 32))
+
+		_, err := ParsePrivateKey(data, func() ([]byte, error) {
+			t.Fatalf("rounds %d: asked for the passphrase", rounds)
+			return nil, nil
+		})
+		if err == nil {
+			t.Fatalf("rounds %d: accepted", rounds)
+		}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MarshalPrivateKey(key, "", []byte("secret"), MaxRounds+1); err == nil {
+		t.Fatalf("wrote a key with %d rounds", MaxRounds+1)
+	}
+}
+
+// TestSSHKeygenInterop reads a key encrypted by ssh-keygen, and has ssh-keygen
+// read a key encrypted by MarshalPrivateKey.
+func TestSSHKeygenInterop(t *testing.T) {
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not found")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "id_rsa")
+	out, err := exec.Command(sshKeygen, "-q", "-t", "rsa", "-b", "2048", "-a", "4", "-N", "secret", "-C", "alice@laptop", "-f", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh-keygen: %v: %s", err, out)
+	}
+
+	block := readPEM(t, path)
+	parsed, err := ParsePrivateKey(block.Bytes, passphrase("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Cipher != CipherAES256CTR || parsed.KDF != KDFBcrypt || parsed.Rounds != 4 || parsed.Comment != "alice@laptop" {
+		t.Fatalf("got cipher %s, KDF %s, %d rounds, comment %q", parsed.Cipher, parsed.KDF, parsed.Rounds, parsed.Comment)
+	}
+
+	pub, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ParseAuthorizedKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authorized.Key.Equal(&parsed.Key.PublicKey) {
+		t.Fatal("the private key doesn't match the public key written by ssh-keygen")
+	}
+
+	// And the other way around.
+	data, err := MarshalPrivateKey(parsed.Key, "bob@laptop", []byte("other"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := filepath.Join(dir, "ours")
+	if err := os.WriteFile(ours, pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err = exec.Command(sshKeygen, "-y", "-P", "other", "-f", ours).Output()
+	if err != nil {
+		t.Fatalf("ssh-keygen -y: %v", err)
+	}
+	authorized, err = ParseAuthorizedKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authorized.Key.Equal(&parsed.Key.PublicKey) {
+		t.Fatal("ssh-keygen derived a different public key")
+	}
+}
+
+func readPEM(t *testing.T, path string) *pem.Block {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s: no PEM block", path)
+	}
+	return block
+}
@@ -0,0 +1,125 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// AuthorizedKey is a public key read from an authorized_keys line
+// ("[options] ssh-rsa AAAA... [comment]").
+type AuthorizedKey struct {
+	Key     *rsa.PublicKey
+	Comment string
+	Options []string // e.g. `from="10.0.0.0/8"`, `command="uptime"`, `restrict`
+}
+
+// IsAuthorizedKeys reports whether data looks like an authorized_keys file or
+// a single public key line, as opposed to PEM, DER or JSON.
+func IsAuthorizedKeys(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	line, ok := firstKeyLine(data)
+	return ok && strings.Contains(line, "ssh-")
+}
+
+// ParseAuthorizedKey decodes the first key of an authorized_keys file, skipping
+// blank lines and "#" comments.
+func ParseAuthorizedKey(data []byte) (*AuthorizedKey, error) {
+	line, ok := firstKeyLine(data)
+	if !ok {
+		return nil, errors.New("ssh: no public key found")
+	}
+
+	var options []string
+	if !strings.HasPrefix(line, "ssh-") && !strings.HasPrefix(line, "ecdsa-") {
+		var rest string
+		var err error
+		options, rest, err = parseOptions(line)
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimLeft(rest, " \t")
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("ssh: malformed public key line")
+	}
+
+	if fields[0] != KeyAlgoRSA {
+		return nil, fmt.Errorf("ssh: unsupported key type: %s", fields[0])
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("ssh: malformed public key data: %w", err)
+	}
+
+	key, err := ParsePublicKey(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizedKey{
+		Key:     key,
+		Comment: strings.Join(fields[2:], " "),
+		Options: options,
+	}, nil
+}
+
+// MarshalAuthorizedKey returns a public key as an authorized_keys line:
+// "[options] ssh-rsa AAAA... comment\n".
+func MarshalAuthorizedKey(key *rsa.PublicKey, comment string, options []string) []byte {
+	line := KeyAlgoRSA + " " + base64.StdEncoding.EncodeToString(MarshalPublicKey(key))
+	if len(options) > 0 {
+		line = strings.Join(options, ",") + " " + line
+	}
+	if comment != "" {
+		line += " " + comment
+	}
+	return []byte(line + "\n")
+}
+
+func firstKeyLine(data []byte) (string, bool) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		return string(line), true
+	}
+	return "", false
+}
+
+// parseOptions splits the comma-separated option list at the start of line.
+// Commas and whitespace inside double quotes belong to the option value.
+func parseOptions(line string) ([]string, string, error) {
+	var options []string
+	start, quoted := 0, false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && quoted && i+1 < len(line):
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ',':
+			options = append(options, line[start:i])
+			start = i + 1
+		case c == ' ' || c == '\t':
+			options = append(options, line[start:i])
+			return options, line[i:], nil
+		}
+	}
+
+	if quoted {
+		return nil, "", errors.New("ssh: unterminated quote in key options")
+	}
+	return nil, "", errors.New("ssh: malformed public key line")
+}
@@ -0,0 +1,145 @@
+package sshkey
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testAuthorizedKey was written by "ssh-keygen -t rsa -b 1024 -C alice@laptop".
+const testAuthorizedKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDi7OpkdrNvgK4fXiXdJwH81cdlvFgF7PNjY2kOczVm6tYwt5fHRBeyU4TKUMkMabY8djPW1UPyVyYvJqm06GTsoiD71fk2Jq/qlUppHvW/Bz3phYO2A7iP4U1ViCjWhdajmdYIW0in2NQK1FMNtTtK/R2p0mIC7Jv0uqUpfFdpbQ== alice@laptop"
+
+// testModulus is the modulus of testAuthorizedKey, from "openssl rsa -modulus".
+const testModulus = "E2ECEA6476B36F80AE1F5E25DD2701FCD5C765BC5805ECF36363690E733566EAD630B797C74417B25384CA50C90C69B63C7633D6D543F257262F26A9B4E864ECA220FBD5F93626AFEA954A691EF5BF073DE98583B603B88FE14D558828D685D6A399D6085B48A7D8D40AD4530DB53B4AFD1DA9D26202EC9BF4BAA5297C57696D"
+
+func TestParseAuthorizedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		comment string
+		options []string
+	}{
+		{"plain", testAuthorizedKey, "alice@laptop", nil},
+		{"no comment", testAuthorizedKey[:len(testAuthorizedKey)-len(" alice@laptop")], "", nil},
+		{"comment with spaces", testAuthorizedKey + " (work laptop)", "alice@laptop (work laptop)", nil},
+		{"restrict", "restrict " + testAuthorizedKey, "alice@laptop", []string{"restrict"}},
+		{
+			"from and command",
+			`from="10.0.0.0/8,192.168.1.1",command="uptime" ` + testAuthorizedKey,
+			"alice@laptop",
+			[]string{`from="10.0.0.0/8,192.168.1.1"`, `command="uptime"`},
+		},
+		{
+			"quoted spaces and escaped quotes",
+			`restrict,command="echo \"a, b\" c",pty	` + testAuthorizedKey,
+			"alice@laptop",
+			[]string{"restrict", `command="echo \"a, b\" c"`, "pty"},
+		},
+		{
+			"comments and blank lines first",
+			"# deploy keys\n\n   \n" + `environment="A=b" ` + testAuthorizedKey + "\nssh-rsa AAAA other",
+			"alice@laptop",
+			[]string{`environment="A=b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthorizedKey([]byte(tt.line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Key.N.Text(16) != strings.ToLower(testModulus) || got.Key.E != 65537 {
+				t.Fatal("parsed a different key")
+			}
+			if got.Comment != tt.comment || !slices.Equal(got.Options, tt.options) {
+				t.Fatalf("got comment %q, options %q; want %q, %q", got.Comment, got.Options, tt.comment, tt.options)
+			}
+
+			line := MarshalAuthorizedKey(got.Key, got.Comment, got.Options)
+			again, err := ParseAuthorizedKey(line)
+			if err != nil {
+				t.Fatalf("%s: %v", line, err)
+			}
+			if !again.Key.Equal(got.Key) || again.Comment != got.Comment || !slices.Equal(again.Options, got.Options) {
+				t.Fatalf("round trip of %s: got %+v", line, again)
+			}
+		})
+	}
+}
+
+func TestMarshalAuthorizedKey(t *testing.T) {
+	parsed, err := ParseAuthorizedKey([]byte(testAuthorizedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(MarshalAuthorizedKey(parsed.Key, "alice@laptop", nil)); got != testAuthorizedKey+"\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestParseAuthorizedKeyErrors(t *testing.T) {
+	tests := []struct {
+		name, line string
+	}{
+		{"empty", "\n# only a comment\n"},
+		{"unterminated quote", `command="uptime ` + testAuthorizedKey},
+		{"options only", "restrict,pty"},
+		{"no key data", "ssh-rsa"},
+		{"other key type", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"},
+		{"bad base64", "ssh-rsa AAAA!!!! alice"},
+		{"type mismatch in blob", "ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"},
+	}
+
+	for _, tt := range tests {
+		if got, err := ParseAuthorizedKey([]byte(tt.line)); err == nil {
+			t.Errorf("%s: got %+v", tt.name, got)
+		}
+	}
+}
+
+func TestIsAuthorizedKeys(t *testing.T) {
+	for _, data := range []string{testAuthorizedKey, "# keys\nrestrict " + testAuthorizedKey} {
+		if !IsAuthorizedKeys([]byte(data)) {
+			t.Errorf("%.30q: not recognized", data)
+		}
+	}
+
+	for _, data := range []string{"-----BEGIN PUBLIC KEY-----\n", `{"kty":"RSA"}`, "\x30\x82\x01\x0a"} {
+		if IsAuthorizedKeys([]byte(data)) {
+			t.Errorf("%.30q: recognized", data)
+		}
+	}
+}
+
+func TestParseSSH2PublicKey(t *testing.T) {
+	// "ssh-keygen -e" of testAuthorizedKey.
+	const exported = `---- BEGIN SSH2 PUBLIC KEY ----
+Comment: "1024-bit RSA, converted by root@vm from OpenSSH"
+AAAAB3NzaC1yc2EAAAADAQABAAAAgQDi7OpkdrNvgK4fXiXdJwH81cdlvFgF7PNjY2kOcz
+Vm6tYwt5fHRBeyU4TKUMkMabY8djPW1UPyVyYvJqm06GTsoiD71fk2Jq/qlUppHvW/Bz3p
+hYO2A7iP4U1ViCjWhdajmdYIW0in2NQK1FMNtTtK/R2p0mIC7Jv0uqUpfFdpbQ==
+---- END SSH2 PUBLIC KEY ----
+`
+
+	if !IsSSH2PublicKey([]byte(exported)) {
+		t.Fatal("not recognized")
+	}
+
+	parsed, err := ParseSSH2PublicKey([]byte(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Key.N.Text(16) != strings.ToLower(testModulus) || parsed.Comment != "1024-bit RSA, converted by root@vm from OpenSSH" {
+		t.Fatalf("got %+v", parsed)
+	}
+
+	again, err := ParseSSH2PublicKey(MarshalSSH2PublicKey(parsed.Key, parsed.Comment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Key.Equal(parsed.Key) || again.Comment != parsed.Comment {
+		t.Fatalf("round trip: got %+v", again)
+	}
+}
@@ -0,0 +1,123 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	ssh2Begin = "---- BEGIN SSH2 PUBLIC KEY ----"
+	ssh2End   = "---- END SSH2 PUBLIC KEY ----"
+
+	// ssh2LineLength is the longest line RFC 4716 allows, excluding the line ending.
+	ssh2LineLength = 72
+)
+
+// SSH2PublicKey is a public key read from the RFC 4716 "SSH2 PUBLIC KEY" format.
+type SSH2PublicKey struct {
+	Key     *rsa.PublicKey
+	Comment string
+	Headers map[string]string // every header, including Comment, keyed as written
+}
+
+// IsSSH2PublicKey reports whether data holds an RFC 4716 public key.
+func IsSSH2PublicKey(data []byte) bool {
+	return bytes.Contains(data, []byte(ssh2Begin))
+}
+
+// ParseSSH2PublicKey decodes an RFC 4716 public key file.
+func ParseSSH2PublicKey(data []byte) (*SSH2PublicKey, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	begin := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == ssh2Begin {
+			begin = i
+			break
+		}
+	}
+	if begin < 0 {
+		return nil, errors.New("ssh: missing SSH2 PUBLIC KEY begin line")
+	}
+
+	key := &SSH2PublicKey{Headers: map[string]string{}}
+	var body strings.Builder
+	ended := false
+
+	for i := begin + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == ssh2End {
+			ended = true
+			break
+		}
+
+		// Headers come first and may continue on the next line after a backslash.
+		if tag, value, ok := strings.Cut(line, ":"); ok && body.Len() == 0 {
+			for strings.HasSuffix(value, "\\") && i+1 < len(lines) {
+				i++
+				value = strings.TrimSuffix(value, "\\") + strings.TrimSpace(lines[i])
+			}
+			key.Headers[tag] = strings.TrimSpace(value)
+			continue
+		}
+
+		body.WriteString(line)
+	}
+
+	if !ended {
+		return nil, errors.New("ssh: missing SSH2 PUBLIC KEY end line")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("ssh: malformed SSH2 public key data: %w", err)
+	}
+
+	if key.Key, err = ParsePublicKey(blob); err != nil {
+		return nil, err
+	}
+
+	for tag, value := range key.Headers {
+		if strings.EqualFold(tag, "Comment") {
+			key.Comment = unquote(value)
+		}
+	}
+
+	return key, nil
+}
+
+// MarshalSSH2PublicKey encodes a public key in the RFC 4716 format.
+func MarshalSSH2PublicKey(key *rsa.PublicKey, comment string) []byte {
+	var b strings.Builder
+	b.WriteString(ssh2Begin + "\n")
+
+	if comment != "" {
+		header := `Comment: "` + strings.ReplaceAll(comment, `"`, `\"`) + `"`
+		for len(header) > ssh2LineLength {
+			b.WriteString(header[:ssh2LineLength-1] + "\\\n")
+			header = header[ssh2LineLength-1:]
+		}
+		b.WriteString(header + "\n")
+	}
+
+	body := base64.StdEncoding.EncodeToString(MarshalPublicKey(key))
+	for len(body) > 70 {
+		b.WriteString(body[:70] + "\n")
+		body = body[70:]
+	}
+	b.WriteString(body + "\n")
+
+	b.WriteString(ssh2End + "\n")
+	return []byte(b.String())
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package sshkey
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+var errShortData = errors.New("ssh: short data")
+
+// reader decodes the SSH wire encoding (RFC 4251, section 5). The first error
+// sticks, so a sequence of reads can be checked once at the end.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.b) < 4 {
+		r.err = errShortData
+		return 0
+	}
+
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *reader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+
+	if uint32(len(r.b)) < n {
+		r.err = errShortData
+		return nil
+	}
+
+	s := r.b[:n]
+	r.b = r.b[n:]
+	return s
+}
+
+func (r *reader) mpint() *big.Int {
+	b := r.string()
+	if r.err != nil {
+		return nil
+	}
+
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		r.err = errors.New("ssh: negative mpint")
+		return nil
+	}
+
+	return new(big.Int).SetBytes(b)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func appendString(b, s []byte) []byte {
+	b = appendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendMPInt encodes a non-negative integer, with a leading zero byte when
+// the most significant bit is set.
+func appendMPInt(b []byte, n *big.Int) []byte {
+	v := n.Bytes()
+	if len(v) > 0 && v[0]&0x80 != 0 {
+		v = append([]byte{0}, v...)
+	}
+	return appendString(b, v)
+}